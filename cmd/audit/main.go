@@ -0,0 +1,49 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/RomanenkoDR/metrics/internal/audit"
+)
+
+const usage = `Usage: audit verify <file>...
+
+Checks that audit log entries are consecutive and each entry references the hash of the previous
+one. Prints the head of every log: compare it with the head logged by the server to detect
+entries removed from the end.
+`
+
+func main() {
+	if len(os.Args) < 3 || os.Args[1] != "verify" {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	code := 0
+	for _, path := range os.Args[2:] {
+		head, err := verify(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+			var tamper *audit.TamperError
+			if errors.As(err, &tamper) {
+				code = 1
+			} else if code == 0 {
+				code = 2
+			}
+			continue
+		}
+		fmt.Printf("%s: OK, %d entries, head %s\n", path, head.Seq, head.Hash)
+	}
+	os.Exit(code)
+}
+
+func verify(path string) (audit.Head, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return audit.Head{}, err
+	}
+	defer f.Close()
+	return audit.Verify(f)
+}
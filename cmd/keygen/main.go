@@ -0,0 +1,11 @@
+package main
+
+import (
+	"os"
+
+	"github.com/RomanenkoDR/metrics/internal/keygen"
+)
+
+func main() {
+	os.Exit(keygen.Run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
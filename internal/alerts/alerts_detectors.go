@@ -0,0 +1,75 @@
+package alerts
+
+import (
+	"time"
+
+	"github.com/RomanenkoDR/metrics/internal/middleware/logger"
+	"go.uber.org/zap"
+)
+
+// Event — отклонение, найденное детектором. Пока детектор возвращает событие, алерт горит.
+type Event struct {
+	Name    string // имя алерта, уникальное среди всех правил и детекторов
+	Expr    string
+	Metric  string
+	Value   float64
+	Labels  map[string]string
+	Summary string
+}
+
+// Detector — источник алертов помимо правил, например поиск аномалий.
+// Detect вызывается при каждом вычислении правил.
+type Detector interface {
+	Name() string
+	Detect(now time.Time) []Event
+}
+
+// AddDetector подключает детектор. Вызывается до Restore, чтобы его алерты восстановились.
+func (e *Engine) AddDetector(d Detector) {
+	e.mu.Lock()
+	e.detectors = append(e.detectors, d)
+	e.mu.Unlock()
+}
+
+// evaluateEvents обновляет алерты детектора по найденным событиям и возвращает true,
+// если состояние изменилось. Событие сразу переводит алерт в firing. Вызывается под блокировкой.
+func (e *Engine) evaluateEvents(source string, events []Event, now time.Time) bool {
+	changed := false
+	seen := map[string]bool{}
+
+	for _, ev := range events {
+		seen[ev.Name] = true
+
+		a, exists := e.alerts[ev.Name]
+		if exists && a.State != StateResolved {
+			a.Value = ev.Value
+			continue
+		}
+
+		e.alerts[ev.Name] = &Alert{
+			Rule:     ev.Name,
+			Source:   source,
+			Expr:     ev.Expr,
+			Metric:   ev.Metric,
+			State:    StateFiring,
+			Value:    ev.Value,
+			Labels:   ev.Labels,
+			Summary:  ev.Summary,
+			ActiveAt: now,
+			FiredAt:  &now,
+		}
+		logger.Warn("Алерт сработал", zap.String("rule", ev.Name), zap.String("source", source), zap.Float64("value", ev.Value))
+		changed = true
+	}
+
+	for name, a := range e.alerts {
+		if a.Source != source || seen[name] || a.State != StateFiring {
+			continue
+		}
+		a.State = StateResolved
+		a.ResolvedAt = &now
+		logger.Info("Алерт разрешён", zap.String("rule", name), zap.String("source", source))
+		changed = true
+	}
+	return changed
+}
@@ -0,0 +1,393 @@
+package alerts
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/RomanenkoDR/metrics/internal/forecast"
+	"github.com/RomanenkoDR/metrics/internal/middleware/logger"
+	"github.com/RomanenkoDR/metrics/internal/storage"
+	"go.uber.org/zap"
+)
+
+// State — состояние алерта
+type State string
+
+const (
+	StatePending  State = "pending"  // условие выполняется, но меньше заданного for
+	StateFiring   State = "firing"   // условие выполняется дольше for
+	StateResolved State = "resolved" // условие перестало выполняться после firing
+)
+
+// resolvedRetention — сколько хранится разрешённый алерт, прежде чем он будет забыт
+const resolvedRetention = 15 * time.Minute
+
+// stateKey — ключ, под которым состояние алертов сохраняется в хранилище
+const stateKey = "alerts"
+
+// Alert — текущее состояние правила
+type Alert struct {
+	Rule       string            `json:"rule"`
+	Source     string            `json:"source,omitempty"` // детектор, создавший алерт; пусто для правил
+	Expr       string            `json:"expr"`
+	Metric     string            `json:"metric"`
+	State      State             `json:"state"`
+	Value      float64           `json:"value"`
+	Labels     map[string]string `json:"labels"`
+	Summary    string            `json:"summary,omitempty"`
+	ActiveAt   time.Time         `json:"active_at"`
+	FiredAt    *time.Time        `json:"fired_at,omitempty"`
+	ResolvedAt *time.Time        `json:"resolved_at,omitempty"`
+	SilencedBy []string          `json:"silenced_by,omitempty"` // активные тишины, под которые попал алерт
+	AckedBy    string            `json:"acked_by,omitempty"`
+	AckComment string            `json:"ack_comment,omitempty"`
+	AckedAt    *time.Time        `json:"acked_at,omitempty"`
+}
+
+// Silenced сообщает, заглушён ли алерт тишиной
+func (a Alert) Silenced() bool {
+	return len(a.SilencedBy) > 0
+}
+
+// Acked сообщает, подтверждён ли алерт
+func (a Alert) Acked() bool {
+	return a.AckedAt != nil
+}
+
+// sample — значение счётчика при прошлом вычислении, нужно для расчёта скорости
+type sample struct {
+	value float64
+	at    time.Time
+}
+
+// Sink получает состояние всех алертов после каждого вычисления правил
+type Sink interface {
+	Notify(alerts []Alert)
+}
+
+// Engine периодически вычисляет правила над хранилищем метрик
+type Engine struct {
+	rules    []Rule
+	interval time.Duration
+	store    storage.MemStorage
+	state    storage.StateStore
+
+	mu        sync.RWMutex
+	alerts    map[string]*Alert
+	silences  map[string]*Silence
+	samples   map[string]sample
+	sinks     []Sink
+	detectors []Detector
+
+	now func() time.Time
+}
+
+// NewEngine создаёт вычислитель правил. state может быть nil, тогда состояние не сохраняется.
+func NewEngine(cfg Config, store storage.MemStorage, state storage.StateStore) *Engine {
+	interval := time.Duration(cfg.Interval)
+	if interval <= 0 {
+		interval = defaultInterval
+	}
+	return &Engine{
+		rules:    cfg.Rules,
+		interval: interval,
+		store:    store,
+		state:    state,
+		alerts:   map[string]*Alert{},
+		silences: map[string]*Silence{},
+		samples:  map[string]sample{},
+		now:      time.Now,
+	}
+}
+
+// AddSink подписывает получателя на результаты вычисления правил
+func (e *Engine) AddSink(s Sink) {
+	e.mu.Lock()
+	e.sinks = append(e.sinks, s)
+	e.mu.Unlock()
+}
+
+// Restore загружает состояние алертов и тишины, сохранённые до перезапуска
+func (e *Engine) Restore() error {
+	if e.state == nil {
+		return nil
+	}
+
+	if err := e.restoreSilences(); err != nil {
+		return err
+	}
+
+	data, err := e.state.LoadState(stateKey)
+	if err != nil || data == nil {
+		return err
+	}
+
+	var saved []*Alert
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return err
+	}
+
+	known := map[string]bool{}
+	for _, r := range e.rules {
+		known[r.Name] = true
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	sources := map[string]bool{}
+	for _, d := range e.detectors {
+		sources[d.Name()] = true
+	}
+	for _, a := range saved {
+		// Алерты удалённых из конфигурации правил и отключённых детекторов не восстанавливаем
+		if known[a.Rule] || sources[a.Source] {
+			e.alerts[a.Rule] = a
+		}
+	}
+	e.applySilences(e.now())
+	return nil
+}
+
+// Run вычисляет правила с заданным периодом до отмены контекста
+func (e *Engine) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			e.Evaluate()
+		}
+	}
+}
+
+// Evaluate вычисляет все правила один раз и сохраняет изменившееся состояние
+func (e *Engine) Evaluate() {
+	now := e.now()
+
+	// Детекторы читают хранилище сами, поэтому вызываются без блокировки движка
+	e.mu.RLock()
+	detectors := e.detectors
+	e.mu.RUnlock()
+	events := make([][]Event, len(detectors))
+	for i, d := range detectors {
+		events[i] = d.Detect(now)
+	}
+
+	e.mu.Lock()
+	changed := false
+	rates := e.rates(now)
+	for i := range e.rules {
+		if e.evaluateRule(&e.rules[i], now, rates) {
+			changed = true
+		}
+	}
+	for i, d := range detectors {
+		if e.evaluateEvents(d.Name(), events[i], now) {
+			changed = true
+		}
+	}
+
+	// Забываем давно разрешённые алерты
+	for k, a := range e.alerts {
+		if a.State == StateResolved && now.Sub(*a.ResolvedAt) > resolvedRetention {
+			delete(e.alerts, k)
+			changed = true
+		}
+	}
+
+	silencesPruned := e.applySilences(now)
+
+	var snapshot []byte
+	if changed && e.state != nil {
+		snapshot, _ = json.Marshal(e.list(nil))
+	}
+	sinks := e.sinks
+	e.mu.Unlock()
+
+	e.saveState(stateKey, snapshot)
+	if silencesPruned {
+		e.saveSilences()
+	}
+
+	if len(sinks) > 0 {
+		all := e.All()
+		for _, s := range sinks {
+			s.Notify(all)
+		}
+	}
+}
+
+// evaluateRule обновляет алерт правила и возвращает true, если его состояние изменилось.
+// Вызывается под блокировкой.
+func (e *Engine) evaluateRule(r *Rule, now time.Time, rates map[string]float64) bool {
+	value, ok := e.value(r.cond, now, rates)
+	active := ok && r.cond.compare(value)
+
+	a, exists := e.alerts[r.Name]
+	if !active {
+		switch {
+		case !exists:
+			return false
+		case a.State == StatePending:
+			delete(e.alerts, r.Name)
+			return true
+		case a.State == StateFiring:
+			a.State = StateResolved
+			a.ResolvedAt = &now
+			logger.Info("Алерт разрешён", zap.String("rule", r.Name))
+			return true
+		}
+		return false
+	}
+
+	if !exists || a.State == StateResolved {
+		a = &Alert{
+			Rule:     r.Name,
+			Expr:     r.Expr,
+			Metric:   r.cond.Metric,
+			State:    StatePending,
+			Labels:   r.labels(),
+			Summary:  r.Summary,
+			ActiveAt: now,
+		}
+		e.alerts[r.Name] = a
+		exists = false
+	}
+	a.Value = value
+
+	if a.State == StatePending && now.Sub(a.ActiveAt) >= r.cond.For {
+		a.State = StateFiring
+		a.FiredAt = &now
+		logger.Warn("Алерт сработал", zap.String("rule", r.Name), zap.Float64("value", value))
+		return true
+	}
+	return !exists
+}
+
+// value возвращает значение метрики для условия. Скорость берётся из rates, для прогноза
+// нужны хотя бы две точки в истории метрики.
+func (e *Engine) value(c Condition, now time.Time, rates map[string]float64) (float64, bool) {
+	switch c.Type {
+	case gaugeType:
+		if c.Predict > 0 {
+			f, err := forecast.Fit(forecast.Linear, e.store.History(gaugeType, c.Metric))
+			if err != nil {
+				return 0, false
+			}
+			return f.ValueAt(now.Add(c.Predict)), true
+		}
+		v, ok := e.store.GetGauge(c.Metric)
+		return float64(v), ok
+	case counterType:
+		if c.Rate {
+			rate, ok := rates[c.Metric]
+			return rate, ok
+		}
+		v, ok := e.store.GetCounter(c.Metric)
+		return float64(v), ok
+	}
+	return 0, false
+}
+
+// rates вычисляет скорость счётчиков, за которыми следят правила. Каждый счётчик читается
+// один раз за вычисление, поэтому все правила на него видят одну скорость. Для скорости
+// нужно два вычисления подряд. Вызывается под блокировкой.
+func (e *Engine) rates(now time.Time) map[string]float64 {
+	rates := map[string]float64{}
+	sampled := map[string]bool{}
+	for i := range e.rules {
+		c := e.rules[i].cond
+		if c.Type != counterType || !c.Rate || sampled[c.Metric] {
+			continue
+		}
+		sampled[c.Metric] = true
+
+		v, ok := e.store.GetCounter(c.Metric)
+		if !ok {
+			continue
+		}
+		prev, seen := e.samples[c.Metric]
+		e.samples[c.Metric] = sample{value: float64(v), at: now}
+		elapsed := now.Sub(prev.at).Seconds()
+		if !seen || elapsed <= 0 {
+			continue
+		}
+
+		delta := float64(v) - prev.value
+		// Счётчик мог быть сброшен, тогда весь текущий объём считаем приростом
+		if delta < 0 {
+			delta = float64(v)
+		}
+		rates[c.Metric] = delta / elapsed
+	}
+	return rates
+}
+
+// labels возвращает метки алерта: метки правила, имя правила и метрики
+func (r *Rule) labels() map[string]string {
+	labels := map[string]string{
+		"alertname": r.Name,
+		"metric":    r.cond.Metric,
+	}
+	for k, v := range r.Labels {
+		labels[k] = v
+	}
+	return labels
+}
+
+// Forget забывает прошлые значения удалённых счётчиков, чтобы скорость вновь созданного счётчика
+// не считалась от значения удалённого. Алерты правил на удалённые метрики разрешаются
+// при следующем вычислении, как для любой пропавшей метрики. Нулевой *Engine ничего не делает.
+func (e *Engine) Forget(counters []string) {
+	if e == nil {
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, name := range counters {
+		delete(e.samples, name)
+	}
+}
+
+// Active возвращает алерты в состояниях pending и firing
+func (e *Engine) Active() []Alert {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	var res []Alert
+	for _, a := range e.list(func(a *Alert) bool { return a.State != StateResolved }) {
+		res = append(res, *a)
+	}
+	return res
+}
+
+// All возвращает все алерты, включая недавно разрешённые
+func (e *Engine) All() []Alert {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	var res []Alert
+	for _, a := range e.list(nil) {
+		res = append(res, *a)
+	}
+	return res
+}
+
+// list возвращает алерты, отсортированные по имени правила. Вызывается под блокировкой.
+func (e *Engine) list(keep func(*Alert) bool) []*Alert {
+	res := make([]*Alert, 0, len(e.alerts))
+	for _, a := range e.alerts {
+		if keep == nil || keep(a) {
+			res = append(res, a)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Rule < res[j].Rule })
+	return res
+}
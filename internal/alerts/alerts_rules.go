@@ -0,0 +1,211 @@
+package alerts
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	counterType = "counter"
+	gaugeType   = "gauge"
+
+	defaultInterval = 15 * time.Second
+)
+
+// Duration — длительность, которая в JSON задаётся строкой вида "30s" или "2m"
+type Duration time.Duration
+
+// UnmarshalJSON принимает строку в формате time.ParseDuration или число наносекунд
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		var n int64
+		if err := json.Unmarshal(b, &n); err != nil {
+			return fmt.Errorf("incorrect duration %s", b)
+		}
+		*d = Duration(n)
+		return nil
+	}
+
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// MarshalJSON записывает длительность строкой
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// Config — содержимое файла с правилами алертов
+type Config struct {
+	Interval Duration `json:"interval"` // период вычисления правил
+	Rules    []Rule   `json:"rules"`
+}
+
+// Rule — правило алерта. Expr задаёт условие, например:
+//
+//	gauge HeapAlloc > 1e9 for 2m
+//	counter PollCount rate > 5/s for 30s
+//	gauge DiskUsed predict 4h > 1e11
+type Rule struct {
+	Name    string            `json:"name"`
+	Expr    string            `json:"expr"`
+	Labels  map[string]string `json:"labels,omitempty"`
+	Summary string            `json:"summary,omitempty"`
+
+	cond Condition
+}
+
+// Condition — разобранное условие правила
+type Condition struct {
+	Type      string        // counter или gauge
+	Metric    string        // имя метрики
+	Rate      bool          // сравнивать скорость роста счётчика в секунду, а не значение
+	Predict   time.Duration // сравнивать значение, предсказанное линейным трендом на этот срок вперёд
+	Op        string        // оператор сравнения
+	Threshold float64       // порог
+	For       time.Duration // сколько условие должно выполняться до перехода в firing
+}
+
+// LoadConfig загружает правила алертов из JSON-файла
+func LoadConfig(path string) (Config, error) {
+	var cfg Config
+
+	file, err := os.Open(path)
+	if err != nil {
+		return cfg, err
+	}
+	defer file.Close()
+
+	err = json.NewDecoder(file).Decode(&cfg)
+	if err != nil {
+		return cfg, err
+	}
+
+	err = cfg.compile()
+	return cfg, err
+}
+
+// compile проверяет правила и разбирает их условия
+func (cfg *Config) compile() error {
+	if cfg.Interval <= 0 {
+		cfg.Interval = Duration(defaultInterval)
+	}
+
+	names := map[string]bool{}
+	for i := range cfg.Rules {
+		r := &cfg.Rules[i]
+		if r.Name == "" {
+			return fmt.Errorf("rule %d: empty name", i)
+		}
+		if names[r.Name] {
+			return fmt.Errorf("rule %q: duplicate name", r.Name)
+		}
+		names[r.Name] = true
+
+		cond, err := ParseCondition(r.Expr)
+		if err != nil {
+			return fmt.Errorf("rule %q: %w", r.Name, err)
+		}
+		r.cond = cond
+	}
+	return nil
+}
+
+// ParseCondition разбирает условие вида
+// "<type> <metric> [rate | predict <duration>] <op> <threshold>[/s] [for <duration>]"
+func ParseCondition(expr string) (Condition, error) {
+	var c Condition
+
+	tokens := strings.Fields(expr)
+	if len(tokens) < 4 {
+		return c, fmt.Errorf("incorrect expression %q", expr)
+	}
+
+	c.Type, c.Metric = tokens[0], tokens[1]
+	switch c.Type {
+	case counterType, gaugeType:
+	default:
+		return c, fmt.Errorf("incorrect metric type %q", c.Type)
+	}
+	tokens = tokens[2:]
+
+	if tokens[0] == "rate" {
+		if c.Type != counterType {
+			return c, fmt.Errorf("rate is supported only for counters")
+		}
+		c.Rate = true
+		tokens = tokens[1:]
+	} else if tokens[0] == "predict" {
+		if c.Type != gaugeType {
+			return c, fmt.Errorf("predict is supported only for gauges")
+		}
+		d, err := time.ParseDuration(tokens[1])
+		if err != nil || d <= 0 {
+			return c, fmt.Errorf("incorrect predict duration %q", tokens[1])
+		}
+		c.Predict = d
+		tokens = tokens[2:]
+	}
+
+	if len(tokens) < 2 {
+		return c, fmt.Errorf("incorrect expression %q", expr)
+	}
+
+	switch tokens[0] {
+	case ">", ">=", "<", "<=", "==", "!=":
+		c.Op = tokens[0]
+	default:
+		return c, fmt.Errorf("incorrect operator %q", tokens[0])
+	}
+
+	threshold := tokens[1]
+	if c.Rate {
+		threshold = strings.TrimSuffix(threshold, "/s")
+	}
+	v, err := strconv.ParseFloat(threshold, 64)
+	if err != nil {
+		return c, fmt.Errorf("incorrect threshold %q", tokens[1])
+	}
+	c.Threshold = v
+	tokens = tokens[2:]
+
+	if len(tokens) > 0 {
+		if len(tokens) != 2 || tokens[0] != "for" {
+			return c, fmt.Errorf("unexpected %q", strings.Join(tokens, " "))
+		}
+		c.For, err = time.ParseDuration(tokens[1])
+		if err != nil {
+			return c, fmt.Errorf("incorrect duration: %w", err)
+		}
+	}
+
+	return c, nil
+}
+
+// compare применяет оператор условия к значению
+func (c Condition) compare(v float64) bool {
+	switch c.Op {
+	case ">":
+		return v > c.Threshold
+	case ">=":
+		return v >= c.Threshold
+	case "<":
+		return v < c.Threshold
+	case "<=":
+		return v <= c.Threshold
+	case "==":
+		return v == c.Threshold
+	case "!=":
+		return v != c.Threshold
+	}
+	return false
+}
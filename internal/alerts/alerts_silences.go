@@ -0,0 +1,284 @@
+package alerts
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/RomanenkoDR/metrics/internal/middleware/logger"
+	"go.uber.org/zap"
+)
+
+// silencesKey — ключ, под которым тишины сохраняются в хранилище
+const silencesKey = "silences"
+
+// expiredRetention — сколько истёкшая тишина остаётся в списке
+const expiredRetention = 24 * time.Hour
+
+// Ошибки работы с тишинами и подтверждениями
+var (
+	ErrSilenceNotFound = errors.New("silence not found")
+	ErrAlertNotFound   = errors.New("alert not found")
+	ErrAlertNotFiring  = errors.New("alert is not firing")
+)
+
+// Состояния тишины
+const (
+	SilencePending = "pending"
+	SilenceActive  = "active"
+	SilenceExpired = "expired"
+)
+
+// Matcher сравнивает метку алерта (alertname, metric или метку правила) со значением
+type Matcher struct {
+	Name    string `json:"name"`
+	Value   string `json:"value"`
+	IsRegex bool   `json:"is_regex,omitempty"`
+
+	re *regexp.Regexp
+}
+
+// Silence заглушает уведомления по алертам, метки которых совпали со всеми матчерами
+type Silence struct {
+	ID        string    `json:"id"`
+	Matchers  []Matcher `json:"matchers"`
+	StartsAt  time.Time `json:"starts_at"`
+	EndsAt    time.Time `json:"ends_at"`
+	Author    string    `json:"author"`
+	Comment   string    `json:"comment,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	State     string    `json:"state"`
+}
+
+// compile проверяет матчеры и компилирует регулярные выражения
+func (s *Silence) compile() error {
+	if len(s.Matchers) == 0 {
+		return errors.New("at least one matcher is required")
+	}
+	for i := range s.Matchers {
+		m := &s.Matchers[i]
+		if m.Name == "" {
+			return fmt.Errorf("matcher %d: empty name", i)
+		}
+		if m.IsRegex {
+			re, err := regexp.Compile("^(?:" + m.Value + ")$")
+			if err != nil {
+				return fmt.Errorf("matcher %d: %w", i, err)
+			}
+			m.re = re
+		}
+	}
+	return nil
+}
+
+// matches проверяет, что все матчеры совпали с метками алерта
+func (s *Silence) matches(labels map[string]string) bool {
+	for _, m := range s.Matchers {
+		v := labels[m.Name]
+		if m.re != nil {
+			if !m.re.MatchString(v) {
+				return false
+			}
+		} else if v != m.Value {
+			return false
+		}
+	}
+	return true
+}
+
+// state вычисляет состояние тишины на момент now
+func (s *Silence) state(now time.Time) string {
+	switch {
+	case now.Before(s.StartsAt):
+		return SilencePending
+	case now.Before(s.EndsAt):
+		return SilenceActive
+	default:
+		return SilenceExpired
+	}
+}
+
+// AddSilence проверяет и сохраняет новую тишину. Если StartsAt не задан, тишина начинается сразу.
+func (e *Engine) AddSilence(s Silence) (Silence, error) {
+	now := e.now()
+	if s.StartsAt.IsZero() {
+		s.StartsAt = now
+	}
+	if !s.EndsAt.After(s.StartsAt) {
+		return s, errors.New("ends_at should be after starts_at")
+	}
+	if !s.EndsAt.After(now) {
+		return s, errors.New("ends_at should be in the future")
+	}
+	if s.Author == "" {
+		return s, errors.New("author is required")
+	}
+	if err := s.compile(); err != nil {
+		return s, err
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return s, err
+	}
+	s.ID = hex.EncodeToString(id)
+	s.CreatedAt = now
+	s.State = s.state(now)
+
+	// Движок хранит свою копию: её читают Silences и Evaluate под блокировкой
+	stored := s
+	e.mu.Lock()
+	e.silences[s.ID] = &stored
+	e.applySilences(now)
+	e.mu.Unlock()
+
+	logger.Info("Добавлена тишина", zap.String("id", s.ID), zap.String("author", s.Author), zap.Time("ends_at", s.EndsAt))
+	e.saveSilences()
+	return s, nil
+}
+
+// ExpireSilence досрочно завершает тишину
+func (e *Engine) ExpireSilence(id string) error {
+	now := e.now()
+
+	e.mu.Lock()
+	s, ok := e.silences[id]
+	if !ok {
+		e.mu.Unlock()
+		return ErrSilenceNotFound
+	}
+	if s.EndsAt.After(now) {
+		s.EndsAt = now
+	}
+	if s.StartsAt.After(now) {
+		s.StartsAt = now
+	}
+	e.applySilences(now)
+	e.mu.Unlock()
+
+	logger.Info("Тишина завершена", zap.String("id", id))
+	e.saveSilences()
+	return nil
+}
+
+// Silences возвращает все тишины, новые первыми
+func (e *Engine) Silences() []Silence {
+	now := e.now()
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	res := make([]Silence, 0, len(e.silences))
+	for _, s := range e.silences {
+		c := *s
+		c.State = s.state(now)
+		res = append(res, c)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].CreatedAt.After(res[j].CreatedAt) })
+	return res
+}
+
+// Ack подтверждает горящий алерт: повторные уведомления по нему больше не отправляются
+func (e *Engine) Ack(rule, author, comment string) (Alert, error) {
+	if author == "" {
+		return Alert{}, errors.New("author is required")
+	}
+	now := e.now()
+
+	e.mu.Lock()
+	a, ok := e.alerts[rule]
+	if !ok || a.State == StateResolved {
+		e.mu.Unlock()
+		return Alert{}, ErrAlertNotFound
+	}
+	if a.State != StateFiring {
+		e.mu.Unlock()
+		return Alert{}, ErrAlertNotFiring
+	}
+	a.AckedBy = author
+	a.AckComment = comment
+	a.AckedAt = &now
+	res := *a
+	snapshot, _ := json.Marshal(e.list(nil))
+	e.mu.Unlock()
+
+	logger.Info("Алерт подтверждён", zap.String("rule", rule), zap.String("author", author))
+	e.saveState(stateKey, snapshot)
+	return res, nil
+}
+
+// applySilences отмечает алерты, попавшие под активные тишины, и удаляет давно истёкшие тишины.
+// Вызывается под блокировкой. Возвращает true, если список тишин изменился.
+func (e *Engine) applySilences(now time.Time) bool {
+	pruned := false
+	for id, s := range e.silences {
+		if now.Sub(s.EndsAt) > expiredRetention {
+			delete(e.silences, id)
+			pruned = true
+		}
+	}
+
+	for _, a := range e.alerts {
+		a.SilencedBy = nil
+		for _, s := range e.silences {
+			if s.state(now) == SilenceActive && s.matches(a.Labels) {
+				a.SilencedBy = append(a.SilencedBy, s.ID)
+			}
+		}
+		sort.Strings(a.SilencedBy)
+	}
+	return pruned
+}
+
+// restoreSilences загружает тишины, сохранённые до перезапуска
+func (e *Engine) restoreSilences() error {
+	data, err := e.state.LoadState(silencesKey)
+	if err != nil || data == nil {
+		return err
+	}
+
+	var saved []Silence
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for i := range saved {
+		s := saved[i]
+		if err := s.compile(); err != nil {
+			logger.Warn("Пропущена некорректная тишина", zap.String("id", s.ID), zap.Error(err))
+			continue
+		}
+		e.silences[s.ID] = &s
+	}
+	return nil
+}
+
+// saveSilences сохраняет текущий список тишин
+func (e *Engine) saveSilences() {
+	e.mu.RLock()
+	list := make([]*Silence, 0, len(e.silences))
+	for _, s := range e.silences {
+		list = append(list, s)
+	}
+	snapshot, _ := json.Marshal(list)
+	e.mu.RUnlock()
+
+	e.saveState(silencesKey, snapshot)
+}
+
+// saveState записывает состояние в хранилище, если оно подключено
+func (e *Engine) saveState(key string, data []byte) {
+	if e.state == nil || data == nil {
+		return
+	}
+	if err := e.state.SaveState(key, data); err != nil {
+		logger.Error("Ошибка сохранения состояния алертов", zap.String("key", key), zap.Error(err))
+	}
+}
@@ -0,0 +1,233 @@
+package alerts
+
+import (
+	"testing"
+	"time"
+
+	"github.com/RomanenkoDR/metrics/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memState — StateStore в памяти для тестов
+type memState map[string][]byte
+
+func (m memState) SaveState(key string, data []byte) error {
+	m[key] = data
+	return nil
+}
+
+func (m memState) LoadState(key string) ([]byte, error) {
+	return m[key], nil
+}
+
+// fakeClock позволяет управлять временем вычисления правил
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time { return c.t }
+
+func (c *fakeClock) add(d time.Duration) { c.t = c.t.Add(d) }
+
+func newTestEngine(t *testing.T, store storage.MemStorage, state storage.StateStore, rules ...Rule) (*Engine, *fakeClock) {
+	t.Helper()
+	cfg := Config{Rules: rules}
+	require.NoError(t, cfg.compile())
+
+	clock := &fakeClock{t: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	e := NewEngine(cfg, store, state)
+	e.now = clock.now
+	return e, clock
+}
+
+func TestParseCondition(t *testing.T) {
+	tests := []struct {
+		name    string
+		expr    string
+		want    Condition
+		wantErr bool
+	}{
+		{"Gauge with for", "gauge HeapAlloc > 1e9 for 2m",
+			Condition{Type: "gauge", Metric: "HeapAlloc", Op: ">", Threshold: 1e9, For: 2 * time.Minute}, false},
+		{"Counter rate", "counter PollCount rate >= 5/s",
+			Condition{Type: "counter", Metric: "PollCount", Rate: true, Op: ">=", Threshold: 5}, false},
+		{"Counter value", "counter PollCount < 10",
+			Condition{Type: "counter", Metric: "PollCount", Op: "<", Threshold: 10}, false},
+		{"Gauge predict", "gauge DiskUsed predict 4h > 1e11",
+			Condition{Type: "gauge", Metric: "DiskUsed", Predict: 4 * time.Hour, Op: ">", Threshold: 1e11}, false},
+		{"Gauge rate", "gauge HeapAlloc rate > 1", Condition{}, true},
+		{"Counter predict", "counter PollCount predict 1h > 1", Condition{}, true},
+		{"Wrong predict", "gauge X predict soon > 1", Condition{}, true},
+		{"Wrong type", "histogram X > 1", Condition{}, true},
+		{"Wrong operator", "gauge X => 1", Condition{}, true},
+		{"Wrong threshold", "gauge X > abc", Condition{}, true},
+		{"Wrong for", "gauge X > 1 during 2m", Condition{}, true},
+		{"Too short", "gauge X >", Condition{}, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := ParseCondition(tc.expr)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, c)
+		})
+	}
+}
+
+func TestEngineLifecycle(t *testing.T) {
+	store := storage.New()
+	state := memState{}
+	e, clock := newTestEngine(t, store, state, Rule{Name: "HighHeap", Expr: "gauge HeapAlloc > 100 for 2m"})
+
+	store.UpdateGauge("HeapAlloc", 200)
+	e.Evaluate()
+	active := e.Active()
+	require.Len(t, active, 1)
+	assert.Equal(t, StatePending, active[0].State)
+	assert.Equal(t, "HighHeap", active[0].Labels["alertname"])
+
+	clock.add(2 * time.Minute)
+	e.Evaluate()
+	require.Len(t, e.Active(), 1)
+	assert.Equal(t, StateFiring, e.Active()[0].State)
+
+	// Состояние сохранено и восстанавливается новым экземпляром
+	restored, _ := newTestEngine(t, store, state, Rule{Name: "HighHeap", Expr: "gauge HeapAlloc > 100 for 2m"})
+	require.NoError(t, restored.Restore())
+	require.Len(t, restored.Active(), 1)
+	assert.Equal(t, StateFiring, restored.Active()[0].State)
+
+	store.UpdateGauge("HeapAlloc", 50)
+	clock.add(time.Minute)
+	e.Evaluate()
+	assert.Empty(t, e.Active())
+	assert.Equal(t, StateResolved, e.alerts["HighHeap"].State)
+
+	clock.add(resolvedRetention + time.Second)
+	e.Evaluate()
+	assert.Empty(t, e.alerts)
+}
+
+func TestEnginePendingCancelled(t *testing.T) {
+	store := storage.New()
+	e, clock := newTestEngine(t, store, nil, Rule{Name: "HighHeap", Expr: "gauge HeapAlloc > 100 for 2m"})
+
+	store.UpdateGauge("HeapAlloc", 200)
+	e.Evaluate()
+	require.Len(t, e.Active(), 1)
+
+	store.UpdateGauge("HeapAlloc", 50)
+	clock.add(time.Minute)
+	e.Evaluate()
+	assert.Empty(t, e.alerts)
+}
+
+func TestEngineCounterRate(t *testing.T) {
+	store := storage.New()
+	e, clock := newTestEngine(t, store, nil, Rule{Name: "FastPoll", Expr: "counter PollCount rate > 5/s"})
+
+	store.UpdateCounter("PollCount", 10)
+	e.Evaluate()
+	assert.Empty(t, e.Active(), "rate needs two samples")
+
+	// 100 за 10 секунд — 10/s
+	store.UpdateCounter("PollCount", 100)
+	clock.add(10 * time.Second)
+	e.Evaluate()
+	require.Len(t, e.Active(), 1)
+	assert.Equal(t, StateFiring, e.Active()[0].State)
+	assert.Equal(t, 10.0, e.Active()[0].Value)
+
+	// 20 за 10 секунд — 2/s
+	store.UpdateCounter("PollCount", 20)
+	clock.add(10 * time.Second)
+	e.Evaluate()
+	assert.Empty(t, e.Active())
+}
+
+func TestEngineCounterRateShared(t *testing.T) {
+	store := storage.New()
+	e, clock := newTestEngine(t, store, nil,
+		Rule{Name: "PollWarn", Expr: "counter PollCount rate > 1/s"},
+		Rule{Name: "PollCrit", Expr: "counter PollCount rate > 2/s"},
+	)
+
+	store.UpdateCounter("PollCount", 10)
+	e.Evaluate()
+
+	// 30 за 10 секунд — 3/s, выше обоих порогов
+	store.UpdateCounter("PollCount", 30)
+	clock.add(10 * time.Second)
+	e.Evaluate()
+	active := e.Active()
+	require.Len(t, active, 2, "rules on one counter see the same rate")
+	for _, a := range active {
+		assert.Equal(t, StateFiring, a.State, a.Rule)
+		assert.Equal(t, 3.0, a.Value, a.Rule)
+	}
+}
+
+func TestEngineSilences(t *testing.T) {
+	store := storage.New()
+	state := memState{}
+	rule := Rule{Name: "HighHeap", Expr: "gauge HeapAlloc > 100", Labels: map[string]string{"severity": "page"}}
+	e, clock := newTestEngine(t, store, state, rule)
+
+	store.UpdateGauge("HeapAlloc", 200)
+	e.Evaluate()
+
+	_, err := e.AddSilence(Silence{Matchers: []Matcher{{Name: "severity", Value: "page"}}, EndsAt: clock.t.Add(time.Hour)})
+	assert.Error(t, err, "author is required")
+
+	_, err = e.AddSilence(Silence{Author: "ops", EndsAt: clock.t.Add(time.Hour)})
+	assert.Error(t, err, "matchers are required")
+
+	s, err := e.AddSilence(Silence{
+		Matchers: []Matcher{{Name: "alertname", Value: "High.*", IsRegex: true}},
+		EndsAt:   clock.t.Add(time.Hour),
+		Author:   "ops",
+		Comment:  "maintenance",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, SilenceActive, s.State)
+	assert.Equal(t, []string{s.ID}, e.Active()[0].SilencedBy)
+
+	// Тишины переживают перезапуск
+	restored, _ := newTestEngine(t, store, state, rule)
+	require.NoError(t, restored.Restore())
+	require.Len(t, restored.Silences(), 1)
+	assert.True(t, restored.Active()[0].Silenced())
+
+	require.NoError(t, e.ExpireSilence(s.ID))
+	assert.False(t, e.Active()[0].Silenced())
+	assert.Equal(t, SilenceExpired, e.Silences()[0].State)
+	assert.ErrorIs(t, e.ExpireSilence("nosuch"), ErrSilenceNotFound)
+
+	clock.add(expiredRetention + time.Minute)
+	e.Evaluate()
+	assert.Empty(t, e.Silences())
+}
+
+func TestEngineAck(t *testing.T) {
+	store := storage.New()
+	e, _ := newTestEngine(t, store, memState{},
+		Rule{Name: "HighHeap", Expr: "gauge HeapAlloc > 100"},
+		Rule{Name: "SlowHeap", Expr: "gauge HeapAlloc > 100 for 1h"})
+
+	_, err := e.Ack("HighHeap", "ops", "")
+	assert.ErrorIs(t, err, ErrAlertNotFound)
+
+	store.UpdateGauge("HeapAlloc", 200)
+	e.Evaluate()
+
+	_, err = e.Ack("SlowHeap", "ops", "")
+	assert.ErrorIs(t, err, ErrAlertNotFiring)
+
+	a, err := e.Ack("HighHeap", "ops", "looking into it")
+	require.NoError(t, err)
+	assert.True(t, a.Acked())
+	assert.Equal(t, "ops", a.AckedBy)
+}
@@ -0,0 +1,71 @@
+// Package anomaly ищет аномальные значения gauge-метрик по скользящей статистике:
+// экспоненциально взвешенным среднему и дисперсии (EWMA)
+package anomaly
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+)
+
+const (
+	defaultAlpha  = 0.1
+	defaultZScore = 3
+	defaultWarmup = 30
+)
+
+// Config — настройки поиска аномалий, секция "anomaly" файла правил алертов
+type Config struct {
+	Enabled bool              `json:"enabled"`
+	Metrics []string          `json:"metrics,omitempty"` // шаблоны имён gauge в синтаксисе path.Match; пусто — все gauge
+	Alpha   float64           `json:"alpha"`             // вес нового значения в EWMA, от 0 до 1
+	ZScore  float64           `json:"z_score"`           // порог отклонения от среднего в стандартных отклонениях
+	Warmup  int               `json:"warmup"`            // сколько значений накопить, прежде чем искать аномалии
+	Labels  map[string]string `json:"labels,omitempty"`  // дополнительные метки алертов об аномалиях
+}
+
+// LoadConfig читает секцию "anomaly" из файла правил алертов
+func LoadConfig(path string) (Config, error) {
+	var file struct {
+		Anomaly Config `json:"anomaly"`
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return file.Anomaly, err
+	}
+	defer f.Close()
+
+	if err := json.NewDecoder(f).Decode(&file); err != nil {
+		return file.Anomaly, err
+	}
+	return file.Anomaly, file.Anomaly.validate()
+}
+
+// validate проверяет заданные параметры
+func (cfg Config) validate() error {
+	if cfg.Alpha < 0 || cfg.Alpha >= 1 {
+		return errors.New("anomaly: alpha should be in [0, 1)")
+	}
+	if cfg.ZScore < 0 {
+		return errors.New("anomaly: z_score should be positive")
+	}
+	if cfg.Warmup < 0 {
+		return errors.New("anomaly: warmup should be positive")
+	}
+	return nil
+}
+
+// withDefaults заполняет незаданные параметры значениями по умолчанию
+func (cfg Config) withDefaults() Config {
+	if cfg.Alpha == 0 {
+		cfg.Alpha = defaultAlpha
+	}
+	if cfg.ZScore == 0 {
+		cfg.ZScore = defaultZScore
+	}
+	if cfg.Warmup == 0 {
+		cfg.Warmup = defaultWarmup
+	}
+	return cfg
+}
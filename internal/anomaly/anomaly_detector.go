@@ -0,0 +1,234 @@
+package anomaly
+
+import (
+	"fmt"
+	"math"
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/RomanenkoDR/metrics/internal/alerts"
+	"github.com/RomanenkoDR/metrics/internal/middleware/logger"
+	"github.com/RomanenkoDR/metrics/internal/storage"
+	"go.uber.org/zap"
+)
+
+// source — имя детектора в алертах
+const source = "anomaly"
+
+// endedKept — сколько завершившихся аномалий хранится для API
+const endedKept = 100
+
+// Состояния аномалии
+const (
+	StateActive = "active"
+	StateEnded  = "ended"
+)
+
+// Anomaly — период, в течение которого значение gauge выходило за порог z-оценки
+type Anomaly struct {
+	Metric    string     `json:"metric"`
+	State     string     `json:"state"`
+	Value     float64    `json:"value"`   // последнее аномальное значение
+	Mean      float64    `json:"mean"`    // среднее до этого значения
+	StdDev    float64    `json:"stddev"`  // стандартное отклонение до этого значения
+	ZScore    float64    `json:"z_score"` // отклонение от среднего в стандартных отклонениях
+	StartedAt time.Time  `json:"started_at"`
+	EndedAt   *time.Time `json:"ended_at,omitempty"`
+}
+
+// stats — скользящая статистика одной метрики
+type stats struct {
+	mean     float64
+	variance float64
+	count    int
+	last     time.Time // время последнего учтённого значения
+}
+
+// observe возвращает z-оценку значения относительно накопленной статистики и учитывает его.
+// judged равен false, пока статистика не накоплена или дисперсия нулевая.
+func (s *stats) observe(x float64, alpha float64, warmup int) (z, mean, std float64, judged bool) {
+	mean, std = s.mean, math.Sqrt(s.variance)
+	if s.count >= warmup && std > 0 {
+		z = (x - mean) / std
+		judged = true
+	}
+
+	if s.count == 0 {
+		s.mean = x
+	} else {
+		diff := x - s.mean
+		incr := alpha * diff
+		s.mean += incr
+		s.variance = (1 - alpha) * (s.variance + diff*incr)
+	}
+	s.count++
+	return z, mean, std, judged
+}
+
+// Detector ведёт статистику по gauge и реализует alerts.Detector
+type Detector struct {
+	cfg   Config
+	store storage.MemStorage
+
+	mu     sync.RWMutex
+	stats  map[string]*stats
+	active map[string]*Anomaly
+	ended  []Anomaly
+}
+
+// NewDetector создаёт детектор аномалий над хранилищем метрик
+func NewDetector(cfg Config, store storage.MemStorage) *Detector {
+	return &Detector{
+		cfg:    cfg.withDefaults(),
+		store:  store,
+		stats:  map[string]*stats{},
+		active: map[string]*Anomaly{},
+	}
+}
+
+// Name возвращает имя детектора, которым помечаются его алерты
+func (d *Detector) Name() string {
+	return source
+}
+
+// Detect учитывает значения gauge, поступившие с прошлого вызова, и возвращает текущие аномалии
+func (d *Detector) Detect(now time.Time) []alerts.Event {
+	for name := range d.store.GetAllGauge() {
+		if d.match(name) {
+			d.observe(name, d.store.History(storage.GaugeType, name))
+		}
+	}
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	events := make([]alerts.Event, 0, len(d.active))
+	for _, a := range d.active {
+		events = append(events, d.event(a))
+	}
+	return events
+}
+
+// match проверяет, что метрика попадает под шаблоны из конфигурации
+func (d *Detector) match(name string) bool {
+	if len(d.cfg.Metrics) == 0 {
+		return true
+	}
+	for _, pattern := range d.cfg.Metrics {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// observe учитывает новые точки истории метрики и обновляет состояние её аномалии
+func (d *Detector) observe(name string, points []storage.Point) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	st, ok := d.stats[name]
+	if !ok {
+		st = &stats{}
+		d.stats[name] = st
+	}
+
+	for _, p := range points {
+		if !p.Time.After(st.last) {
+			continue
+		}
+		st.last = p.Time
+
+		z, mean, std, judged := st.observe(p.Value, d.cfg.Alpha, d.cfg.Warmup)
+		if !judged {
+			continue
+		}
+
+		a, exists := d.active[name]
+		if math.Abs(z) > d.cfg.ZScore {
+			if !exists {
+				a = &Anomaly{Metric: name, State: StateActive, StartedAt: p.Time}
+				d.active[name] = a
+				logger.Warn("Обнаружена аномалия", zap.String("metric", name), zap.Float64("value", p.Value), zap.Float64("z_score", z))
+			}
+			a.Value, a.Mean, a.StdDev, a.ZScore = p.Value, mean, std, z
+			continue
+		}
+
+		if exists {
+			endedAt := p.Time
+			a.State = StateEnded
+			a.EndedAt = &endedAt
+			delete(d.active, name)
+			d.ended = append(d.ended, *a)
+			if len(d.ended) > endedKept {
+				d.ended = d.ended[len(d.ended)-endedKept:]
+			}
+			logger.Info("Аномалия завершилась", zap.String("metric", name))
+		}
+	}
+}
+
+// event описывает аномалию для движка алертов
+func (d *Detector) event(a *Anomaly) alerts.Event {
+	labels := map[string]string{
+		"alertname": "GaugeAnomaly",
+		"metric":    a.Metric,
+	}
+	for k, v := range d.cfg.Labels {
+		labels[k] = v
+	}
+
+	return alerts.Event{
+		Name:    source + ":" + a.Metric,
+		Expr:    fmt.Sprintf("gauge %s |z| > %g", a.Metric, d.cfg.ZScore),
+		Metric:  a.Metric,
+		Value:   a.Value,
+		Labels:  labels,
+		Summary: fmt.Sprintf("value %g deviates from mean %g by %.1f standard deviations", a.Value, a.Mean, a.ZScore),
+	}
+}
+
+// Forget забывает статистику удалённых gauge и завершает их аномалии, чтобы вновь созданная
+// метрика с тем же именем начинала накапливать статистику заново. Нулевой *Detector ничего не делает.
+func (d *Detector) Forget(gauges []string) {
+	if d == nil {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now()
+	for _, name := range gauges {
+		delete(d.stats, name)
+		if a, ok := d.active[name]; ok {
+			a.State = StateEnded
+			a.EndedAt = &now
+			delete(d.active, name)
+			d.ended = append(d.ended, *a)
+		}
+	}
+	if len(d.ended) > endedKept {
+		d.ended = d.ended[len(d.ended)-endedKept:]
+	}
+}
+
+// Anomalies возвращает текущие аномалии по имени метрики и затем завершившиеся, новые первыми
+func (d *Detector) Anomalies() []Anomaly {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	res := make([]Anomaly, 0, len(d.active)+len(d.ended))
+	for _, a := range d.active {
+		res = append(res, *a)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Metric < res[j].Metric })
+
+	for i := len(d.ended) - 1; i >= 0; i-- {
+		res = append(res, d.ended[i])
+	}
+	return res
+}
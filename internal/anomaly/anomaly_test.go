@@ -0,0 +1,121 @@
+package anomaly
+
+import (
+	"testing"
+	"time"
+
+	"github.com/RomanenkoDR/metrics/internal/alerts"
+	"github.com/RomanenkoDR/metrics/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// series строит точки с шагом в секунду
+func series(start time.Time, values ...float64) []storage.Point {
+	points := make([]storage.Point, len(values))
+	for i, v := range values {
+		points[i] = storage.Point{Time: start.Add(time.Duration(i) * time.Second), Value: v}
+	}
+	return points
+}
+
+func TestDetectorObserve(t *testing.T) {
+	d := NewDetector(Config{Warmup: 10}, storage.New())
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// Колебания около 100 задают статистику
+	var values []float64
+	for i := 0; i < 20; i++ {
+		values = append(values, 100+float64(i%2)*2-1)
+	}
+	points := series(start, values...)
+	d.observe("HeapAlloc", points)
+	assert.Empty(t, d.Anomalies())
+
+	// Уже учтённые точки повторно не обрабатываются
+	d.observe("HeapAlloc", points)
+	assert.Equal(t, 20, d.stats["HeapAlloc"].count)
+
+	// Выброс
+	spike := series(start.Add(20*time.Second), 150)
+	d.observe("HeapAlloc", spike)
+	list := d.Anomalies()
+	require.Len(t, list, 1)
+	assert.Equal(t, StateActive, list[0].State)
+	assert.Equal(t, 150.0, list[0].Value)
+	assert.Greater(t, list[0].ZScore, 3.0)
+
+	// Возврат к норме завершает аномалию
+	d.observe("HeapAlloc", series(start.Add(21*time.Second), 100))
+	list = d.Anomalies()
+	require.Len(t, list, 1)
+	assert.Equal(t, StateEnded, list[0].State)
+	require.NotNil(t, list[0].EndedAt)
+}
+
+func TestDetectorForget(t *testing.T) {
+	d := NewDetector(Config{Warmup: 10}, storage.New())
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	var values []float64
+	for i := 0; i < 20; i++ {
+		values = append(values, 100+float64(i%2)*2-1)
+	}
+	d.observe("HeapAlloc", series(start, append(values, 150)...))
+	require.Len(t, d.Anomalies(), 1)
+
+	// Удалённая метрика теряет статистику, её аномалия завершается
+	d.Forget([]string{"HeapAlloc"})
+	assert.NotContains(t, d.stats, "HeapAlloc")
+	list := d.Anomalies()
+	require.Len(t, list, 1)
+	assert.Equal(t, StateEnded, list[0].State)
+
+	var nilDetector *Detector
+	nilDetector.Forget([]string{"HeapAlloc"})
+}
+
+func TestDetectorWarmup(t *testing.T) {
+	d := NewDetector(Config{Warmup: 10}, storage.New())
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	d.observe("HeapAlloc", series(start, 1, 2, 1, 1000))
+	assert.Empty(t, d.Anomalies(), "no anomalies before warmup")
+}
+
+func TestDetectorMatch(t *testing.T) {
+	d := NewDetector(Config{Metrics: []string{"Heap*", "GCCPUFraction"}}, storage.New())
+	assert.True(t, d.match("HeapAlloc"))
+	assert.True(t, d.match("GCCPUFraction"))
+	assert.False(t, d.match("Alloc"))
+}
+
+func TestDetectorEngine(t *testing.T) {
+	store := storage.New()
+	d := NewDetector(Config{Warmup: 10, Labels: map[string]string{"severity": "warning"}}, store)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	var values []float64
+	for i := 0; i < 20; i++ {
+		values = append(values, 100+float64(i%2)*2-1)
+	}
+	d.observe("HeapAlloc", series(start, append(values, 150)...))
+
+	e := alerts.NewEngine(alerts.Config{}, store, nil)
+	e.AddDetector(d)
+	e.Evaluate()
+
+	active := e.Active()
+	require.Len(t, active, 1)
+	assert.Equal(t, "anomaly:HeapAlloc", active[0].Rule)
+	assert.Equal(t, "anomaly", active[0].Source)
+	assert.Equal(t, alerts.StateFiring, active[0].State)
+	assert.Equal(t, "warning", active[0].Labels["severity"])
+
+	d.observe("HeapAlloc", series(start.Add(21*time.Second), 100))
+	e.Evaluate()
+	assert.Empty(t, e.Active())
+	all := e.All()
+	require.Len(t, all, 1)
+	assert.Equal(t, alerts.StateResolved, all[0].State)
+}
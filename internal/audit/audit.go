@@ -0,0 +1,316 @@
+// Package audit ведёт журнал изменений: запись метрик и административные действия. Журнал —
+// файл JSON-записей по одной на строку, только для дозаписи. Каждая запись содержит хеш
+// предыдущей, поэтому изменение или удаление записи в середине журнала обнаруживает Verify.
+// Удаление записей с конца цепочка не выявляет; для этого сверяют голову журнала (Head)
+// с последним известным значением, например из журнала сервера при запуске.
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Entry — запись журнала
+type Entry struct {
+	Seq     uint64    `json:"seq"`              // номер записи, начиная с 1
+	Time    time.Time `json:"time"`             // время запроса в UTC
+	Actor   string    `json:"actor"`            // субъект токена доступа или идентификатор клиента
+	Client  string    `json:"client"`           // идентификатор клиента: сертификат, агент или адрес
+	Tenant  string    `json:"tenant,omitempty"` // арендатор из X-Tenant-ID
+	Method  string    `json:"method"`
+	Route   string    `json:"route"` // шаблон маршрута, например /update/{type}/{metric}/{value}
+	Path    string    `json:"path"`
+	Query   string    `json:"query,omitempty"`
+	Status  int       `json:"status"`
+	Metrics int       `json:"metrics"`         // сколько метрик изменено
+	Admin   bool      `json:"admin,omitempty"` // административное действие
+	Prev    string    `json:"prev"`            // хеш предыдущей записи, пустой у первой
+	Hash    string    `json:"hash"`            // SHA-256 записи с пустым полем hash
+}
+
+// Head — последняя запись журнала
+type Head struct {
+	Seq  uint64 `json:"seq"`
+	Hash string `json:"hash"`
+}
+
+// TamperError сообщает, в какой строке журнала нарушена цепочка
+type TamperError struct {
+	Line   int
+	Reason string
+}
+
+func (e *TamperError) Error() string {
+	return fmt.Sprintf("audit: line %d: %s", e.Line, e.Reason)
+}
+
+// maxLine — наибольшая длина записи журнала
+const maxLine = 1 << 20
+
+// Log — журнал аудита. Методы безопасны для одновременного использования.
+type Log struct {
+	path string
+
+	mu   sync.Mutex
+	file *os.File
+	head Head
+	size int64
+	torn int64
+	err  error // ошибка последней записи, сбрасывается следующей удачной
+}
+
+// Open открывает журнал для дозаписи, создавая файл при необходимости. Неполная последняя
+// строка, оставшаяся от прерванной записи, отрезается. Остальной журнал проверяется целиком:
+// продолжать повреждённую цепочку нельзя, такой файл нужно сохранить для разбора и начать новый.
+func Open(path string) (*Log, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	size, torn, err := trimTorn(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	head, err := Verify(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &Log{path: path, file: file, head: head, size: size, torn: torn}, nil
+}
+
+// trimTorn отрезает от файла всё после последнего перевода строки. Append сообщает об успехе
+// только после того, как строка записана целиком и сброшена на диск, поэтому запись без
+// перевода строки никто не считал принятой. Возвращает новый размер файла и число отрезанных байт.
+func trimTorn(file *os.File) (size, torn int64, err error) {
+	info, err := file.Stat()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// Ищем последний перевод строки, читая файл с конца
+	buf := make([]byte, 4<<10)
+	end := info.Size()
+	for end > 0 {
+		n := min(int64(len(buf)), end)
+		if _, err := file.ReadAt(buf[:n], end-n); err != nil {
+			return 0, 0, err
+		}
+		if i := bytes.LastIndexByte(buf[:n], '\n'); i >= 0 {
+			end -= n - int64(i) - 1
+			break
+		}
+		end -= n
+	}
+	if end == info.Size() {
+		return end, 0, nil
+	}
+
+	if err := file.Truncate(end); err != nil {
+		return 0, 0, err
+	}
+	if err := file.Sync(); err != nil {
+		return 0, 0, err
+	}
+	return end, info.Size() - end, nil
+}
+
+// Append дописывает запись, заполняя номер, хеш предыдущей записи и свой хеш.
+// Запись считается принятой, только когда она сброшена на диск.
+func (l *Log) Append(e Entry) (Entry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// После неудачной записи в файле может остаться неполная строка
+	if l.err != nil {
+		if err := l.file.Truncate(l.size); err != nil {
+			l.err = err
+			return e, err
+		}
+	}
+
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	e.Time = e.Time.UTC()
+	e.Seq = l.head.Seq + 1
+	e.Prev = l.head.Hash
+	hash, err := e.sum()
+	if err != nil {
+		return e, err
+	}
+	e.Hash = hash
+
+	line, err := json.Marshal(e)
+	if err != nil {
+		return e, err
+	}
+	line = append(line, '\n')
+	_, err = l.file.Write(line)
+	if err == nil {
+		err = l.file.Sync()
+	}
+	if err != nil {
+		l.err = err
+		return e, err
+	}
+
+	l.err = nil
+	l.head = Head{Seq: e.Seq, Hash: e.Hash}
+	l.size += int64(len(line))
+	return e, nil
+}
+
+// Head возвращает последнюю запись журнала
+func (l *Log) Head() Head {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.head
+}
+
+// Err возвращает ошибку последней записи или nil, если она удалась. Пока ошибка не сброшена
+// удачной записью, Middleware отклоняет изменяющие запросы.
+func (l *Log) Err() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.err
+}
+
+// Torn возвращает, сколько байт неполной последней строки отрезано при открытии журнала
+func (l *Log) Torn() int64 {
+	return l.torn
+}
+
+// Close закрывает файл журнала
+func (l *Log) Close() error {
+	return l.file.Close()
+}
+
+// Filter — условия выборки записей. Пустые поля не ограничивают выборку.
+type Filter struct {
+	From, To time.Time
+	Actor    string // совпадает с полем actor или client
+	Limit    int    // сколько последних подходящих записей вернуть, по умолчанию DefaultLimit
+}
+
+// DefaultLimit — сколько записей возвращает Query, если Limit не задан
+const DefaultLimit = 100
+
+func (f Filter) match(e Entry) bool {
+	if !f.From.IsZero() && e.Time.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && !e.Time.Before(f.To) {
+		return false
+	}
+	return f.Actor == "" || e.Actor == f.Actor || e.Client == f.Actor
+}
+
+// Query возвращает последние записи, подходящие под фильтр, в порядке записи
+func (l *Log) Query(f Filter) ([]Entry, error) {
+	if f.Limit <= 0 {
+		f.Limit = DefaultLimit
+	}
+
+	// Читаем только записи, дописанные к моменту запроса, чтобы не встретить неполную строку
+	l.mu.Lock()
+	size := l.size
+	l.mu.Unlock()
+
+	file, err := os.Open(l.path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	entries := []Entry{}
+	err = scan(io.LimitReader(file, size), func(_ int, line []byte) error {
+		var e Entry
+		if err := json.Unmarshal(line, &e); err != nil {
+			return err
+		}
+		if f.match(e) {
+			entries = append(entries, e)
+			if len(entries) > f.Limit {
+				entries = entries[1:]
+			}
+		}
+		return nil
+	})
+	return entries, err
+}
+
+// Verify проверяет журнал: номера записей идут подряд, каждая ссылается на хеш предыдущей,
+// а хеш записи совпадает с её содержимым. Возвращает последнюю запись журнала.
+func Verify(r io.Reader) (Head, error) {
+	var head Head
+	err := scan(r, func(n int, line []byte) error {
+		var e Entry
+		if err := json.Unmarshal(line, &e); err != nil {
+			return &TamperError{Line: n, Reason: "malformed entry: " + err.Error()}
+		}
+		if e.Seq != head.Seq+1 {
+			return &TamperError{Line: n, Reason: fmt.Sprintf("seq %d, expected %d", e.Seq, head.Seq+1)}
+		}
+		if e.Prev != head.Hash {
+			return &TamperError{Line: n, Reason: "previous hash doesn't match"}
+		}
+		hash, err := e.sum()
+		if err != nil {
+			return err
+		}
+		if hash != e.Hash {
+			return &TamperError{Line: n, Reason: "entry hash doesn't match"}
+		}
+		// Поля, которых нет в Entry, не входят в хеш, поэтому запись должна совпадать с каноничной
+		canonical, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(canonical, line) {
+			return &TamperError{Line: n, Reason: "entry is not canonical"}
+		}
+		head = Head{Seq: e.Seq, Hash: e.Hash}
+		return nil
+	})
+	return head, err
+}
+
+// sum вычисляет хеш записи с пустым полем hash
+func (e Entry) sum() (string, error) {
+	e.Hash = ""
+	data, err := json.Marshal(e)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// scan вызывает fn для каждой строки журнала с её номером
+func scan(r io.Reader, fn func(n int, line []byte) error) error {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64<<10), maxLine)
+	for n := 1; sc.Scan(); n++ {
+		if err := fn(n, sc.Bytes()); err != nil {
+			return err
+		}
+	}
+	if err := sc.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return fmt.Errorf("audit: entry is longer than %d bytes", maxLine)
+		}
+		return err
+	}
+	return nil
+}
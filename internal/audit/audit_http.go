@@ -0,0 +1,108 @@
+package audit
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/RomanenkoDR/metrics/internal/identity"
+	"github.com/RomanenkoDR/metrics/internal/middleware/auth"
+	"github.com/RomanenkoDR/metrics/internal/middleware/logger"
+	"github.com/RomanenkoDR/metrics/internal/problem"
+	"github.com/RomanenkoDR/metrics/internal/telemetry"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+// record — сведения, которые обработчик сообщает о запросе
+type record struct {
+	metrics int
+}
+
+type recordKey struct{}
+
+// Touch сообщает журналу, сколько метрик изменил запрос. Вне журналируемого запроса ничего не делает.
+func Touch(r *http.Request, metrics int) {
+	if rec, ok := r.Context().Value(recordKey{}).(*record); ok {
+		rec.metrics += metrics
+	}
+}
+
+// Middleware записывает в журнал запросы, изменяющие данные: все, кроме GET, HEAD и OPTIONS.
+// Middleware подключается до проверки токена и подсети, чтобы в журнал попадали и отказы
+// 401 и 403; субъект принятого токена журнал узнаёт через auth.Track.
+// Пока последняя запись в журнал не удалась, изменяющие запросы отклоняются с кодом 503:
+// изменение, которое нельзя записать, не выполняется. Отказ тоже записывается в журнал,
+// и первая удачная запись снова открывает доступ.
+// Нулевой *Log пропускает запросы без записи.
+func (l *Log) Middleware(next http.Handler) http.Handler {
+	if l == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		if err := l.Err(); err != nil {
+			problem.Write(sw, r, http.StatusServiceUnavailable, problem.CodeAuditUnavailable, "audit log is unavailable")
+			l.record(r, sw.status, "", 0)
+			return
+		}
+
+		rec := &record{}
+		r = r.WithContext(context.WithValue(r.Context(), recordKey{}, rec))
+		r, tracked := auth.Track(r)
+		next.ServeHTTP(sw, r)
+
+		actor := ""
+		if claims := tracked(); claims != nil {
+			actor = claims.Subject
+		}
+		l.record(r, sw.status, actor, rec.metrics)
+	})
+}
+
+// record дописывает запрос в журнал. Пустой actor заменяется идентификатором клиента.
+func (l *Log) record(r *http.Request, status int, actor string, metrics int) {
+	client := identity.Of(r, identity.SourceCert, identity.SourceAgent, identity.SourceIP)
+	if actor == "" {
+		actor = client
+	}
+	route := r.URL.Path
+	if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+		route = rctx.RoutePattern()
+	}
+
+	_, err := l.Append(Entry{
+		Actor:   actor,
+		Client:  client,
+		Tenant:  strings.TrimSpace(r.Header.Get(identity.HeaderTenant)),
+		Method:  r.Method,
+		Route:   route,
+		Path:    r.URL.Path,
+		Query:   r.URL.RawQuery,
+		Status:  status,
+		Metrics: metrics,
+		Admin:   strings.HasPrefix(route, "/admin/"),
+	})
+	if err != nil {
+		telemetry.Default.Inc("audit_failures_total", nil)
+		logger.Error("Ошибка записи в журнал аудита", zap.String("path", r.URL.Path), zap.Error(err))
+	}
+}
+
+// statusWriter запоминает код ответа
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
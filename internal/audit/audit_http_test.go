@@ -0,0 +1,62 @@
+package audit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/RomanenkoDR/metrics/internal/problem"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMiddlewareFailClosed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	l, err := Open(path)
+	require.NoError(t, err)
+	defer l.Close()
+
+	served := 0
+	handler := l.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served++
+		Touch(r, 1)
+	}))
+	send := func(method string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(method, "/update/gauge/Alloc/1", nil))
+		return w
+	}
+
+	require.Equal(t, http.StatusOK, send(http.MethodPost).Code)
+	require.Equal(t, 1, served)
+
+	// Запись не удалась: изменения не принимаются, чтение продолжает работать
+	file := l.file
+	l.file, err = os.Open(path)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, send(http.MethodPost).Code, "change already applied when append failed")
+	require.Error(t, l.Err())
+
+	w := send(http.MethodPost)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Contains(t, w.Body.String(), string(problem.CodeAuditUnavailable))
+	assert.Equal(t, http.StatusOK, send(http.MethodGet).Code)
+	assert.Equal(t, 3, served)
+	l.file.Close()
+
+	// Отказ записывается в журнал, удачная запись снова открывает доступ
+	l.file = file
+	assert.Equal(t, http.StatusServiceUnavailable, send(http.MethodPost).Code)
+	require.NoError(t, l.Err())
+	assert.Equal(t, http.StatusOK, send(http.MethodPost).Code)
+	assert.Equal(t, 4, served)
+
+	entries, err := l.Query(Filter{})
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+	assert.Equal(t, http.StatusServiceUnavailable, entries[1].Status)
+	assert.Zero(t, entries[1].Metrics)
+	assert.Equal(t, 1, entries[2].Metrics)
+}
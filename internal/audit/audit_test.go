@@ -0,0 +1,181 @@
+package audit
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAppendVerify(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	l, err := Open(path)
+	require.NoError(t, err)
+
+	start := time.Date(2026, 1, 2, 10, 0, 0, 0, time.UTC)
+	for i, actor := range []string{"alice", "agent-1", "alice"} {
+		e, err := l.Append(Entry{Time: start.Add(time.Duration(i) * time.Minute), Actor: actor, Method: "POST", Route: "/updates/", Metrics: i})
+		require.NoError(t, err)
+		assert.Equal(t, uint64(i+1), e.Seq)
+	}
+	head := l.Head()
+	require.NoError(t, l.Close())
+
+	// Открытый заново журнал продолжает цепочку
+	l, err = Open(path)
+	require.NoError(t, err)
+	assert.Equal(t, head, l.Head())
+	e, err := l.Append(Entry{Time: start.Add(time.Hour), Actor: "bob", Route: "/admin/counters/reset", Admin: true})
+	require.NoError(t, err)
+	assert.Equal(t, head.Hash, e.Prev)
+	require.NoError(t, l.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	got, err := Verify(bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, uint64(4), got.Seq)
+}
+
+func TestVerifyTampering(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	l, err := Open(path)
+	require.NoError(t, err)
+	for _, actor := range []string{"alice", "bob", "carol"} {
+		_, err := l.Append(Entry{Actor: actor, Route: "/admin/counters/reset"})
+		require.NoError(t, err)
+	}
+	require.NoError(t, l.Close())
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	lines := strings.SplitAfter(strings.TrimSuffix(string(data), "\n"), "\n")
+
+	tests := []struct {
+		name string
+		log  string
+		line int
+	}{
+		{"Changed actor", lines[0] + strings.Replace(lines[1], `"bob"`, `"mallory"`, 1) + lines[2], 2},
+		{"Removed entry", lines[0] + lines[2], 2},
+		{"Swapped entries", lines[1] + lines[0] + lines[2], 1},
+		{"Extra field", lines[0] + strings.Replace(lines[1], `{"seq"`, `{"note":"x","seq"`, 1) + lines[2], 2},
+		{"Not JSON", lines[0] + "garbage\n", 2},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Verify(strings.NewReader(tc.log))
+			var tamper *TamperError
+			require.ErrorAs(t, err, &tamper)
+			assert.Equal(t, tc.line, tamper.Line)
+		})
+	}
+
+	// Повреждённый журнал не открывается для дозаписи
+	require.NoError(t, os.WriteFile(path, []byte(lines[0]+lines[2]+"\n"), 0o600))
+	_, err = Open(path)
+	assert.Error(t, err)
+}
+
+func TestQuery(t *testing.T) {
+	l, err := Open(filepath.Join(t.TempDir(), "audit.log"))
+	require.NoError(t, err)
+	defer l.Close()
+
+	start := time.Date(2026, 1, 2, 10, 0, 0, 0, time.UTC)
+	for i, actor := range []string{"alice", "agent-1", "alice", "alice"} {
+		_, err := l.Append(Entry{Time: start.Add(time.Duration(i) * time.Hour), Actor: actor, Client: "ip:10.0.0.1"})
+		require.NoError(t, err)
+	}
+
+	all, err := l.Query(Filter{})
+	require.NoError(t, err)
+	assert.Len(t, all, 4)
+
+	got, err := l.Query(Filter{Actor: "alice", From: start.Add(time.Hour), Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	assert.Equal(t, uint64(4), got[0].Seq, "latest matching entry")
+
+	got, err = l.Query(Filter{From: start.Add(time.Hour), To: start.Add(3 * time.Hour)})
+	require.NoError(t, err)
+	assert.Len(t, got, 2, "to is exclusive")
+
+	got, err = l.Query(Filter{Actor: "ip:10.0.0.1"})
+	require.NoError(t, err)
+	assert.Len(t, got, 4, "actor matches client identity too")
+}
+
+func TestOpenTornLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	l, err := Open(path)
+	require.NoError(t, err)
+	for _, actor := range []string{"alice", "bob"} {
+		_, err := l.Append(Entry{Actor: actor, Method: "POST", Route: "/updates/"})
+		require.NoError(t, err)
+	}
+	head := l.Head()
+	require.NoError(t, l.Close())
+
+	// Запись прервана на середине строки
+	torn := `{"seq":3,"time":"2026-01-02T10:00:00Z","act`
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+	_, err = file.WriteString(torn)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	l, err = Open(path)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(torn)), l.Torn())
+	assert.Equal(t, head, l.Head())
+	e, err := l.Append(Entry{Actor: "carol", Method: "POST", Route: "/updates/"})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), e.Seq)
+	require.NoError(t, l.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	got, err := Verify(bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), got.Seq)
+
+	// Журнал из одной неполной строки становится пустым
+	path = filepath.Join(t.TempDir(), "audit.log")
+	require.NoError(t, os.WriteFile(path, []byte(torn), 0o600))
+	l, err = Open(path)
+	require.NoError(t, err)
+	defer l.Close()
+	assert.Equal(t, int64(len(torn)), l.Torn())
+	assert.Zero(t, l.Head().Seq)
+}
+
+func TestAppendFailure(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	l, err := Open(path)
+	require.NoError(t, err)
+	defer l.Close()
+	_, err = l.Append(Entry{Actor: "alice"})
+	require.NoError(t, err)
+	head := l.Head()
+
+	// Файл, открытый только на чтение, не принимает записи
+	file := l.file
+	l.file, err = os.Open(path)
+	require.NoError(t, err)
+	_, err = l.Append(Entry{Actor: "bob"})
+	require.Error(t, err)
+	assert.Error(t, l.Err())
+	assert.Equal(t, head, l.Head(), "failed entry is not part of the chain")
+	l.file.Close()
+
+	l.file = file
+	e, err := l.Append(Entry{Actor: "bob"})
+	require.NoError(t, err)
+	assert.NoError(t, l.Err(), "successful append clears the error")
+	assert.Equal(t, uint64(2), e.Seq)
+}
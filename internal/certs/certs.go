@@ -0,0 +1,195 @@
+// Package certs загружает сертификаты TLS сервера и агента. Сертификат сервера и доверенные
+// центры сертификации клиентов можно перечитать без перезапуска: новые соединения получают
+// их сразу, установленные продолжают работать со старыми.
+package certs
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Config — файлы сертификатов сервера
+type Config struct {
+	CertFile string // сертификат сервера в PEM, может содержать цепочку
+	KeyFile  string // приватный ключ сертификата
+	// ClientCAFile — центры сертификации клиентов в PEM. Если задан, сервер требует от клиентов
+	// сертификат, подписанный одним из них (mTLS).
+	ClientCAFile string
+}
+
+// Store хранит текущий сертификат сервера. Методы безопасны для одновременного использования.
+type Store struct {
+	cfg Config
+
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+	stamp     string
+}
+
+// New загружает сертификаты
+func New(cfg Config) (*Store, error) {
+	if cfg.CertFile == "" || cfg.KeyFile == "" {
+		return nil, errors.New("certs: both certificate and key files are required")
+	}
+
+	s := &Store{cfg: cfg}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Reload перечитывает сертификаты. При ошибке продолжают действовать прежние.
+func (s *Store) Reload() error {
+	stamp := s.fileStamp()
+
+	cert, err := tls.LoadX509KeyPair(s.cfg.CertFile, s.cfg.KeyFile)
+	if err != nil {
+		return fmt.Errorf("certs: load certificate: %w", err)
+	}
+	var pool *x509.CertPool
+	if s.cfg.ClientCAFile != "" {
+		if pool, err = LoadPool(s.cfg.ClientCAFile); err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cert = &cert
+	s.clientCAs = pool
+	s.stamp = stamp
+	return nil
+}
+
+// MutualTLS сообщает, проверяет ли сервер сертификаты клиентов
+func (s *Store) MutualTLS() bool {
+	return s.cfg.ClientCAFile != ""
+}
+
+// Expires возвращает время окончания действия текущего сертификата
+func (s *Store) Expires() time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.cert.Leaf != nil {
+		return s.cert.Leaf.NotAfter
+	}
+	leaf, err := x509.ParseCertificate(s.cert.Certificate[0])
+	if err != nil {
+		return time.Time{}
+	}
+	return leaf.NotAfter
+}
+
+// TLSConfig возвращает настройки TLS сервера. Сертификат и центры сертификации клиентов
+// выбираются для каждого соединения, поэтому перезагрузка действует без перезапуска.
+func (s *Store) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			s.mu.RLock()
+			defer s.mu.RUnlock()
+
+			cfg := &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*s.cert},
+				NextProtos:   []string{"h2", "http/1.1"},
+			}
+			if s.clientCAs != nil {
+				cfg.ClientAuth = tls.RequireAndVerifyClientCert
+				cfg.ClientCAs = s.clientCAs
+			}
+			return cfg, nil
+		},
+	}
+}
+
+// Watch проверяет файлы сертификатов каждые interval и перечитывает их при изменении,
+// пока не завершён ctx. Результат каждой перезагрузки передаётся в reloaded.
+func (s *Store) Watch(ctx context.Context, interval time.Duration, reloaded func(error)) {
+	s.mu.RLock()
+	last := s.stamp
+	s.mu.RUnlock()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// Неудачная загрузка повторяется только после следующего изменения файлов,
+			// например когда записаны и сертификат, и ключ
+			if stamp := s.fileStamp(); stamp != last {
+				last = stamp
+				reloaded(s.Reload())
+			}
+		}
+	}
+}
+
+// fileStamp описывает состояние файлов сертификатов, чтобы заметить их изменение
+func (s *Store) fileStamp() string {
+	var b strings.Builder
+	for _, file := range []string{s.cfg.CertFile, s.cfg.KeyFile, s.cfg.ClientCAFile} {
+		if file == "" {
+			continue
+		}
+		b.WriteString(file)
+		if info, err := os.Stat(file); err == nil {
+			fmt.Fprintf(&b, ":%d:%d", info.Size(), info.ModTime().UnixNano())
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// LoadPool читает сертификаты центров сертификации из PEM-файла
+func LoadPool(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("certs: read CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("certs: no certificates in %s", path)
+	}
+	return pool, nil
+}
+
+// ClientConfig возвращает настройки TLS агента. Если задан caFile, сервер проверяется только
+// по этим центрам сертификации, а не по системным. Если заданы certFile и keyFile, агент
+// предъявляет серверу клиентский сертификат.
+func ClientConfig(caFile, certFile, keyFile string) (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if caFile != "" {
+		pool, err := LoadPool(caFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.RootCAs = pool
+	}
+
+	if (certFile == "") != (keyFile == "") {
+		return nil, errors.New("certs: both client certificate and key files are required")
+	}
+	if certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("certs: load client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
@@ -0,0 +1,190 @@
+package certs
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// issuer — тестовый центр сертификации
+type issuer struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newCA(t *testing.T) *issuer {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &issuer{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue выпускает сертификат и возвращает его и ключ в PEM
+func (ca *issuer) issue(t *testing.T, cn string, usage x509.ExtKeyUsage) ([]byte, []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+}
+
+func writeFile(t *testing.T, dir, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+	return path
+}
+
+// startServer запускает TLS-сервер с сертификатами из store, обработчик возвращает CN клиента
+func startServer(t *testing.T, store *Store) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.VerifiedChains) > 0 {
+			w.Write([]byte(r.TLS.VerifiedChains[0][0].Subject.CommonName))
+		}
+	}))
+	srv.TLS = store.TLSConfig()
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// get выполняет запрос новым соединением и возвращает CN сертификата сервера
+func get(cfg *tls.Config, url string) (string, error) {
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: cfg, DisableKeepAlives: true}}
+	resp, err := client.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	return resp.TLS.PeerCertificates[0].Subject.CommonName, nil
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newCA(t)
+	caFile := writeFile(t, dir, "ca.pem", ca.pem)
+	cert, key := ca.issue(t, "server", x509.ExtKeyUsageServerAuth)
+	clientCert, clientKey := ca.issue(t, "agent-1", x509.ExtKeyUsageClientAuth)
+	other := newCA(t)
+	otherCert, otherKey := other.issue(t, "intruder", x509.ExtKeyUsageClientAuth)
+
+	store, err := New(Config{
+		CertFile:     writeFile(t, dir, "server.pem", cert),
+		KeyFile:      writeFile(t, dir, "server.key", key),
+		ClientCAFile: caFile,
+	})
+	require.NoError(t, err)
+	assert.True(t, store.MutualTLS())
+	assert.WithinDuration(t, time.Now().Add(time.Hour), store.Expires(), time.Minute)
+	srv := startServer(t, store)
+
+	cfg, err := ClientConfig(caFile, writeFile(t, dir, "client.pem", clientCert), writeFile(t, dir, "client.key", clientKey))
+	require.NoError(t, err)
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: cfg}}
+	resp, err := client.Get(srv.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	noCert, err := ClientConfig(caFile, "", "")
+	require.NoError(t, err)
+	_, err = get(noCert, srv.URL)
+	assert.Error(t, err, "client without certificate is rejected")
+
+	foreign, err := ClientConfig(caFile, writeFile(t, dir, "other.pem", otherCert), writeFile(t, dir, "other.key", otherKey))
+	require.NoError(t, err)
+	_, err = get(foreign, srv.URL)
+	assert.Error(t, err, "certificate of unknown CA is rejected")
+
+	pinned, err := ClientConfig(writeFile(t, dir, "other-ca.pem", other.pem), "", "")
+	require.NoError(t, err)
+	_, err = get(pinned, srv.URL)
+	assert.Error(t, err, "server is verified by pinned CA only")
+}
+
+func TestReload(t *testing.T) {
+	dir := t.TempDir()
+	ca := newCA(t)
+	caFile := writeFile(t, dir, "ca.pem", ca.pem)
+	cert, key := ca.issue(t, "old", x509.ExtKeyUsageServerAuth)
+	certFile, keyFile := writeFile(t, dir, "server.pem", cert), writeFile(t, dir, "server.key", key)
+
+	store, err := New(Config{CertFile: certFile, KeyFile: keyFile})
+	require.NoError(t, err)
+	assert.False(t, store.MutualTLS())
+	srv := startServer(t, store)
+
+	cfg, err := ClientConfig(caFile, "", "")
+	require.NoError(t, err)
+	cn, err := get(cfg, srv.URL)
+	require.NoError(t, err)
+	assert.Equal(t, "old", cn)
+
+	// Сертификат без парного ключа не загружается, действует прежний
+	cert, key = ca.issue(t, "new", x509.ExtKeyUsageServerAuth)
+	writeFile(t, dir, "server.pem", cert)
+	assert.Error(t, store.Reload())
+	cn, err = get(cfg, srv.URL)
+	require.NoError(t, err)
+	assert.Equal(t, "old", cn)
+
+	writeFile(t, dir, "server.key", key)
+	require.NoError(t, store.Reload())
+	cn, err = get(cfg, srv.URL)
+	require.NoError(t, err)
+	assert.Equal(t, "new", cn, "new connections get reloaded certificate")
+}
+
+func TestClientConfigRequiresPair(t *testing.T) {
+	_, err := ClientConfig("", "client.pem", "")
+	assert.Error(t, err)
+
+	cfg, err := ClientConfig("", "", "")
+	require.NoError(t, err)
+	assert.Nil(t, cfg.RootCAs, "system roots are used without CA")
+}
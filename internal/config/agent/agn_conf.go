@@ -15,6 +15,11 @@ type Options struct {
 	Key            string `env:"KEY"`
 	CryptoKey      string `env:"CRYPTO_KEY"`
 	Config         string `env:"CONFIG"`
+	AgentID        string `env:"AGENT_ID"`
+	TLSCA          string `env:"TLS_CA"`
+	TLSCert        string `env:"TLS_CERT"`
+	TLSKey         string `env:"TLS_KEY"`
+	Token          string `env:"TOKEN"`
 }
 
 func ParseOptions() (Options, error) {
@@ -29,14 +34,25 @@ func ParseOptions() (Options, error) {
 	// Чтение параметра командной строки для адреса сервера (по умолчанию "localhost:8080")
 	flag.StringVar(&opt.ServerAddress, "a", "localhost:8080", "Address of the server to send metrics")
 
-	// Чтение параметра командной строки для установки JWT токена
-	flag.StringVar(&opt.Key, "k", "", "Token auth by JWT")
+	// Чтение параметра командной строки для ключа подписи запросов HMAC-SHA256
+	flag.StringVar(&opt.Key, "k", "", "Key to sign requests and verify responses with HMAC-SHA256")
 
 	// Чтение параметра командной строки для пути к файлу с публичным ключом
 	flag.StringVar(&opt.CryptoKey, "crypto-key", "", "Path to the public key for encryption")
 
 	flag.StringVar(&opt.Config, "c", "", "Path to config file")
 
+	// Чтение параметра командной строки для идентификатора агента (по умолчанию имя хоста)
+	flag.StringVar(&opt.AgentID, "id", "", "Agent ID sent to the server in X-Agent-ID header, hostname if empty")
+
+	// Чтение параметров командной строки для TLS: доверенный центр сертификации сервера и клиентский сертификат
+	flag.StringVar(&opt.TLSCA, "tls-ca", "", "Path to CA certificate in PEM to verify the server instead of system roots, enables https")
+	flag.StringVar(&opt.TLSCert, "tls-cert", "", "Path to client TLS certificate in PEM for mutual TLS, enables https")
+	flag.StringVar(&opt.TLSKey, "tls-key", "", "Path to client TLS private key in PEM")
+
+	// Чтение параметра командной строки для токена доступа JWT, если сервер проверяет токены
+	flag.StringVar(&opt.Token, "token", "", "Bearer JWT with write scope for servers with token auth")
+
 	// Парсинг аргументов командной строки
 	flag.Parse()
 
@@ -77,6 +93,22 @@ func ParseOptions() (Options, error) {
 		if opt.CryptoKey == "" {
 			opt.CryptoKey = cfg.CryptoKey
 		}
+		if opt.TLSCA == "" {
+			opt.TLSCA = cfg.TLSCA
+		}
+		if opt.TLSCert == "" {
+			opt.TLSCert = cfg.TLSCert
+		}
+		if opt.TLSKey == "" {
+			opt.TLSKey = cfg.TLSKey
+		}
+		if opt.Token == "" {
+			opt.Token = cfg.Token
+		}
+	}
+
+	if opt.AgentID == "" {
+		opt.AgentID, _ = os.Hostname()
 	}
 
 	// Возвращаем структуру с параметрами и nil
@@ -12,6 +12,10 @@ type agentConfFromFile struct {
 	ReportInterval time.Duration `json:"report_interval"` // Интервал отправки метрик на сервер
 	PollInterval   time.Duration `json:"poll_interval"`   // Интервал сбора метрик
 	CryptoKey      string        `json:"crypto_key"`      // Путь к публичному ключу для шифрования
+	TLSCA          string        `json:"tls_ca"`          // Центр сертификации для проверки сервера
+	TLSCert        string        `json:"tls_cert"`        // Клиентский сертификат TLS
+	TLSKey         string        `json:"tls_key"`         // Приватный ключ клиентского сертификата
+	Token          string        `json:"token"`           // Токен доступа JWT
 }
 
 // loadConfigFromFile загружает конфигурацию агента из JSON-файла
@@ -0,0 +1,22 @@
+package agent
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/RomanenkoDR/metrics/internal/identity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSendRequestRealIP(t *testing.T) {
+	var realIP string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		realIP = r.Header.Get(identity.HeaderRealIP)
+	}))
+	defer srv.Close()
+
+	require.NoError(t, sendRequest(srv.URL+"/updates/", []byte(`[]`), ""))
+	assert.Equal(t, "127.0.0.1", realIP, "address of the interface used to reach the server")
+}
@@ -3,60 +3,66 @@ package agent
 import (
 	"bytes"
 	"context"
-	"crypto/rand"
 	"encoding/json"
 	"fmt"
 	"github.com/RomanenkoDR/metrics/internal/crypto"
+	"github.com/RomanenkoDR/metrics/internal/identity"
 	"github.com/RomanenkoDR/metrics/internal/middleware/logger"
+	"github.com/RomanenkoDR/metrics/internal/middleware/token"
+	"github.com/RomanenkoDR/metrics/internal/problem"
 	"github.com/RomanenkoDR/metrics/internal/storage"
 	"go.uber.org/zap"
-	"io"
+	"net"
 	"net/http"
+	"net/url"
 	"strings"
+	"time"
 )
 
+// Client отправляет запросы на сервер. Run настраивает его TLS, если заданы -tls-ca или клиентский сертификат.
+var Client = &http.Client{}
+
+// Scheme — схема, с которой агент обращается к серверу, если в адресе -a она не указана
+var Scheme = "http"
+
+// endpoint возвращает URL обработчика path на сервере. Адрес может быть указан со схемой http:// или https://.
+func endpoint(serverAddress, path string) string {
+	if strings.HasPrefix(serverAddress, "http://") || strings.HasPrefix(serverAddress, "https://") {
+		return strings.TrimSuffix(serverAddress, "/") + "/" + path
+	}
+	return Scheme + "://" + serverAddress + "/" + path
+}
+
 // sendRequest - вспомогательная функция для отправки HTTP-запроса на сервер
 func sendRequest(serverAddress string, data []byte, cryptoKeyPath string) error {
 	// Если ключа нет — отправляем данные без шифрования
 	if cryptoKeyPath == "" {
 		logger.Warn("Публичный ключ RSA не передан, отправка данных без шифрования")
 	} else {
-		// Генерируем случайный AES-ключ
-		aesKey := make([]byte, 32)
-		_, err := rand.Read(aesKey)
-		if err != nil {
-			logger.Error("Ошибка генерации AES-ключа", zap.Error(err))
-			return err
-		}
-
-		// Шифруем данные с помощью AES
-		encryptedData, err := crypto.EncryptAES(data, aesKey)
+		pub, err := crypto.LoadPublicKey(cryptoKeyPath)
 		if err != nil {
-			logger.Error("Ошибка шифрования данных AES", zap.Error(err))
+			logger.Error("Ошибка загрузки публичного ключа RSA", zap.Error(err))
 			return err
 		}
 
-		// Шифруем AES-ключ с помощью RSA
-		encryptedAESKey, err := crypto.EncryptRSA(aesKey, cryptoKeyPath)
+		// Путь запроса входит в дополнительные данные конверта, сервер проверит его при расшифровке
+		target, err := url.Parse(serverAddress)
 		if err != nil {
-			logger.Error("Ошибка шифрования AES-ключа RSA", zap.Error(err))
+			logger.Error("Ошибка разбора адреса сервера", zap.Error(err))
 			return err
 		}
-
-		// Создаём JSON-объект для отправки
-		payload := map[string][]byte{
-			"data": encryptedData,
-			"key":  encryptedAESKey,
+		path := target.Path
+		if path == "" {
+			path = "/"
 		}
 
-		// Сериализуем зашифрованные данные в JSON
-		data, err = json.Marshal(payload)
+		data, err = crypto.Seal(data, pub, path, time.Now())
 		if err != nil {
-			logger.Error("Ошибка сериализации зашифрованных данных", zap.Error(err))
+			logger.Error("Ошибка шифрования данных", zap.Error(err))
 			return err
 		}
 
-		logger.Info("Данные зашифрованы с использованием AES + RSA")
+		logger.Info("Данные зашифрованы конвертом AES-256-GCM + RSA-OAEP")
 	}
 
 	// Создаём HTTP-запрос
@@ -68,27 +74,71 @@ func sendRequest(serverAddress string, data []byte, cryptoKeyPath string) error
 
 	// Устанавливаем заголовки
 	request.Header.Set("Content-Type", "application/json")
+	// Подписываем ровно те байты, которые уходят в теле запроса, то есть уже зашифрованные,
+	// вместе с меткой времени, nonce и путём, чтобы сервер мог отклонить повтор запроса
+	if Encrypt {
+		if err := token.SignRequest(request, string(Key), data); err != nil {
+			logger.Error("Ошибка подписи запроса", zap.Error(err))
+			return err
+		}
+	}
+	if AgentID != "" {
+		request.Header.Set(identity.HeaderAgentID, AgentID)
+	}
+	if Token != "" {
+		request.Header.Set("Authorization", "Bearer "+Token)
+	}
+	// Сервер может принимать метрики только из доверенных подсетей и проверяет адрес агента по X-Real-IP
+	if ip, err := outboundIP(request.URL); err != nil {
+		logger.Warn("Не удалось определить адрес агента для X-Real-IP", zap.Error(err))
+	} else {
+		request.Header.Set(identity.HeaderRealIP, ip.String())
+	}
 
 	// Отправляем HTTP-запрос
-	client := &http.Client{}
-	resp, err := client.Do(request)
+	resp, err := Client.Do(request)
 	if err != nil {
 		logger.Error("Ошибка выполнения HTTP-запроса", zap.Error(err))
 		return err
 	}
 	defer resp.Body.Close()
 
+	if Encrypt {
+		if err := token.VerifyResponse(string(Key), resp, request.Header.Get(token.HeaderNonce)); err != nil {
+			logger.Error("Ответ сервера не прошёл проверку подписи", zap.Error(err))
+			return err
+		}
+	}
+
 	// Проверяем статус ответа сервера
 	if resp.StatusCode != http.StatusOK {
-		b, _ := io.ReadAll(resp.Body)
-		logger.Error("Ошибка при отправке метрик", zap.String("status", resp.Status), zap.String("response", string(b)))
-		return fmt.Errorf("can't send report to the server: %s; %s", resp.Status, b)
+		p := problem.Parse(resp)
+		logger.Error("Ошибка при отправке метрик", zap.String("status", resp.Status), zap.String("code", string(p.Code)), zap.String("detail", p.Detail))
+		return fmt.Errorf("can't send report to the server: %w", p)
 	}
 
 	logger.Info("Метрики успешно отправлены на сервер")
 	return nil
 }
 
+// outboundIP возвращает адрес интерфейса, через который агент обращается к серверу target.
+// Для UDP соединение не устанавливается, система только выбирает маршрут и исходный адрес.
+func outboundIP(target *url.URL) (net.IP, error) {
+	port := target.Port()
+	if port == "" {
+		port = "80"
+		if target.Scheme == "https" {
+			port = "443"
+		}
+	}
+	conn, err := net.Dial("udp", net.JoinHostPort(target.Hostname(), port))
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).IP, nil
+}
+
 // sendReport - отправка одной метрики
 func sendReport(serverAddress, cryptoKeyPath string, metrics Metrics) error {
 	logger.Debug("Подготовка к отправке метрики", zap.Any("metrics", metrics))
@@ -121,7 +171,7 @@ func sendReportBatch(serverAddress, cryptoKeyPath string, metrics []Metrics) err
 func ProcessReport(serverAddress, cryptoKeyPath string, m storage.MemStorage) error {
 	var metrics Metrics
 
-	serverAddress = strings.Join([]string{"http:/", serverAddress, "update/"}, "/")
+	serverAddress = endpoint(serverAddress, "update/")
 
 	for k, v := range m.CounterData {
 		metrics = Metrics{ID: k, MType: counterType, Delta: v}
@@ -149,7 +199,7 @@ func ProcessReport(serverAddress, cryptoKeyPath string, m storage.MemStorage) er
 func ProcessBatch(ctx context.Context, serverAddress, cryptoKeyPath string, m storage.MemStorage) error {
 	var metrics []Metrics
 
-	serverAddress = strings.Join([]string{"http:/", serverAddress, "updates/"}, "/")
+	serverAddress = endpoint(serverAddress, "updates/")
 
 	for k, v := range m.CounterData {
 		metrics = append(metrics, Metrics{ID: k, MType: counterType, Delta: v})
@@ -3,8 +3,10 @@ package agent
 import (
 	"context"
 	"go.uber.org/zap"
+	"net/http"
 	"time"
 
+	"github.com/RomanenkoDR/metrics/internal/certs"
 	"github.com/RomanenkoDR/metrics/internal/middleware/logger"
 	"github.com/RomanenkoDR/metrics/internal/storage"
 )
@@ -23,6 +25,20 @@ func Run() {
 		Encrypt = true
 		Key = []byte(cfg.Key)
 	}
+	AgentID = cfg.AgentID
+	Token = cfg.Token
+
+	// Настраиваем TLS: сервер проверяется по заданному центру сертификации, агент предъявляет свой сертификат
+	if cfg.TLSCA != "" || cfg.TLSCert != "" || cfg.TLSKey != "" {
+		tlsConfig, err := certs.ClientConfig(cfg.TLSCA, cfg.TLSCert, cfg.TLSKey)
+		if err != nil {
+			logger.Fatal("Ошибка загрузки сертификатов TLS", zap.Error(err))
+		}
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = tlsConfig
+		Client = &http.Client{Transport: transport}
+		Scheme = "https"
+	}
 
 	// Создаем тикеры
 	pollTicker := time.NewTicker(time.Second * time.Duration(cfg.PollInterval))
@@ -2,7 +2,9 @@ package agent
 
 import (
 	"context"
+	"errors"
 	"github.com/RomanenkoDR/metrics/internal/middleware/logger"
+	"github.com/RomanenkoDR/metrics/internal/problem"
 	"github.com/RomanenkoDR/metrics/internal/storage"
 	"time"
 )
@@ -10,9 +12,16 @@ import (
 // Sender определяем тип функции, которая принимает контекст, строку с адресом сервера и объект MemStorage, и возвращает ошибку.
 type sender func(context.Context, string, storage.MemStorage) error
 
+// Encrypt включает подпись запросов и проверку подписи ответов ключом Key (HMAC-SHA256)
 var Encrypt bool
 var Key []byte
 
+// AgentID передаётся серверу в заголовке X-Agent-ID, чтобы он мог отличать агентов друг от друга.
+var AgentID string
+
+// Token — токен доступа JWT с областью write, передаётся в заголовке Authorization: Bearer
+var Token string
+
 // Retry функция принимает другую функцию Sender, количество попыток retries и задержку delay, возвращает функцию того же типа,
 // которая выполняет sender с попытками повторов в случае неудачи.
 func Retry(sender sender, retries int, delay time.Duration) sender {
@@ -26,14 +35,21 @@ func Retry(sender sender, retries int, delay time.Duration) sender {
 				return err
 			}
 
-			// Логируем сообщение о неудачной попытке и увеличиваем задержку перед следующей попыткой.
-			logger.DebugLogger.Sugar().Warnf("Отправка метрик завершила ошибкой: %v, повторная попытка %v", err, delay)
-
 			delay += time.Second * 2 // Увеличиваем задержку на 2 секунды после каждой попытки.
 
+			// Если сервер указал в Retry-After, когда повторить запрос, ждём столько, сколько он просит.
+			wait := delay
+			var p *problem.Problem
+			if errors.As(err, &p) && p.RetryAfter > 0 {
+				wait = p.RetryAfter
+			}
+
+			// Логируем сообщение о неудачной попытке.
+			logger.DebugLogger.Sugar().Warnf("Отправка метрик завершила ошибкой: %v, повторная попытка через %v", err, wait)
+
 			// Ожидаем либо окончания задержки, либо завершения контекста.
 			select {
-			case <-time.After(delay):
+			case <-time.After(wait):
 			case <-ctx.Done(): // Если контекст завершён (например, программа была остановлена), возвращаем ошибку контекста.
 				logger.DebugLogger.Sugar().Error("Context отменен, остановка повторных попыток")
 				return ctx.Err()
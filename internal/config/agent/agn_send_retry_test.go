@@ -0,0 +1,31 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/RomanenkoDR/metrics/internal/problem"
+	"github.com/RomanenkoDR/metrics/internal/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryHonorsRetryAfter(t *testing.T) {
+	var calls []time.Time
+	send := Retry(func(ctx context.Context, serverAddress string, m storage.MemStorage) error {
+		calls = append(calls, time.Now())
+		if len(calls) == 1 {
+			p := problem.New(http.StatusTooManyRequests, problem.CodeRateLimited, "")
+			p.RetryAfter = 100 * time.Millisecond
+			return fmt.Errorf("can't send report to the server: %w", p)
+		}
+		return nil
+	}, 3, time.Minute)
+
+	assert.NoError(t, send(context.Background(), "localhost:8080", storage.New()))
+	if assert.Len(t, calls, 2) {
+		assert.Less(t, calls[1].Sub(calls[0]), time.Second, "Retry-After replaces the growing delay")
+	}
+}
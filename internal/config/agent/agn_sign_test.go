@@ -0,0 +1,85 @@
+package agent
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/RomanenkoDR/metrics/internal/middleware/token"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSendRequestSigned(t *testing.T) {
+	Encrypt, Key = true, []byte("secret")
+	t.Cleanup(func() { Encrypt, Key = false, nil })
+
+	var tamper bool
+	var gotBody []byte
+	handler := token.CheckReqSign(func() string { return "secret" }, token.Options{Strict: true})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotBody, _ = io.ReadAll(r.Body)
+		w.Write([]byte("ok"))
+	}))
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !tamper {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, r)
+		w.Header().Set(token.Header, rec.Header().Get(token.Header))
+		w.Write([]byte("tampered"))
+	}))
+	defer srv.Close()
+
+	require.NoError(t, sendRequest(srv.URL, []byte(`[]`), ""))
+	assert.Equal(t, `[]`, string(gotBody))
+
+	tamper = true
+	err := sendRequest(srv.URL, []byte(`[]`), "")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "signature")
+}
+
+func TestSendRequestUnsignedResponse(t *testing.T) {
+	Encrypt, Key = true, []byte("secret")
+	t.Cleanup(func() { Encrypt, Key = false, nil })
+
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NotEmpty(t, r.Header.Get(token.HeaderNonce))
+		assert.NotEmpty(t, r.Header.Get(token.Header))
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	assert.EqualError(t, sendRequest(srv.URL, []byte(`[]`), ""), "server response with status 200 is not signed")
+
+	// Ответ об ошибке тоже должен быть подписан
+	status = http.StatusBadRequest
+	assert.EqualError(t, sendRequest(srv.URL, []byte(`[]`), ""), "server response with status 400 is not signed")
+}
+
+func TestSendRequestReplayedResponse(t *testing.T) {
+	Encrypt, Key = true, []byte("secret")
+	t.Cleanup(func() { Encrypt, Key = false, nil })
+
+	// Подписанный пустой ответ 200 на другой запрос не подходит к новому
+	var recorded http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if recorded == nil {
+			rec := httptest.NewRecorder()
+			token.CheckReqSign(func() string { return "secret" }, token.Options{})(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})).ServeHTTP(rec, r)
+			recorded = rec.Header().Clone()
+		}
+		w.Header().Set(token.Header, recorded.Get(token.Header))
+	}))
+	defer srv.Close()
+
+	err := sendRequest(srv.URL, []byte(`[]`), "")
+	require.NoError(t, err)
+	err = sendRequest(srv.URL, []byte(`[]`), "")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "signature")
+}
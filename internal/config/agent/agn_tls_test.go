@@ -0,0 +1,40 @@
+package agent
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/RomanenkoDR/metrics/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEndpoint(t *testing.T) {
+	t.Cleanup(func() { Scheme = "http" })
+
+	assert.Equal(t, "http://localhost:8080/updates/", endpoint("localhost:8080", "updates/"))
+	assert.Equal(t, "https://metrics.local/updates/", endpoint("https://metrics.local/", "updates/"))
+
+	Scheme = "https"
+	assert.Equal(t, "https://localhost:8080/updates/", endpoint("localhost:8080", "updates/"))
+	assert.Equal(t, "http://localhost:8080/update/", endpoint("http://localhost:8080", "update/"), "explicit scheme wins")
+}
+
+func TestProcessBatchHTTPS(t *testing.T) {
+	var path string
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+	}))
+	defer srv.Close()
+
+	Client, Scheme = srv.Client(), "https"
+	t.Cleanup(func() { Client, Scheme = &http.Client{}, "http" })
+
+	m := storage.New()
+	m.UpdateCounter("PollCount", 1)
+	require.NoError(t, ProcessBatch(context.Background(), strings.TrimPrefix(srv.URL, "https://"), "", m))
+	assert.Equal(t, "/updates/", path)
+}
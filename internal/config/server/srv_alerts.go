@@ -0,0 +1,66 @@
+package server
+
+import (
+	"context"
+
+	"github.com/RomanenkoDR/metrics/internal/alerts"
+	"github.com/RomanenkoDR/metrics/internal/anomaly"
+	"github.com/RomanenkoDR/metrics/internal/config/server/types"
+	"github.com/RomanenkoDR/metrics/internal/handlers"
+	"github.com/RomanenkoDR/metrics/internal/middleware/logger"
+	"github.com/RomanenkoDR/metrics/internal/notify"
+	"github.com/RomanenkoDR/metrics/internal/storage"
+	"go.uber.org/zap"
+)
+
+// runAlerts загружает правила алертов, детектор аномалий, каналы уведомлений и запускает
+// периодическое вычисление. Компоненты сохраняются в обработчике; если файл правил не задан,
+// они остаются nil.
+func runAlerts(ctx context.Context, cfg types.Options, h *handlers.Handler, writer storage.StorageWriter) error {
+	if cfg.AlertRules == "" {
+		return nil
+	}
+
+	alertCfg, err := alerts.LoadConfig(cfg.AlertRules)
+	if err != nil {
+		return err
+	}
+
+	// Состояние алертов сохраняется тем же бэкендом, что и метрики
+	state, _ := writer.(storage.StateStore)
+	engine := alerts.NewEngine(alertCfg, h.Store, state)
+
+	anomalyCfg, err := anomaly.LoadConfig(cfg.AlertRules)
+	if err != nil {
+		return err
+	}
+	if anomalyCfg.Enabled {
+		h.Anomalies = anomaly.NewDetector(anomalyCfg, h.Store)
+		engine.AddDetector(h.Anomalies)
+		logger.Info("Поиск аномалий включён", zap.Strings("metrics", anomalyCfg.Metrics))
+	}
+
+	if err := engine.Restore(); err != nil {
+		logger.Warn("Не удалось восстановить состояние алертов", zap.Error(err))
+	}
+
+	notifyCfg, err := notify.LoadConfig(cfg.AlertRules)
+	if err != nil {
+		return err
+	}
+
+	if len(notifyCfg.Receivers) > 0 {
+		h.Notifications, err = notify.NewDispatcher(notifyCfg)
+		if err != nil {
+			return err
+		}
+		engine.AddSink(h.Notifications)
+		logger.Info("Уведомления об алертах включены", zap.Int("receivers", len(notifyCfg.Receivers)))
+	}
+
+	h.Alerts = engine
+	go engine.Run(ctx)
+
+	logger.Info("Алерты запущены", zap.String("file", cfg.AlertRules), zap.Int("rules", len(alertCfg.Rules)))
+	return nil
+}
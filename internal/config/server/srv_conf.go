@@ -9,6 +9,12 @@ import (
 	"os"
 )
 
+// Ограничения размера тела запроса по умолчанию
+const (
+	defaultMaxBodySize         = 10 << 20
+	defaultMaxDecompressedSize = 50 << 20
+)
+
 func parseOptions() (types.Options, error) {
 	var cfg types.Options
 
@@ -24,17 +30,67 @@ func parseOptions() (types.Options, error) {
 	// Чтение флага "-r" для задания опции восстановления метрик из файла
 	flag.BoolVar(&cfg.Restore, "r", true, "Restore metrics value from file")
 
-	// Чтение флака "-k" для задания токена JWT
-	flag.StringVar(&cfg.Key, "k", "", "Token auth by JWT")
+	// Чтение флага "-k" для задания ключа подписи запросов и ответов HMAC-SHA256
+	flag.StringVar(&cfg.Key, "k", "", "Key to verify request and sign response bodies with HMAC-SHA256 (HashSHA256 header)")
+
+	// Чтение флага "-key-file" для задания файла с ключом подписи, который можно перечитать без перезапуска
+	flag.StringVar(&cfg.KeyFile, "key-file", "", "Path to file with sign key, overrides -k")
 
 	// Чтение флага "-d" для задания строки подключения к базе данных
 	flag.StringVar(&cfg.DBDSN, "d", "", "Connection string in Postgres format")
 
-	// Чтение параметра командной строки для пути к файлу с публичным ключом
-	flag.StringVar(&cfg.CryptoKey, "crypto-key", "", "Path to the public key for encryption")
+	// Чтение параметра командной строки для приватных ключей расшифровки: файлы или каталоги через запятую
+	flag.StringVar(&cfg.CryptoKey, "crypto-key", "", "Comma-separated private key PEM files or directories with *.pem files for decryption")
+
+	// Чтение параметра "-crypto-legacy-key": отдельный RSA-ключ, с которым на время обновления агентов
+	// принимается прежний формат шифрования. Ключи -crypto-key для него не используются, потому что
+	// расшифровка PKCS #1 v1.5 позволяет атаку на дополнение (Блейхенбахера) против ключа.
+	flag.StringVar(&cfg.CryptoLegacyKey, "crypto-legacy-key", "", "Separate RSA private key PEM file for request bodies in legacy AES-CFB and RSA PKCS #1 v1.5 format")
 
 	flag.StringVar(&cfg.Config, "c", "", "Path to config file")
 
+	// Чтение флагов для приёма метрик по протоколу Graphite
+	flag.StringVar(&cfg.GraphiteAddress, "graphite-address", "", "Graphite plaintext listener address, disabled if empty")
+	flag.StringVar(&cfg.GraphiteRules, "graphite-rules", "", "Path to JSON file with Graphite path mapping rules")
+
+	// Чтение флага "-alert-rules" для задания файла с правилами алертов
+	flag.StringVar(&cfg.AlertRules, "alert-rules", "", "Path to JSON file with alerting rules, alerting disabled if empty")
+
+	// Чтение флага "-admin-key" для задания токена доступа к /admin, отдельного от ключа агентов
+	flag.StringVar(&cfg.AdminKey, "admin-key", "", "Bearer token for /admin API, admin API disabled if empty")
+
+	// Чтение флага "-rate-limits" для задания файла с ограничениями частоты запросов
+	flag.StringVar(&cfg.RateLimits, "rate-limits", "", "Path to JSON file with per-client rate limits, rate limiting disabled if empty")
+
+	// Чтение флага "-self-metrics-interval" для копирования собственных метрик сервера в хранилище
+	flag.IntVar(&cfg.SelfMetricsInterval, "self-metrics-interval", 0, "Interval in seconds to copy server telemetry into metrics storage, disabled if 0")
+
+	// Чтение флагов режима проверки подписи запросов и допустимого расхождения часов
+	flag.StringVar(&cfg.SignMode, "sign-mode", "optional", "Request signature mode: optional accepts unsigned requests, strict requires signature with timestamp and nonce")
+	flag.IntVar(&cfg.SignSkew, "sign-skew", 300, "Allowed clock skew in seconds for signed request timestamps")
+
+	// Чтение флагов, ограничивающих размер тела запроса до и после распаковки gzip
+	flag.Int64Var(&cfg.MaxBodySize, "max-body", defaultMaxBodySize, "Max request body size in bytes, 413 if larger, unlimited if 0")
+	flag.Int64Var(&cfg.MaxDecompressedSize, "max-decompressed", defaultMaxDecompressedSize, "Max decompressed gzip request body size in bytes, 413 if larger, unlimited if 0")
+
+	// Чтение флагов TLS: сертификат сервера и центры сертификации клиентов для mTLS
+	flag.StringVar(&cfg.TLSCert, "tls-cert", "", "Path to the server TLS certificate in PEM, plain HTTP if empty")
+	flag.StringVar(&cfg.TLSKey, "tls-key", "", "Path to the server TLS private key in PEM")
+	flag.StringVar(&cfg.TLSClientCA, "tls-client-ca", "", "Path to CA certificates in PEM to require and verify client certificates (mTLS)")
+
+	// Чтение флагов доверенных подсетей, из которых принимается запись метрик
+	flag.StringVar(&cfg.TrustedSubnet, "t", "", "Comma-separated CIDR subnets allowed to write metrics by X-Real-IP header and Graphite connection address, any if empty")
+	flag.BoolVar(&cfg.TrustedRemote, "trusted-remote", false, "Require the connection address to be in a trusted subnet as well as X-Real-IP")
+
+	// Чтение флагов ключей токенов доступа JWT с областями read, write и admin
+	flag.StringVar(&cfg.JWTSecret, "jwt-secret", "", "Secret to verify HS256 bearer tokens, token auth disabled if neither secret nor public key is set")
+	flag.StringVar(&cfg.JWTPublicKey, "jwt-public-key", "", "Path to RSA public key in PEM to verify RS256 bearer tokens")
+	flag.StringVar(&cfg.JWTIssuer, "jwt-issuer", "", "Required iss claim of bearer tokens, not checked if empty")
+	flag.StringVar(&cfg.JWTAudience, "jwt-audience", "", "Required aud claim of bearer tokens, not checked if empty")
+
+	// Чтение флага "-audit-log" для журнала аудита изменений метрик и административных действий
+	flag.StringVar(&cfg.AuditLog, "audit-log", "", "Path to append-only hash-chained audit log of metric writes and admin actions, disabled if empty")
+
 	// Парсинг флагов командной строки
 	flag.Parse()
 
@@ -85,6 +141,72 @@ func parseOptions() (types.Options, error) {
 		if cfg.CryptoKey == "" {
 			cfg.CryptoKey = jsonCfg.CryptoKey
 		}
+		if cfg.CryptoLegacyKey == "" {
+			cfg.CryptoLegacyKey = jsonCfg.CryptoLegacyKey
+		}
+		if cfg.GraphiteAddress == "" {
+			cfg.GraphiteAddress = jsonCfg.GraphiteAddress
+		}
+		if cfg.GraphiteRules == "" {
+			cfg.GraphiteRules = jsonCfg.GraphiteRules
+		}
+		if cfg.AlertRules == "" {
+			cfg.AlertRules = jsonCfg.AlertRules
+		}
+		if cfg.KeyFile == "" {
+			cfg.KeyFile = jsonCfg.KeyFile
+		}
+		if cfg.AdminKey == "" {
+			cfg.AdminKey = jsonCfg.AdminKey
+		}
+		if cfg.RateLimits == "" {
+			cfg.RateLimits = jsonCfg.RateLimits
+		}
+		if cfg.SelfMetricsInterval == 0 {
+			cfg.SelfMetricsInterval = int(jsonCfg.SelfMetricsInterval.Seconds())
+		}
+		if cfg.SignMode == "optional" && jsonCfg.SignMode != "" {
+			cfg.SignMode = jsonCfg.SignMode
+		}
+		if cfg.SignSkew == 300 && jsonCfg.SignSkew != 0 {
+			cfg.SignSkew = int(jsonCfg.SignSkew.Seconds())
+		}
+		if cfg.MaxBodySize == defaultMaxBodySize && jsonCfg.MaxBodySize != 0 {
+			cfg.MaxBodySize = jsonCfg.MaxBodySize
+		}
+		if cfg.MaxDecompressedSize == defaultMaxDecompressedSize && jsonCfg.MaxDecompressedSize != 0 {
+			cfg.MaxDecompressedSize = jsonCfg.MaxDecompressedSize
+		}
+		if cfg.TLSCert == "" {
+			cfg.TLSCert = jsonCfg.TLSCert
+		}
+		if cfg.TLSKey == "" {
+			cfg.TLSKey = jsonCfg.TLSKey
+		}
+		if cfg.TLSClientCA == "" {
+			cfg.TLSClientCA = jsonCfg.TLSClientCA
+		}
+		if cfg.TrustedSubnet == "" {
+			cfg.TrustedSubnet = jsonCfg.TrustedSubnet
+		}
+		if !cfg.TrustedRemote {
+			cfg.TrustedRemote = jsonCfg.TrustedRemote
+		}
+		if cfg.JWTSecret == "" {
+			cfg.JWTSecret = jsonCfg.JWTSecret
+		}
+		if cfg.JWTPublicKey == "" {
+			cfg.JWTPublicKey = jsonCfg.JWTPublicKey
+		}
+		if cfg.JWTIssuer == "" {
+			cfg.JWTIssuer = jsonCfg.JWTIssuer
+		}
+		if cfg.JWTAudience == "" {
+			cfg.JWTAudience = jsonCfg.JWTAudience
+		}
+		if cfg.AuditLog == "" {
+			cfg.AuditLog = jsonCfg.AuditLog
+		}
 	}
 
 	return cfg, nil
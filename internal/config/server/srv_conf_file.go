@@ -8,12 +8,44 @@ import (
 
 // serverFileConfig хранит конфигурацию сервера из JSON-файла
 type serverFileConfig struct {
-	Address       string        `json:"address"`        // Адрес сервера
-	Restore       bool          `json:"restore"`        // Восстанавливать ли метрики из файла
-	StoreInterval time.Duration `json:"store_interval"` // Интервал сохранения метрик
-	StoreFile     string        `json:"store_file"`     // Файл хранения метрик
-	DatabaseDSN   string        `json:"database_dsn"`   // Строка подключения к БД
-	CryptoKey     string        `json:"crypto_key"`     // Путь к приватному ключу
+	Address         string        `json:"address"`           // Адрес сервера
+	Restore         bool          `json:"restore"`           // Восстанавливать ли метрики из файла
+	StoreInterval   time.Duration `json:"store_interval"`    // Интервал сохранения метрик
+	StoreFile       string        `json:"store_file"`        // Файл хранения метрик
+	DatabaseDSN     string        `json:"database_dsn"`      // Строка подключения к БД
+	CryptoKey       string        `json:"crypto_key"`        // Путь к приватному ключу
+	CryptoLegacyKey string        `json:"crypto_legacy_key"` // Отдельный RSA-ключ для прежнего формата шифрования
+
+	GraphiteAddress string `json:"graphite_address"` // Адрес приёма метрик Graphite
+	GraphiteRules   string `json:"graphite_rules"`   // Файл правил сопоставления путей Graphite
+
+	AlertRules string `json:"alert_rules"` // Файл правил алертов
+
+	KeyFile    string `json:"key_file"`    // Файл ключа подписи
+	AdminKey   string `json:"admin_key"`   // Токен доступа к /admin
+	RateLimits string `json:"rate_limits"` // Файл ограничений частоты запросов
+
+	SelfMetricsInterval time.Duration `json:"self_metrics_interval"` // Интервал копирования собственных метрик в хранилище
+
+	SignMode string        `json:"sign_mode"` // Режим проверки подписи: optional или strict
+	SignSkew time.Duration `json:"sign_skew"` // Допустимое расхождение метки времени подписи
+
+	MaxBodySize         int64 `json:"max_body_size"`         // Наибольший размер тела запроса в байтах
+	MaxDecompressedSize int64 `json:"max_decompressed_size"` // Наибольший размер распакованного тела запроса в байтах
+
+	TLSCert     string `json:"tls_cert"`      // Сертификат TLS сервера
+	TLSKey      string `json:"tls_key"`       // Приватный ключ сертификата TLS
+	TLSClientCA string `json:"tls_client_ca"` // Центры сертификации клиентов для mTLS
+
+	TrustedSubnet string `json:"trusted_subnet"` // Доверенные подсети агентов в нотации CIDR через запятую
+	TrustedRemote bool   `json:"trusted_remote"` // Проверять также адрес соединения
+
+	JWTSecret    string `json:"jwt_secret"`     // Секрет токенов доступа HS256
+	JWTPublicKey string `json:"jwt_public_key"` // Публичный ключ токенов доступа RS256
+	JWTIssuer    string `json:"jwt_issuer"`     // Ожидаемый издатель токенов доступа
+	JWTAudience  string `json:"jwt_audience"`   // Ожидаемый получатель токенов доступа
+
+	AuditLog string `json:"audit_log"` // Файл журнала аудита
 }
 
 // loadConfigFromFile загружает конфигурацию сервера из JSON-файла
@@ -0,0 +1,51 @@
+package server
+
+import (
+	"github.com/RomanenkoDR/metrics/internal/audit"
+	"github.com/RomanenkoDR/metrics/internal/config/server/types"
+	"github.com/RomanenkoDR/metrics/internal/graphite"
+	"github.com/RomanenkoDR/metrics/internal/middleware/logger"
+	"github.com/RomanenkoDR/metrics/internal/middleware/subnet"
+	"github.com/RomanenkoDR/metrics/internal/storage"
+	"go.uber.org/zap"
+)
+
+// runGraphite запускает приём метрик по протоколу Graphite, если задан адрес.
+// Соединения записываются в журнал аудита log, если он открыт. Возвращает nil, если приём отключён.
+func runGraphite(cfg types.Options, store storage.MemStorage, log *audit.Log) (*graphite.Listener, error) {
+	if cfg.GraphiteAddress == "" {
+		return nil, nil
+	}
+
+	var rules graphite.Rules
+	if cfg.GraphiteRules != "" {
+		var err error
+		rules, err = graphite.LoadRules(cfg.GraphiteRules)
+		if err != nil {
+			return nil, err
+		}
+		logger.Info("Загружены правила Graphite", zap.String("file", cfg.GraphiteRules), zap.Int("rules", len(rules)))
+	}
+
+	listener := graphite.NewListener(cfg.GraphiteAddress, rules, store)
+	listener.Audit = log
+	// Запись метрик по Graphite ограничена теми же доверенными подсетями, что и по HTTP
+	if cfg.TrustedSubnet != "" {
+		var err error
+		if listener.Trusted, err = subnet.Parse(cfg.TrustedSubnet); err != nil {
+			return nil, err
+		}
+	}
+	if err := listener.Listen(); err != nil {
+		return nil, err
+	}
+
+	go func() {
+		if err := listener.Serve(); err != nil {
+			logger.Error("Ошибка приёма метрик Graphite", zap.Error(err))
+		}
+	}()
+
+	logger.Info("Приём метрик Graphite запущен", zap.String("address", cfg.GraphiteAddress))
+	return listener, nil
+}
@@ -0,0 +1,62 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/RomanenkoDR/metrics/internal/config/server/types"
+	"github.com/RomanenkoDR/metrics/internal/handlers"
+	"github.com/RomanenkoDR/metrics/internal/health"
+	"github.com/RomanenkoDR/metrics/internal/storage"
+)
+
+// minSnapshotAge — нижняя граница допустимого возраста снимка, чтобы при малом
+// интервале сохранения проверка не срабатывала от случайной задержки
+const minSnapshotAge = time.Minute
+
+// setupHealth регистрирует проверки готовности компонентов, которые настроены в cfg
+func setupHealth(cfg types.Options, h *handlers.Handler, store storage.StorageWriter, snapshots *storage.Snapshots) {
+	checker := health.New(health.DefaultTimeout)
+
+	// Проверка берёт из пула отдельное соединение: прерванная по таймауту проверка закрывает
+	// только его и не мешает сохранению метрик
+	if h.DB != nil {
+		pool := h.DB
+		checker.Add("database", func(ctx context.Context) error {
+			return pool.Ping(ctx)
+		})
+	}
+
+	// Снимок должен обновляться хотя бы раз за несколько интервалов сохранения
+	maxAge := max(3*time.Duration(cfg.Interval)*time.Second, minSnapshotAge)
+	checker.Add("snapshot", func(ctx context.Context) error {
+		if age := snapshots.Age(); age > maxAge {
+			return fmt.Errorf("last successful snapshot was %s ago, limit is %s", age.Round(time.Second), maxAge)
+		}
+		return nil
+	})
+
+	if file, ok := store.(*storage.Localfile); ok {
+		checker.Add("disk", func(ctx context.Context) error {
+			return file.CheckWritable()
+		})
+	}
+
+	if h.Keys.Files() {
+		keys := h.Keys
+		checker.Add("keys", func(ctx context.Context) error {
+			return keys.Check()
+		})
+	}
+
+	// Пока запись в журнал аудита не удаётся, изменяющие запросы отклоняются
+	if h.Audit != nil {
+		log := h.Audit
+		checker.Add("audit", func(ctx context.Context) error {
+			return log.Err()
+		})
+	}
+
+	h.Health = checker
+}
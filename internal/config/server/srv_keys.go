@@ -0,0 +1,56 @@
+package server
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/RomanenkoDR/metrics/internal/keys"
+	"github.com/RomanenkoDR/metrics/internal/middleware/logger"
+	"go.uber.org/zap"
+)
+
+// keysWatchInterval — как часто проверяются изменения файлов ключей
+const keysWatchInterval = 10 * time.Second
+
+// runKeyReload перечитывает ключи по сигналу SIGHUP и при изменении их файлов. Новый ключ
+// расшифровки можно добавить в каталог ключей, перевести агентов на парный публичный ключ
+// и затем удалить старый, не перезапуская сервер.
+func runKeyReload(ctx context.Context, store *keys.Store) {
+	if !store.Files() {
+		return
+	}
+	logKeys(store, nil)
+
+	go store.Watch(ctx, keysWatchInterval, func(err error) { logKeys(store, err) })
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		defer signal.Stop(hup)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hup:
+				logKeys(store, store.Reload())
+			}
+		}
+	}()
+}
+
+// logKeys сообщает результат загрузки ключей
+func logKeys(store *keys.Store, err error) {
+	if err != nil {
+		logger.Error("Ошибка перезагрузки ключей, действуют прежние", zap.Error(err))
+		return
+	}
+
+	ids := make([]string, 0, len(store.PrivateKeys()))
+	for _, k := range store.PrivateKeys() {
+		ids = append(ids, k.ID)
+	}
+	logger.Info("Ключи загружены", zap.Strings("private_keys", ids), zap.Bool("signing", store.Signing()))
+}
@@ -2,8 +2,11 @@ package server
 
 import (
 	"context"
+	"github.com/RomanenkoDR/metrics/internal/audit"
+	"github.com/RomanenkoDR/metrics/internal/crypto"
 	"github.com/RomanenkoDR/metrics/internal/db"
 	"github.com/RomanenkoDR/metrics/internal/handlers"
+	"github.com/RomanenkoDR/metrics/internal/keys"
 	"github.com/RomanenkoDR/metrics/internal/middleware/logger"
 	"github.com/RomanenkoDR/metrics/internal/routers"
 	"github.com/RomanenkoDR/metrics/internal/storage"
@@ -12,6 +15,7 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 )
 
 func Run() {
@@ -36,7 +40,36 @@ func Run() {
 		logger.Warn("Флаг -crypto-key не задан, сервер не сможет расшифровывать данные")
 	} else {
 		logger.Info("Используется приватный ключ для расшифровки", zap.String("cryptoKey", cfg.CryptoKey))
-		h.SetCryptoKey(cfg.CryptoKey)
+	}
+
+	// Загружаем ключи подписи и расшифровки, их можно перечитать через /admin/keys/reload
+	h.Keys, err = keys.New(keys.Config{SignKey: cfg.Key, SignKeyFile: cfg.KeyFile, PrivateKeyPath: cfg.CryptoKey})
+	if err != nil {
+		logger.Fatal("Ошибка загрузки ключей", zap.Error(err))
+	}
+	h.ClockSkew = time.Duration(cfg.SignSkew) * time.Second
+	if cfg.CryptoLegacyKey != "" {
+		h.LegacyKey, err = crypto.LoadPrivateKey(cfg.CryptoLegacyKey)
+		if err != nil {
+			logger.Fatal("Ошибка загрузки ключа прежнего формата шифрования", zap.Error(err))
+		}
+		if err := handlers.CheckLegacyKey(h.LegacyKey, h.Keys.PrivateKeys()); err != nil {
+			logger.Fatal("Некорректный ключ прежнего формата шифрования", zap.Error(err))
+		}
+		logger.Warn("Принимаются тела, зашифрованные в прежнем формате без аутентификации, удалите -crypto-legacy-key после обновления агентов")
+	}
+
+	// Открываем журнал аудита, существующий журнал проверяется перед продолжением
+	if cfg.AuditLog != "" {
+		h.Audit, err = audit.Open(cfg.AuditLog)
+		if err != nil {
+			logger.Fatal("Ошибка открытия журнала аудита, сохраните файл для разбора и укажите новый", zap.String("file", cfg.AuditLog), zap.Error(err))
+		}
+		if torn := h.Audit.Torn(); torn > 0 {
+			logger.Warn("Отрезана неполная последняя строка журнала аудита", zap.String("file", cfg.AuditLog), zap.Int64("bytes", torn))
+		}
+		head := h.Audit.Head()
+		logger.Info("Журнал аудита открыт", zap.String("file", cfg.AuditLog), zap.Uint64("seq", head.Seq), zap.String("hash", head.Hash))
 	}
 
 	// Определяем хранилище данных (БД или файл)
@@ -47,7 +80,7 @@ func Run() {
 		}
 		logger.Info("Успешное подключение к базе данных")
 		store = &database
-		h.DBconn = database.Conn
+		h.DB = database.Pool
 	} else {
 		store = &storage.Localfile{Path: cfg.Filename}
 	}
@@ -55,6 +88,10 @@ func Run() {
 	// Создаём новое хранилище данных
 	h.Store = storage.New()
 
+	// Запоминаем время успешных сохранений для проверки готовности
+	snapshots := storage.TrackSnapshots(store)
+	h.Writer = snapshots
+
 	// Восстанавливаем данные, если они есть
 	err = store.RestoreData(&h.Store)
 	if err != nil {
@@ -63,6 +100,30 @@ func Run() {
 		logger.Info("Данные успешно загружены из хранилища")
 	}
 
+	// Запускаем приём метрик по протоколу Graphite
+	graphiteListener, err := runGraphite(cfg, h.Store, h.Audit)
+	if err != nil {
+		logger.Fatal("Ошибка запуска приёма метрик Graphite", zap.Error(err))
+	}
+
+	// Контекст фоновых задач сервера, отменяется при остановке
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Запускаем вычисление правил алертов
+	if err := runAlerts(ctx, cfg, &h, store); err != nil {
+		logger.Fatal("Ошибка загрузки правил алертов", zap.Error(err))
+	}
+
+	// Запускаем сбор собственных метрик сервера
+	runTelemetry(ctx, cfg, h.Store)
+
+	// Ключи перечитываются по SIGHUP и при изменении файлов
+	runKeyReload(ctx, h.Keys)
+
+	// Регистрируем проверки готовности компонентов
+	setupHealth(cfg, &h, store, snapshots)
+
 	// Инициализируем маршрутизатор
 	router, err := routers.InitRouter(cfg, h)
 	if err != nil {
@@ -75,10 +136,16 @@ func Run() {
 		Handler: router,
 	}
 
+	// Включаем TLS, если заданы сертификат и ключ
+	tlsEnabled, err := setupTLS(ctx, cfg, &h, &server)
+	if err != nil {
+		logger.Fatal("Ошибка загрузки сертификатов TLS", zap.Error(err))
+	}
+
 	// Запускаем периодическое сохранение данных
 	go func() {
 		for {
-			store.Save(cfg.Interval, h.Store)
+			snapshots.Save(cfg.Interval, h.Store)
 		}
 	}()
 
@@ -91,8 +158,21 @@ func Run() {
 
 		logger.Info("Остановка сервера")
 
+		// Останавливаем фоновые задачи
+		cancel()
+		if h.Notifications != nil {
+			h.Notifications.Close()
+		}
+
+		// Прекращаем приём метрик Graphite до финального сохранения
+		if graphiteListener != nil {
+			if err := graphiteListener.Close(); err != nil {
+				logger.Error("Ошибка остановки приёма метрик Graphite", zap.Error(err))
+			}
+		}
+
 		// Сохраняем данные перед выходом
-		if err := store.Write(h.Store); err != nil {
+		if err := snapshots.Write(h.Store); err != nil {
 			logger.Error("Ошибка сохранения данных перед выходом", zap.Error(err))
 		}
 
@@ -101,11 +181,26 @@ func Run() {
 		if err := server.Shutdown(context.Background()); err != nil {
 			logger.Error("Ошибка завершения сервера", zap.Error(err))
 		}
+
+		// Журнал закрывается после завершения запросов, которые ещё могут в него писать
+		if h.Audit != nil {
+			head := h.Audit.Head()
+			logger.Info("Журнал аудита закрыт", zap.Uint64("seq", head.Seq), zap.String("hash", head.Hash))
+			if err := h.Audit.Close(); err != nil {
+				logger.Error("Ошибка закрытия журнала аудита", zap.Error(err))
+			}
+		}
 		close(idleConnectionsClosed)
 	}()
 
-	logger.Info("Сервер запущен", zap.String("address", cfg.Address))
-	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+	logger.Info("Сервер запущен", zap.String("address", cfg.Address), zap.Bool("tls", tlsEnabled))
+	if tlsEnabled {
+		// Сертификат берётся из server.TLSConfig
+		err = server.ListenAndServeTLS("", "")
+	} else {
+		err = server.ListenAndServe()
+	}
+	if err != nil && err != http.ErrServerClosed {
 		logger.Fatal("Ошибка запуска сервера", zap.Error(err))
 	}
 
@@ -0,0 +1,63 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"github.com/RomanenkoDR/metrics/internal/config/server/types"
+	"github.com/RomanenkoDR/metrics/internal/middleware/logger"
+	"github.com/RomanenkoDR/metrics/internal/storage"
+	"github.com/RomanenkoDR/metrics/internal/telemetry"
+	"go.uber.org/zap"
+)
+
+// runTelemetry регистрирует метрики числа серий и, если задан интервал, периодически
+// копирует собственные метрики сервера в хранилище под префиксом telemetry.Prefix
+func runTelemetry(ctx context.Context, cfg types.Options, store storage.MemStorage) {
+	telemetry.Default.Buckets("batch_size", telemetry.SizeBuckets)
+	telemetry.Default.GaugeFunc("series", telemetry.Labels{"type": "counter"}, func() float64 {
+		counters, _ := store.Len()
+		return float64(counters)
+	})
+	telemetry.Default.GaugeFunc("series", telemetry.Labels{"type": "gauge"}, func() float64 {
+		_, gauges := store.Len()
+		return float64(gauges)
+	})
+
+	if cfg.SelfMetricsInterval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(time.Duration(cfg.SelfMetricsInterval) * time.Second)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				mirrorTelemetry(telemetry.Default, store)
+			}
+		}
+	}()
+	logger.Info("Собственные метрики сервера копируются в хранилище",
+		zap.String("prefix", telemetry.Prefix), zap.Int("interval", cfg.SelfMetricsInterval))
+}
+
+// mirrorTelemetry записывает текущие значения собственных метрик в хранилище. Счётчики
+// увеличиваются на разницу с сохранённым значением, чтобы в хранилище было накопленное значение.
+func mirrorTelemetry(reg *telemetry.Registry, store storage.MemStorage) {
+	for _, s := range reg.Samples() {
+		id := storage.JoinLabels(s.Name, s.Labels)
+		if s.Kind == telemetry.KindGauge {
+			store.UpdateGauge(id, storage.Gauge(s.Value))
+			continue
+		}
+
+		current, _ := store.GetCounter(id)
+		if delta := storage.Counter(s.Value) - current; delta != 0 {
+			store.UpdateCounter(id, delta)
+		}
+	}
+}
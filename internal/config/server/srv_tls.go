@@ -0,0 +1,77 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/RomanenkoDR/metrics/internal/certs"
+	"github.com/RomanenkoDR/metrics/internal/config/server/types"
+	"github.com/RomanenkoDR/metrics/internal/handlers"
+	"github.com/RomanenkoDR/metrics/internal/middleware/logger"
+	"go.uber.org/zap"
+)
+
+// certsWatchInterval — как часто проверяются изменения файлов сертификатов
+const certsWatchInterval = 10 * time.Second
+
+// setupTLS включает TLS на server, если заданы сертификат и ключ, и возвращает true.
+// Сертификаты перечитываются по SIGHUP и при изменении файлов, поэтому обновлённый
+// сертификат начинает действовать без перезапуска сервера.
+func setupTLS(ctx context.Context, cfg types.Options, h *handlers.Handler, server *http.Server) (bool, error) {
+	if cfg.TLSCert == "" && cfg.TLSKey == "" {
+		if cfg.TLSClientCA != "" {
+			return false, errors.New("-tls-client-ca requires -tls-cert and -tls-key")
+		}
+		return false, nil
+	}
+
+	store, err := certs.New(certs.Config{CertFile: cfg.TLSCert, KeyFile: cfg.TLSKey, ClientCAFile: cfg.TLSClientCA})
+	if err != nil {
+		return false, err
+	}
+	server.TLSConfig = store.TLSConfig()
+	logCerts(store, nil)
+
+	go store.Watch(ctx, certsWatchInterval, func(err error) { logCerts(store, err) })
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		defer signal.Stop(hup)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hup:
+				logCerts(store, store.Reload())
+			}
+		}
+	}()
+
+	// Просроченный сертификат не даёт агентам подключиться, сервер не готов принимать метрики
+	if h.Health != nil {
+		h.Health.Add("certificate", func(context.Context) error {
+			if expires := store.Expires(); time.Now().After(expires) {
+				return fmt.Errorf("certificate expired at %s", expires.Format(time.RFC3339))
+			}
+			return nil
+		})
+	}
+	return true, nil
+}
+
+// logCerts сообщает результат загрузки сертификатов
+func logCerts(store *certs.Store, err error) {
+	if err != nil {
+		logger.Error("Ошибка перезагрузки сертификатов TLS, действуют прежние", zap.Error(err))
+		return
+	}
+	logger.Info("Сертификаты TLS загружены",
+		zap.Time("expires", store.Expires()), zap.Bool("mutual_tls", store.MutualTLS()))
+}
@@ -7,6 +7,39 @@ type Options struct {
 	Restore   bool   `env:"RESTORE"`
 	DBDSN     string `env:"DATABASE_DSN"`
 	Key       string `env:"KEY"`
+	KeyFile   string `env:"KEY_FILE"`
 	CryptoKey string `env:"CRYPTO_KEY"`
 	Config    string `env:"CONFIG"`
+
+	CryptoLegacyKey string `env:"CRYPTO_LEGACY_KEY"`
+
+	GraphiteAddress string `env:"GRAPHITE_ADDRESS"`
+	GraphiteRules   string `env:"GRAPHITE_RULES"`
+
+	AlertRules string `env:"ALERT_RULES"`
+
+	AdminKey   string `env:"ADMIN_KEY"`
+	RateLimits string `env:"RATE_LIMITS"`
+
+	SelfMetricsInterval int `env:"SELF_METRICS_INTERVAL"`
+
+	SignMode string `env:"SIGN_MODE"`
+	SignSkew int    `env:"SIGN_SKEW"`
+
+	MaxBodySize         int64 `env:"MAX_BODY_SIZE"`
+	MaxDecompressedSize int64 `env:"MAX_DECOMPRESSED_SIZE"`
+
+	TLSCert     string `env:"TLS_CERT"`
+	TLSKey      string `env:"TLS_KEY"`
+	TLSClientCA string `env:"TLS_CLIENT_CA"`
+
+	TrustedSubnet string `env:"TRUSTED_SUBNET"`
+	TrustedRemote bool   `env:"TRUSTED_REMOTE"`
+
+	JWTSecret    string `env:"JWT_SECRET"`
+	JWTPublicKey string `env:"JWT_PUBLIC_KEY"`
+	JWTIssuer    string `env:"JWT_ISSUER"`
+	JWTAudience  string `env:"JWT_AUDIENCE"`
+
+	AuditLog string `env:"AUDIT_LOG"`
 }
@@ -5,13 +5,12 @@ import (
 	"crypto/cipher"
 	"crypto/rand"
 	"crypto/rsa"
-	"crypto/x509"
-	"encoding/pem"
 	"errors"
-	"os"
 )
 
 // DecryptAES расшифровывает данные с помощью AES-ключа.
+//
+// Deprecated: AES-CFB не аутентифицирует шифротекст, используйте Open.
 func DecryptAES(data, key []byte) ([]byte, error) {
 	block, err := aes.NewCipher(key)
 	if err != nil {
@@ -32,21 +31,22 @@ func DecryptAES(data, key []byte) ([]byte, error) {
 }
 
 // DecryptRSA расшифровывает данные с помощью приватного RSA-ключа.
+//
+// Deprecated: PKCS #1 v1.5 уязвим к атакам на дополнение, используйте Open.
 func DecryptRSA(data []byte, privateKeyPath string) ([]byte, error) {
-	privKeyBytes, err := os.ReadFile(privateKeyPath)
+	priv, err := LoadPrivateKey(privateKeyPath)
 	if err != nil {
 		return nil, err
 	}
-
-	block, _ := pem.Decode(privKeyBytes)
-	if block == nil {
-		return nil, errors.New("failed to decode PEM block containing private key")
-	}
-
-	priv, err := x509.ParsePKCS1PrivateKey(block.Bytes)
-	if err != nil {
-		return nil, err
+	if priv.rsa == nil {
+		return nil, errors.New("not an RSA private key")
 	}
+	return DecryptRSAWithKey(data, priv.rsa)
+}
 
+// DecryptRSAWithKey расшифровывает данные уже загруженным приватным RSA-ключом.
+//
+// Deprecated: PKCS #1 v1.5 уязвим к атакам на дополнение, используйте Open.
+func DecryptRSAWithKey(data []byte, priv *rsa.PrivateKey) ([]byte, error) {
 	return rsa.DecryptPKCS1v15(rand.Reader, priv, data)
 }
@@ -5,13 +5,12 @@ import (
 	"crypto/cipher"
 	"crypto/rand"
 	"crypto/rsa"
-	"crypto/x509"
-	"encoding/pem"
 	"errors"
-	"os"
 )
 
 // EncryptAES шифрует данные с помощью AES-ключа.
+//
+// Deprecated: AES-CFB не аутентифицирует шифротекст, используйте Seal.
 func EncryptAES(data, key []byte) ([]byte, error) {
 	block, err := aes.NewCipher(key)
 	if err != nil {
@@ -31,26 +30,15 @@ func EncryptAES(data, key []byte) ([]byte, error) {
 }
 
 // EncryptRSA шифрует данные с помощью публичного RSA-ключа.
+//
+// Deprecated: PKCS #1 v1.5 уязвим к атакам на дополнение, используйте Seal.
 func EncryptRSA(data []byte, publicKeyPath string) ([]byte, error) {
-	pubKeyBytes, err := os.ReadFile(publicKeyPath)
+	pub, err := LoadPublicKey(publicKeyPath)
 	if err != nil {
 		return nil, err
 	}
-
-	block, _ := pem.Decode(pubKeyBytes)
-	if block == nil {
-		return nil, errors.New("failed to decode PEM block containing public key")
-	}
-
-	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
-	if err != nil {
-		return nil, err
-	}
-
-	rsaPub, ok := pub.(*rsa.PublicKey)
-	if !ok {
+	if pub.rsa == nil {
 		return nil, errors.New("not an RSA public key")
 	}
-
-	return rsa.EncryptPKCS1v15(rand.Reader, rsaPub, data)
+	return rsa.EncryptPKCS1v15(rand.Reader, pub.rsa, data)
 }
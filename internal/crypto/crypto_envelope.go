@@ -0,0 +1,181 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Версия и алгоритмы конверта. Конверт прежнего формата — объект {"data", "key"} без версии,
+// зашифрованный AES-CFB и RSA PKCS #1 v1.5.
+const (
+	EnvelopeVersion = 2
+	// EnvelopeAlg — AES-ключ шифруется RSA-OAEP с SHA-256
+	EnvelopeAlg = "RSA-OAEP-256+A256GCM"
+	// EnvelopeAlgECDH — AES-ключ выводится из общего секрета ECDH с эфемерным ключом (схема ECIES)
+	EnvelopeAlgECDH = "ECDH-ES+A256GCM"
+)
+
+// ErrLegacyEnvelope возвращается Open для данных в прежнем формате без версии
+var ErrLegacyEnvelope = errors.New("crypto: legacy envelope format")
+
+// ErrUnknownKey возвращается Open, если среди ключей нет ключа с идентификатором из конверта
+var ErrUnknownKey = errors.New("crypto: unknown envelope key id")
+
+// Envelope — зашифрованное тело запроса. Данные шифруются AES-256-GCM одноразовым ключом,
+// который передаётся зашифрованным RSA-OAEP или выводится через ECDH. Путь запроса и метка
+// времени входят в дополнительные данные GCM, поэтому конверт нельзя переслать на другой путь
+// или подменить ему время.
+type Envelope struct {
+	Version   int    `json:"v"`
+	Alg       string `json:"alg"`
+	Kid       string `json:"kid,omitempty"` // идентификатор ключа получателя
+	Timestamp int64  `json:"ts"`            // время шифрования, секунды Unix
+	Key       []byte `json:"key,omitempty"` // AES-ключ, зашифрованный RSA-OAEP
+	EPK       []byte `json:"epk,omitempty"` // эфемерный публичный ключ ECDH
+	Nonce     []byte `json:"nonce"`         // nonce GCM
+	Data      []byte `json:"data"`          // шифротекст с тегом аутентификации
+}
+
+// additionalData связывает шифротекст с алгоритмом, версией, путём запроса и меткой времени
+func additionalData(alg, path string, ts int64) []byte {
+	return []byte(alg + "\x00" + strconv.Itoa(EnvelopeVersion) + "\x00" + path + "\x00" + strconv.FormatInt(ts, 10))
+}
+
+// Seal шифрует data ключом pub для запроса на путь path и возвращает конверт в JSON
+func Seal(data []byte, pub *PublicKey, path string, now time.Time) ([]byte, error) {
+	env := Envelope{
+		Version:   EnvelopeVersion,
+		Kid:       pub.ID,
+		Timestamp: now.Unix(),
+	}
+
+	var key []byte
+	if pub.rsa != nil {
+		env.Alg = EnvelopeAlg
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+		wrapped, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, pub.rsa, key, nil)
+		if err != nil {
+			return nil, err
+		}
+		env.Key = wrapped
+	} else {
+		env.Alg = EnvelopeAlgECDH
+		eph, err := pub.ec.Curve().GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		shared, err := eph.ECDH(pub.ec)
+		if err != nil {
+			return nil, err
+		}
+		env.EPK = eph.PublicKey().Bytes()
+		key = deriveKey(shared, env.EPK, pub.ec.Bytes())
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	env.Nonce = make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(env.Nonce); err != nil {
+		return nil, err
+	}
+	env.Data = gcm.Seal(nil, env.Nonce, data, additionalData(env.Alg, path, env.Timestamp))
+	return json.Marshal(env)
+}
+
+// Open расшифровывает конверт, отправленный на путь path, и возвращает данные и время шифрования.
+// Ключ выбирается по идентификатору из конверта; конверт без идентификатора пробуется всеми ключами.
+// Для данных в прежнем формате возвращает ErrLegacyEnvelope.
+func Open(payload []byte, keys []*PrivateKey, path string) ([]byte, time.Time, error) {
+	var env Envelope
+	if err := json.Unmarshal(payload, &env); err != nil {
+		return nil, time.Time{}, err
+	}
+	if env.Version == 0 {
+		return nil, time.Time{}, ErrLegacyEnvelope
+	}
+	if env.Version != EnvelopeVersion || env.Alg != EnvelopeAlg && env.Alg != EnvelopeAlgECDH {
+		return nil, time.Time{}, fmt.Errorf("crypto: unsupported envelope version %d (%s)", env.Version, env.Alg)
+	}
+
+	err := ErrUnknownKey
+	for _, k := range keys {
+		if env.Kid != "" && k.ID != env.Kid {
+			continue
+		}
+		var data []byte
+		if data, err = env.open(k, path); err == nil {
+			return data, time.Unix(env.Timestamp, 0), nil
+		}
+	}
+	return nil, time.Time{}, err
+}
+
+// open расшифровывает конверт ключом k
+func (env *Envelope) open(k *PrivateKey, path string) ([]byte, error) {
+	var key []byte
+	switch {
+	case env.Alg == EnvelopeAlg && k.rsa != nil:
+		var err error
+		if key, err = rsa.DecryptOAEP(sha256.New(), rand.Reader, k.rsa, env.Key, nil); err != nil {
+			return nil, err
+		}
+	case env.Alg == EnvelopeAlgECDH && k.ec != nil:
+		epk, err := k.ec.Curve().NewPublicKey(env.EPK)
+		if err != nil {
+			return nil, err
+		}
+		shared, err := k.ec.ECDH(epk)
+		if err != nil {
+			return nil, err
+		}
+		key = deriveKey(shared, env.EPK, k.ec.PublicKey().Bytes())
+	default:
+		return nil, fmt.Errorf("crypto: key %s doesn't support %s", k.ID, env.Alg)
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(env.Nonce) != gcm.NonceSize() {
+		return nil, errors.New("crypto: incorrect envelope nonce size")
+	}
+	return gcm.Open(nil, env.Nonce, env.Data, additionalData(env.Alg, path, env.Timestamp))
+}
+
+// deriveKey выводит AES-256 ключ из общего секрета ECDH по HKDF-SHA256 (RFC 5869) с пустой солью.
+// В info входят алгоритм и оба публичных ключа, чтобы ключ был привязан к участникам обмена.
+func deriveKey(shared, epk, recipient []byte) []byte {
+	extract := hmac.New(sha256.New, make([]byte, sha256.Size))
+	extract.Write(shared)
+	prk := extract.Sum(nil)
+
+	expand := hmac.New(sha256.New, prk)
+	expand.Write([]byte(EnvelopeAlgECDH))
+	expand.Write(epk)
+	expand.Write(recipient)
+	expand.Write([]byte{1})
+	return expand.Sum(nil)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,107 @@
+package crypto
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newRSAKey(t *testing.T) *PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	priv, err := NewPrivateKey(key)
+	require.NoError(t, err)
+	return priv
+}
+
+func TestSealOpen(t *testing.T) {
+	p256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	x25519, err := ecdh.X25519().GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	keys := map[string]any{"rsa": newRSAKey(t).RSA(), "p256": p256, "x25519": x25519}
+	for name, key := range keys {
+		t.Run(name, func(t *testing.T) {
+			priv, err := NewPrivateKey(key)
+			require.NoError(t, err)
+			now := time.Unix(1700000000, 0)
+
+			sealed, err := Seal([]byte(`[{"id":"a"}]`), priv.Public(), "/updates/", now)
+			require.NoError(t, err)
+
+			data, ts, err := Open(sealed, []*PrivateKey{priv}, "/updates/")
+			require.NoError(t, err)
+			assert.Equal(t, `[{"id":"a"}]`, string(data))
+			assert.Equal(t, now, ts)
+
+			// Конверт привязан к пути
+			_, _, err = Open(sealed, []*PrivateKey{priv}, "/update/")
+			assert.Error(t, err)
+
+			// Подмена метки времени или шифротекста обнаруживается
+			var env Envelope
+			require.NoError(t, json.Unmarshal(sealed, &env))
+			env.Timestamp++
+			changed, _ := json.Marshal(env)
+			_, _, err = Open(changed, []*PrivateKey{priv}, "/updates/")
+			assert.Error(t, err)
+
+			env.Timestamp--
+			env.Data[0] ^= 1
+			changed, _ = json.Marshal(env)
+			_, _, err = Open(changed, []*PrivateKey{priv}, "/updates/")
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestOpenSelectsKeyByID(t *testing.T) {
+	old, current := newRSAKey(t), newRSAKey(t)
+	ring := []*PrivateKey{old, current}
+
+	for _, k := range ring {
+		sealed, err := Seal([]byte("data"), k.Public(), "/", time.Now())
+		require.NoError(t, err)
+		data, _, err := Open(sealed, ring, "/")
+		require.NoError(t, err)
+		assert.Equal(t, "data", string(data))
+	}
+
+	// Ключ, которого нет в наборе, например уже удалённый после ротации
+	sealed, err := Seal([]byte("data"), newRSAKey(t).Public(), "/", time.Now())
+	require.NoError(t, err)
+	_, _, err = Open(sealed, ring, "/")
+	assert.ErrorIs(t, err, ErrUnknownKey)
+
+	// Конверт без идентификатора пробуется всеми ключами
+	var env Envelope
+	sealed, err = Seal([]byte("data"), current.Public(), "/", time.Now())
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(sealed, &env))
+	env.Kid = ""
+	sealed, _ = json.Marshal(env)
+	data, _, err := Open(sealed, ring, "/")
+	require.NoError(t, err)
+	assert.Equal(t, "data", string(data))
+}
+
+func TestOpenLegacy(t *testing.T) {
+	priv := newRSAKey(t)
+
+	legacy, _ := json.Marshal(map[string][]byte{"data": []byte("x"), "key": []byte("y")})
+	_, _, err := Open(legacy, []*PrivateKey{priv}, "/updates/")
+	assert.ErrorIs(t, err, ErrLegacyEnvelope)
+
+	_, _, err = Open([]byte(`{"v":3,"alg":"none"}`), []*PrivateKey{priv}, "/updates/")
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrLegacyEnvelope)
+}
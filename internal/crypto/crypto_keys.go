@@ -0,0 +1,194 @@
+package crypto
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// PublicKey — ключ шифрования конвертов: RSA или EC (NIST P-256/P-384/P-521, X25519)
+type PublicKey struct {
+	// ID — идентификатор ключа, по которому сервер выбирает приватный ключ для расшифровки
+	ID  string
+	rsa *rsa.PublicKey
+	ec  *ecdh.PublicKey
+}
+
+// PrivateKey — ключ расшифровки конвертов: RSA или EC
+type PrivateKey struct {
+	ID  string
+	rsa *rsa.PrivateKey
+	ec  *ecdh.PrivateKey
+}
+
+// NewPublicKey создаёт ключ шифрования из *rsa.PublicKey, *ecdsa.PublicKey или *ecdh.PublicKey
+func NewPublicKey(key any) (*PublicKey, error) {
+	pub := &PublicKey{}
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		pub.rsa = k
+	case *ecdsa.PublicKey:
+		ec, err := k.ECDH()
+		if err != nil {
+			return nil, err
+		}
+		pub.ec = ec
+	case *ecdh.PublicKey:
+		pub.ec = k
+	default:
+		return nil, fmt.Errorf("crypto: unsupported public key type %T", key)
+	}
+
+	id, err := keyID(key)
+	if err != nil {
+		return nil, err
+	}
+	pub.ID = id
+	return pub, nil
+}
+
+// NewPrivateKey создаёт ключ расшифровки из *rsa.PrivateKey, *ecdsa.PrivateKey или *ecdh.PrivateKey
+func NewPrivateKey(key any) (*PrivateKey, error) {
+	priv := &PrivateKey{}
+	var pub any
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		priv.rsa, pub = k, &k.PublicKey
+	case *ecdsa.PrivateKey:
+		ec, err := k.ECDH()
+		if err != nil {
+			return nil, err
+		}
+		priv.ec, pub = ec, &k.PublicKey
+	case *ecdh.PrivateKey:
+		priv.ec, pub = k, k.PublicKey()
+	default:
+		return nil, fmt.Errorf("crypto: unsupported private key type %T", key)
+	}
+
+	id, err := keyID(pub)
+	if err != nil {
+		return nil, err
+	}
+	priv.ID = id
+	return priv, nil
+}
+
+// Public возвращает парный ключ шифрования
+func (k *PrivateKey) Public() *PublicKey {
+	if k.rsa != nil {
+		return &PublicKey{ID: k.ID, rsa: &k.rsa.PublicKey}
+	}
+	return &PublicKey{ID: k.ID, ec: k.ec.PublicKey()}
+}
+
+// Type описывает алгоритм и размер ключа, например RSA-2048, EC P-256 или X25519
+func (k *PublicKey) Type() string {
+	if k.rsa != nil {
+		return fmt.Sprintf("RSA-%d", k.rsa.N.BitLen())
+	}
+	if k.ec.Curve() == ecdh.X25519() {
+		return "X25519"
+	}
+	return fmt.Sprintf("EC %s", k.ec.Curve())
+}
+
+// PKIX возвращает ключ в формате PKIX (DER)
+func (k *PublicKey) PKIX() []byte {
+	var der []byte
+	if k.rsa != nil {
+		der, _ = x509.MarshalPKIXPublicKey(k.rsa)
+	} else {
+		der, _ = x509.MarshalPKIXPublicKey(k.ec)
+	}
+	return der
+}
+
+// RSA возвращает ключ RSA или nil, если ключ эллиптический
+func (k *PrivateKey) RSA() *rsa.PrivateKey {
+	return k.rsa
+}
+
+// keyID — первые 8 байт SHA-256 от публичного ключа в формате PKIX
+func keyID(pub any) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:8]), nil
+}
+
+// ParsePrivateKey разбирает приватный ключ в PEM: PKCS #1 (RSA PRIVATE KEY), PKCS #8 (PRIVATE KEY)
+// или SEC 1 (EC PRIVATE KEY)
+func ParsePrivateKey(data []byte) (*PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("failed to decode PEM block containing private key")
+	}
+
+	var key any
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("crypto: unsupported private key PEM type %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return NewPrivateKey(key)
+}
+
+// ParsePublicKey разбирает публичный ключ в PEM: PKIX (PUBLIC KEY) или PKCS #1 (RSA PUBLIC KEY)
+func ParsePublicKey(data []byte) (*PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("failed to decode PEM block containing public key")
+	}
+
+	var key any
+	var err error
+	switch block.Type {
+	case "PUBLIC KEY":
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("crypto: unsupported public key PEM type %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return NewPublicKey(key)
+}
+
+// LoadPrivateKey читает приватный ключ из PEM-файла
+func LoadPrivateKey(path string) (*PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePrivateKey(data)
+}
+
+// LoadPublicKey читает публичный ключ из PEM-файла
+func LoadPublicKey(path string) (*PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePublicKey(data)
+}
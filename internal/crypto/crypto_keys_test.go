@@ -0,0 +1,64 @@
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseKeys(t *testing.T) {
+	rsaKey := newRSAKey(t).RSA()
+	ecKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+
+	pkcs8RSA, err := x509.MarshalPKCS8PrivateKey(rsaKey)
+	require.NoError(t, err)
+	pkcs8EC, err := x509.MarshalPKCS8PrivateKey(ecKey)
+	require.NoError(t, err)
+	sec1, err := x509.MarshalECPrivateKey(ecKey)
+	require.NoError(t, err)
+	pkixRSA, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	require.NoError(t, err)
+	pkixEC, err := x509.MarshalPKIXPublicKey(&ecKey.PublicKey)
+	require.NoError(t, err)
+
+	encode := func(typ string, der []byte) []byte {
+		return pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der})
+	}
+
+	rsaPub, err := ParsePublicKey(encode("PUBLIC KEY", pkixRSA))
+	require.NoError(t, err)
+	rsaPubPKCS1, err := ParsePublicKey(encode("RSA PUBLIC KEY", x509.MarshalPKCS1PublicKey(&rsaKey.PublicKey)))
+	require.NoError(t, err)
+	assert.Equal(t, rsaPub.ID, rsaPubPKCS1.ID)
+
+	for _, data := range [][]byte{
+		encode("RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey)),
+		encode("PRIVATE KEY", pkcs8RSA),
+	} {
+		priv, err := ParsePrivateKey(data)
+		require.NoError(t, err)
+		assert.Equal(t, rsaPub.ID, priv.ID, "key ID is derived from the public key")
+		assert.NotNil(t, priv.RSA())
+	}
+
+	ecPub, err := ParsePublicKey(encode("PUBLIC KEY", pkixEC))
+	require.NoError(t, err)
+	for _, data := range [][]byte{encode("EC PRIVATE KEY", sec1), encode("PRIVATE KEY", pkcs8EC)} {
+		priv, err := ParsePrivateKey(data)
+		require.NoError(t, err)
+		assert.Equal(t, ecPub.ID, priv.ID)
+		assert.Nil(t, priv.RSA())
+	}
+
+	_, err = ParsePrivateKey(encode("CERTIFICATE", []byte{1}))
+	assert.Error(t, err)
+	_, err = ParsePublicKey([]byte("not pem"))
+	assert.Error(t, err)
+}
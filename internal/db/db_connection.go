@@ -2,27 +2,34 @@ package db
 
 import (
 	"context"
-	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"log"
 )
 
+// Database хранит метрики и состояние сервера в PostgreSQL. Запросы идут через пул соединений,
+// поэтому сохранение снимков, загрузка метрик, состояние алертов и проверки готовности
+// не мешают друг другу.
 type Database struct {
-	Conn *pgx.Conn
+	Pool *pgxpool.Pool
 }
 
 func Connect(connstring string) (Database, error) {
 	var db Database
 
 	ctx := context.Background()
-	connConfig, err := pgx.ParseConfig(connstring)
+	poolConfig, err := pgxpool.ParseConfig(connstring)
 	if err != nil {
 		return db, err
 	}
 
-	db.Conn, err = pgx.ConnectConfig(ctx, connConfig)
+	db.Pool, err = pgxpool.NewWithConfig(ctx, poolConfig)
 	if err != nil {
 		return db, err
 	}
+	if err = db.Pool.Ping(ctx); err != nil {
+		db.Pool.Close()
+		return db, err
+	}
 
 	log.Println("Connected to the database successfully")
 
@@ -36,5 +43,5 @@ func Connect(connstring string) (Database, error) {
 }
 
 func (db *Database) Close() {
-	db.Conn.Close(context.Background())
+	db.Pool.Close()
 }
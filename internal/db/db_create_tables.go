@@ -6,7 +6,7 @@ import (
 )
 
 func (db *Database) createTables() error {
-	_, err := db.Conn.Exec(context.Background(), `CREATE TABLE IF NOT EXISTS gauge_metrics(
+	_, err := db.Pool.Exec(context.Background(), `CREATE TABLE IF NOT EXISTS gauge_metrics(
         id serial PRIMARY KEY,
         name text,
         value double precision,
@@ -16,7 +16,7 @@ func (db *Database) createTables() error {
 		return err
 	}
 
-	_, err = db.Conn.Exec(context.Background(), `CREATE TABLE IF NOT EXISTS counter_metrics(
+	_, err = db.Pool.Exec(context.Background(), `CREATE TABLE IF NOT EXISTS counter_metrics(
         id serial PRIMARY KEY,
         name text,
         value integer,
@@ -26,6 +26,15 @@ func (db *Database) createTables() error {
 		return err
 	}
 
+	_, err = db.Pool.Exec(context.Background(), `CREATE TABLE IF NOT EXISTS server_state(
+        key text PRIMARY KEY,
+        data bytea,
+        timestamp timestamp)`)
+	if err != nil {
+		log.Println("Error creating server_state table:", err)
+		return err
+	}
+
 	log.Println("Tables created successfully")
 	return nil
 }
@@ -0,0 +1,31 @@
+package db
+
+import (
+	"context"
+
+	"github.com/RomanenkoDR/metrics/internal/storage"
+)
+
+// Implements storage.Dropper interface
+// Drop удаляет все сохранённые значения метрик, чтобы RestoreData их не вернул
+func (db *Database) Drop(_ storage.MemStorage, counters, gauges []string) error {
+	ctx := context.Background()
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if len(counters) > 0 {
+		if _, err := tx.Exec(ctx, `DELETE FROM counter_metrics WHERE name = ANY($1)`, counters); err != nil {
+			return err
+		}
+	}
+	if len(gauges) > 0 {
+		if _, err := tx.Exec(ctx, `DELETE FROM gauge_metrics WHERE name = ANY($1)`, gauges); err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
@@ -1,11 +1,62 @@
 package db
 
-import "github.com/RomanenkoDR/metrics/internal/storage"
+import (
+	"context"
+	"time"
+
+	"github.com/RomanenkoDR/metrics/internal/storage"
+)
 
 // Implements StorageWriter interface
+// RestoreData загружает последнее сохранённое значение каждой метрики
 func (db *Database) RestoreData(s *storage.MemStorage) error {
-	//to do
+	ctx := context.Background()
 
-	return nil
+	counters := map[string]storage.Counter{}
+	updated := map[string]time.Time{}
+	rows, err := db.Pool.Query(ctx,
+		`SELECT DISTINCT ON (name) name, value, timestamp FROM counter_metrics ORDER BY name, timestamp DESC`)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var name string
+		var value int64
+		var ts time.Time
+		if err := rows.Scan(&name, &value, &ts); err != nil {
+			rows.Close()
+			return err
+		}
+		counters[name] = storage.Counter(value)
+		updated[storage.Key(storage.CounterType, name)] = ts
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
 
+	gauges := map[string]storage.Gauge{}
+	rows, err = db.Pool.Query(ctx,
+		`SELECT DISTINCT ON (name) name, value, timestamp FROM gauge_metrics ORDER BY name, timestamp DESC`)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var name string
+		var value float64
+		var ts time.Time
+		if err := rows.Scan(&name, &value, &ts); err != nil {
+			rows.Close()
+			return err
+		}
+		gauges[name] = storage.Gauge(value)
+		updated[storage.Key(storage.GaugeType, name)] = ts
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	s.Restore(counters, gauges, updated)
+	return nil
 }
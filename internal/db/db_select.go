@@ -6,7 +6,7 @@ import (
 )
 
 func (db *Database) SelectAll() error {
-	rows, err := db.Conn.Query(context.Background(),
+	rows, err := db.Pool.Query(context.Background(),
 		`SELECT * FROM counter_metrics
                            UNION
                            SELECT * FROM gauge_metrics
@@ -0,0 +1,30 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Implements StateStore interface
+// SaveState сохраняет служебное состояние сервера по ключу
+func (db *Database) SaveState(key string, data []byte) error {
+	_, err := db.Pool.Exec(context.Background(),
+		`INSERT INTO server_state (key, data, timestamp) VALUES ($1, $2, $3)
+         ON CONFLICT (key) DO UPDATE SET data = EXCLUDED.data, timestamp = EXCLUDED.timestamp`,
+		key, data, time.Now())
+	return err
+}
+
+// LoadState возвращает сохранённое состояние или nil, если его нет
+func (db *Database) LoadState(key string) ([]byte, error) {
+	var data []byte
+	err := db.Pool.QueryRow(context.Background(),
+		`SELECT data FROM server_state WHERE key = $1`, key).Scan(&data)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	return data, err
+}
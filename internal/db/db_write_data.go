@@ -3,12 +3,30 @@ package db
 import (
 	"context"
 	"github.com/RomanenkoDR/metrics/internal/storage"
+	"github.com/RomanenkoDR/metrics/internal/telemetry"
 	"time"
 )
 
+// Write сохраняет все метрики в одной транзакции: при ошибке не сохраняется ничего
 func (db *Database) Write(s storage.MemStorage) error {
-	for k, v := range s.CounterData {
-		_, err := db.Conn.Exec(context.Background(),
+	err := db.write(s)
+	if err != nil {
+		telemetry.Default.Inc("db_flush_errors_total", nil)
+	}
+	return err
+}
+
+func (db *Database) write(s storage.MemStorage) error {
+	ctx := context.Background()
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	for k, v := range s.GetAllCounters() {
+		_, err := tx.Exec(ctx,
 			`INSERT INTO counter_metrics (name, value, timestamp) VALUES ($1, $2, $3)`,
 			k, v, time.Now())
 		if err != nil {
@@ -16,15 +34,15 @@ func (db *Database) Write(s storage.MemStorage) error {
 		}
 	}
 
-	for k, v := range s.GaugeData {
-		_, err := db.Conn.Exec(context.Background(),
+	for k, v := range s.GetAllGauge() {
+		_, err := tx.Exec(ctx,
 			`INSERT INTO gauge_metrics (name, value, timestamp) VALUES ($1, $2, $3)`,
 			k, v, time.Now())
 		if err != nil {
 			return err
 		}
 	}
-	return nil
+	return tx.Commit(ctx)
 }
 
 func (db *Database) Save(t int, s storage.MemStorage) error {
@@ -0,0 +1,121 @@
+// Package forecast оценивает тренд gauge-метрики по недавней истории и предсказывает,
+// когда значение пересечёт заданный порог
+package forecast
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/RomanenkoDR/metrics/internal/storage"
+)
+
+// Методы построения тренда
+const (
+	Linear = "linear" // линейная регрессия методом наименьших квадратов
+	Holt   = "holt"   // двойное экспоненциальное сглаживание Хольта
+)
+
+// Параметры сглаживания метода Хольта
+const (
+	holtAlpha = 0.5 // вес нового значения в уровне
+	holtBeta  = 0.3 // вес нового значения в тренде
+)
+
+// ErrNotEnoughData возвращается, если в истории меньше двух точек с разным временем
+var ErrNotEnoughData = errors.New("not enough data for forecast")
+
+// Forecast — тренд метрики на момент последней точки истории
+type Forecast struct {
+	Method string    `json:"method"`
+	Points int       `json:"points"` // сколько точек истории использовано
+	At     time.Time `json:"at"`     // время последней точки
+	Level  float64   `json:"level"`  // значение тренда в момент At
+	Slope  float64   `json:"slope"`  // изменение значения в секунду
+}
+
+// Fit строит тренд по точкам истории, упорядоченным от старых к новым
+func Fit(method string, points []storage.Point) (Forecast, error) {
+	f := Forecast{Method: method, Points: len(points)}
+	if len(points) < 2 || !points[len(points)-1].Time.After(points[0].Time) {
+		return f, ErrNotEnoughData
+	}
+	f.At = points[len(points)-1].Time
+
+	switch method {
+	case Linear:
+		f.Level, f.Slope = linear(points)
+	case Holt:
+		f.Level, f.Slope = holt(points)
+	default:
+		return f, fmt.Errorf("unknown forecast method %q", method)
+	}
+	return f, nil
+}
+
+// ValueAt возвращает значение тренда в момент t
+func (f Forecast) ValueAt(t time.Time) float64 {
+	return f.Level + f.Slope*t.Sub(f.At).Seconds()
+}
+
+// Crossing возвращает момент, когда тренд достигнет порога. Если уровень в момент At равен порогу,
+// возвращается At. Если тренд не меняется или удаляется от порога, ok равен false — в том числе
+// когда уровень уже за порогом: прогноз смотрит только вперёд, прошедшее пересечение не ищется.
+func (f Forecast) Crossing(threshold float64) (at time.Time, ok bool) {
+	diff := threshold - f.Level
+	if diff == 0 {
+		return f.At, true
+	}
+	if f.Slope == 0 || math.Signbit(diff) != math.Signbit(f.Slope) {
+		return time.Time{}, false
+	}
+
+	seconds := diff / f.Slope
+	// Слишком далёкое пересечение не помещается в time.Duration
+	if seconds > float64(math.MaxInt64/int64(time.Second)) {
+		return time.Time{}, false
+	}
+	return f.At.Add(time.Duration(seconds * float64(time.Second))), true
+}
+
+// linear возвращает значение прямой наименьших квадратов в момент последней точки и её наклон
+func linear(points []storage.Point) (level, slope float64) {
+	start := points[0].Time
+	n := float64(len(points))
+
+	var sumX, sumY, sumXY, sumXX float64
+	for _, p := range points {
+		x := p.Time.Sub(start).Seconds()
+		sumX += x
+		sumY += p.Value
+		sumXY += x * p.Value
+		sumXX += x * x
+	}
+
+	denom := n*sumXX - sumX*sumX
+	if denom == 0 {
+		return sumY / n, 0
+	}
+	slope = (n*sumXY - sumX*sumY) / denom
+	intercept := (sumY - slope*sumX) / n
+
+	last := points[len(points)-1].Time.Sub(start).Seconds()
+	return intercept + slope*last, slope
+}
+
+// holt сглаживает уровень и тренд по шагам истории. Тренд за шаг переводится в секунды
+// через средний интервал между точками.
+func holt(points []storage.Point) (level, slope float64) {
+	level = points[0].Value
+	trend := points[1].Value - points[0].Value
+
+	for _, p := range points[1:] {
+		prev := level
+		level = holtAlpha*p.Value + (1-holtAlpha)*(level+trend)
+		trend = holtBeta*(level-prev) + (1-holtBeta)*trend
+	}
+
+	step := points[len(points)-1].Time.Sub(points[0].Time).Seconds() / float64(len(points)-1)
+	return level, trend / step
+}
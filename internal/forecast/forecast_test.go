@@ -0,0 +1,86 @@
+package forecast
+
+import (
+	"testing"
+	"time"
+
+	"github.com/RomanenkoDR/metrics/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var start = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// line строит точки y = base + step*i с интервалом в минуту
+func line(n int, base, step float64) []storage.Point {
+	points := make([]storage.Point, n)
+	for i := range points {
+		points[i] = storage.Point{Time: start.Add(time.Duration(i) * time.Minute), Value: base + step*float64(i)}
+	}
+	return points
+}
+
+func TestFit(t *testing.T) {
+	points := line(10, 100, 60) // +1 в секунду
+
+	for _, method := range []string{Linear, Holt} {
+		t.Run(method, func(t *testing.T) {
+			f, err := Fit(method, points)
+			require.NoError(t, err)
+			assert.InDelta(t, 640, f.Level, 1e-6)
+			assert.InDelta(t, 1, f.Slope, 1e-6)
+			assert.Equal(t, points[9].Time, f.At)
+
+			at, ok := f.Crossing(1000)
+			require.True(t, ok)
+			assert.Equal(t, f.At.Add(360*time.Second), at)
+			assert.InDelta(t, 1000, f.ValueAt(at), 1e-6)
+
+			_, ok = f.Crossing(0)
+			assert.False(t, ok, "trend goes away from threshold")
+		})
+	}
+}
+
+func TestFitErrors(t *testing.T) {
+	_, err := Fit(Linear, line(1, 0, 0))
+	assert.ErrorIs(t, err, ErrNotEnoughData)
+
+	_, err = Fit(Linear, []storage.Point{{Time: start, Value: 1}, {Time: start, Value: 2}})
+	assert.ErrorIs(t, err, ErrNotEnoughData)
+
+	_, err = Fit("arima", line(3, 0, 1))
+	assert.Error(t, err)
+}
+
+func TestCrossingFlat(t *testing.T) {
+	f, err := Fit(Linear, line(5, 10, 0))
+	require.NoError(t, err)
+
+	_, ok := f.Crossing(20)
+	assert.False(t, ok)
+
+	at, ok := f.Crossing(10)
+	require.True(t, ok)
+	assert.Equal(t, f.At, at)
+}
+
+func TestCrossingPastThreshold(t *testing.T) {
+	rising, err := Fit(Linear, line(5, 100, 60))
+	require.NoError(t, err)
+	falling, err := Fit(Linear, line(5, 100, -60))
+	require.NoError(t, err)
+
+	// Уровень уже выше порога и продолжает расти — пересечения впереди нет
+	_, ok := rising.Crossing(rising.Level - 10)
+	assert.False(t, ok)
+
+	// Уровень выше порога и падает — пересечение впереди
+	at, ok := falling.Crossing(falling.Level - 10)
+	require.True(t, ok)
+	assert.Equal(t, falling.At.Add(10*time.Second), at)
+
+	// Уровень ниже порога и падает — пересечения впереди нет
+	_, ok = falling.Crossing(falling.Level + 10)
+	assert.False(t, ok)
+}
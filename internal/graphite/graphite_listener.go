@@ -0,0 +1,273 @@
+package graphite
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/RomanenkoDR/metrics/internal/audit"
+	"github.com/RomanenkoDR/metrics/internal/middleware/logger"
+	"github.com/RomanenkoDR/metrics/internal/middleware/subnet"
+	"github.com/RomanenkoDR/metrics/internal/storage"
+	"github.com/RomanenkoDR/metrics/internal/telemetry"
+	"go.uber.org/zap"
+)
+
+// defaultReadTimeout — сколько соединение может простаивать без новых строк
+const defaultReadTimeout = time.Minute
+
+// AuditMethod — метод и маршрут записей журнала аудита о соединениях Graphite
+const AuditMethod = "graphite"
+
+// Listener принимает метрики по plaintext-протоколу Graphite ("path value timestamp")
+type Listener struct {
+	Addr        string
+	Rules       Rules
+	Store       storage.MemStorage
+	ReadTimeout time.Duration
+	// Trusted — подсети, из которых принимаются соединения; пустой список не ограничивает клиентов.
+	// У протокола нет заголовков, поэтому проверяется адрес соединения.
+	Trusted subnet.Trusted
+	// Audit — журнал аудита, куда записывается каждое соединение; nil отключает запись
+	Audit *audit.Log
+
+	mu       sync.Mutex
+	ln       net.Listener
+	conns    map[net.Conn]struct{}
+	wg       sync.WaitGroup
+	shutdown bool
+}
+
+// NewListener создаёт слушатель Graphite, пишущий метрики в хранилище store
+func NewListener(addr string, rules Rules, store storage.MemStorage) *Listener {
+	return &Listener{
+		Addr:        addr,
+		Rules:       rules,
+		Store:       store,
+		ReadTimeout: defaultReadTimeout,
+		conns:       map[net.Conn]struct{}{},
+	}
+}
+
+// Listen открывает TCP-порт. Вызывается до Serve, чтобы ошибки адреса были видны сразу.
+func (l *Listener) Listen() error {
+	ln, err := net.Listen("tcp", l.Addr)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	l.ln = ln
+	l.mu.Unlock()
+	return nil
+}
+
+// Serve принимает соединения до вызова Close. Каждое соединение обрабатывается в своей горутине.
+func (l *Listener) Serve() error {
+	l.mu.Lock()
+	ln := l.ln
+	l.mu.Unlock()
+	if ln == nil {
+		return errors.New("graphite listener is not started")
+	}
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			l.mu.Lock()
+			shutdown := l.shutdown
+			l.mu.Unlock()
+			if shutdown {
+				return nil
+			}
+			return err
+		}
+
+		if !l.trusted(conn) {
+			telemetry.Default.Inc("untrusted_requests_total", telemetry.Labels{"reason": "graphite"})
+			logger.Warn("Соединение Graphite не из доверенной подсети", zap.String("remote", conn.RemoteAddr().String()))
+			l.audit(conn, http.StatusForbidden, 0)
+			conn.Close()
+			continue
+		}
+
+		// Как и HTTP, Graphite не принимает изменения, которые нельзя записать в журнал аудита
+		if l.Audit != nil && l.Audit.Err() != nil {
+			logger.Warn("Соединение Graphite отклонено: журнал аудита недоступен", zap.String("remote", conn.RemoteAddr().String()))
+			l.audit(conn, http.StatusServiceUnavailable, 0)
+			conn.Close()
+			continue
+		}
+
+		// Соединение, принятое одновременно с Close, уже не попадёт в список закрываемых
+		l.mu.Lock()
+		if l.shutdown {
+			l.mu.Unlock()
+			conn.Close()
+			return nil
+		}
+		l.conns[conn] = struct{}{}
+		l.wg.Add(1)
+		l.mu.Unlock()
+
+		go l.handleConn(conn)
+	}
+}
+
+// trusted сообщает, пришло ли соединение из доверенной подсети
+func (l *Listener) trusted(conn net.Conn) bool {
+	if len(l.Trusted) == 0 {
+		return true
+	}
+	addr, ok := conn.RemoteAddr().(*net.TCPAddr)
+	return ok && l.Trusted.Contains(addr.IP)
+}
+
+// audit записывает соединение в журнал аудита. Токенов у протокола нет, поэтому субъектом
+// считается адрес клиента, а код ответа — как у HTTP: 200 для принятого соединения, 403 для
+// соединения не из доверенной подсети и 503, пока журнал недоступен.
+func (l *Listener) audit(conn net.Conn, status, metrics int) {
+	if l.Audit == nil {
+		return
+	}
+
+	client := conn.RemoteAddr().String()
+	if host, _, err := net.SplitHostPort(client); err == nil {
+		client = host
+	}
+	client = "ip:" + client
+
+	_, err := l.Audit.Append(audit.Entry{
+		Actor:   client,
+		Client:  client,
+		Method:  AuditMethod,
+		Route:   AuditMethod,
+		Path:    l.Addr,
+		Status:  status,
+		Metrics: metrics,
+	})
+	if err != nil {
+		telemetry.Default.Inc("audit_failures_total", nil)
+		logger.Error("Ошибка записи в журнал аудита", zap.String("remote", conn.RemoteAddr().String()), zap.Error(err))
+	}
+}
+
+// Close прекращает приём соединений, закрывает открытые соединения и дожидается их обработчиков
+func (l *Listener) Close() error {
+	l.mu.Lock()
+	l.shutdown = true
+	var err error
+	if l.ln != nil {
+		err = l.ln.Close()
+	}
+	for conn := range l.conns {
+		conn.Close()
+	}
+	l.mu.Unlock()
+
+	l.wg.Wait()
+	return err
+}
+
+// handleConn читает строки из соединения, пока клиент не закроет его или не истечёт таймаут
+func (l *Listener) handleConn(conn net.Conn) {
+	metrics := 0
+	defer func() {
+		conn.Close()
+		l.audit(conn, http.StatusOK, metrics)
+		l.mu.Lock()
+		delete(l.conns, conn)
+		l.mu.Unlock()
+		l.wg.Done()
+	}()
+
+	scanner := bufio.NewScanner(conn)
+	for {
+		conn.SetReadDeadline(time.Now().Add(l.ReadTimeout))
+		if !scanner.Scan() {
+			break
+		}
+
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		if err := l.apply(line); err != nil {
+			logger.Warn("Некорректная строка Graphite", zap.String("line", line), zap.Error(err))
+			continue
+		}
+		metrics++
+	}
+
+	var netErr net.Error
+	if err := scanner.Err(); err != nil && !(errors.As(err, &netErr) && netErr.Timeout()) && !errors.Is(err, net.ErrClosed) {
+		logger.Warn("Ошибка чтения соединения Graphite", zap.String("remote", conn.RemoteAddr().String()), zap.Error(err))
+	}
+}
+
+// apply разбирает строку и записывает метрику в хранилище
+func (l *Listener) apply(line string) error {
+	path, value, err := ParseLine(line)
+	if err != nil {
+		return err
+	}
+
+	// Теги в формате Graphite (path;tag=value) переносятся в метки
+	path, tags := storage.SplitLabels(path)
+
+	name, labels, metricType := l.Rules.Resolve(path)
+	for k, v := range tags {
+		if labels == nil {
+			labels = map[string]string{}
+		}
+		if _, ok := labels[k]; !ok {
+			labels[k] = v
+		}
+	}
+	if telemetry.Reserved(name) {
+		return fmt.Errorf("metric name prefix %s is reserved for server metrics", telemetry.Prefix)
+	}
+	id := storage.JoinLabels(name, labels)
+
+	switch metricType {
+	case counterType:
+		if value != math.Trunc(value) {
+			return fmt.Errorf("counter value %v is not an integer", value)
+		}
+		l.Store.UpdateCounter(id, storage.Counter(value))
+	default:
+		l.Store.UpdateGauge(id, storage.Gauge(value))
+	}
+	return nil
+}
+
+// ParseLine разбирает строку plaintext-протокола Graphite и возвращает путь и значение.
+// Временная метка проверяется, но не используется: хранилище держит только последнее значение.
+func ParseLine(line string) (string, float64, error) {
+	fields := strings.Fields(line)
+	if len(fields) != 3 {
+		return "", 0, fmt.Errorf("expected 3 fields, got %d", len(fields))
+	}
+
+	value, err := strconv.ParseFloat(fields[1], 64)
+	if err != nil {
+		return "", 0, fmt.Errorf("incorrect value: %w", err)
+	}
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return "", 0, fmt.Errorf("incorrect value %q", fields[1])
+	}
+
+	if _, err := strconv.ParseFloat(fields[2], 64); err != nil {
+		return "", 0, fmt.Errorf("incorrect timestamp: %w", err)
+	}
+
+	return fields[0], value, nil
+}
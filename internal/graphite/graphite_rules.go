@@ -0,0 +1,128 @@
+package graphite
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	counterType = "counter"
+	gaugeType   = "gauge"
+)
+
+// Rule описывает преобразование пути Graphite в метрику сервера.
+// Match — шаблон пути через точку, где "*" совпадает ровно с одним сегментом.
+// Совпавшие сегменты доступны в Name и Labels как $1, $2 и т.д.
+type Rule struct {
+	Match  string            `json:"match"`
+	Name   string            `json:"name"`
+	Type   string            `json:"type"`
+	Labels map[string]string `json:"labels"`
+
+	segments []string
+}
+
+// Rules — упорядоченный список правил, применяется первое совпавшее
+type Rules []Rule
+
+// rulesFile — формат JSON-файла с правилами
+type rulesFile struct {
+	Rules Rules `json:"rules"`
+}
+
+// LoadRules загружает правила сопоставления из JSON-файла
+func LoadRules(path string) (Rules, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var rf rulesFile
+	err = json.NewDecoder(file).Decode(&rf)
+	if err != nil {
+		return nil, err
+	}
+
+	err = rf.Rules.compile()
+	if err != nil {
+		return nil, err
+	}
+	return rf.Rules, nil
+}
+
+// compile проверяет правила и подготавливает шаблоны к сопоставлению
+func (rs Rules) compile() error {
+	for i := range rs {
+		r := &rs[i]
+		if r.Match == "" {
+			return fmt.Errorf("rule %d: empty match", i)
+		}
+		switch r.Type {
+		case "":
+			r.Type = gaugeType
+		case counterType, gaugeType:
+		default:
+			return fmt.Errorf("rule %d: incorrect metric type %q", i, r.Type)
+		}
+		r.segments = strings.Split(r.Match, ".")
+	}
+	return nil
+}
+
+// match сопоставляет путь с правилом и возвращает значения сегментов под "*"
+func (r *Rule) match(path []string) ([]string, bool) {
+	if len(path) != len(r.segments) {
+		return nil, false
+	}
+
+	var captures []string
+	for i, s := range r.segments {
+		switch {
+		case s == "*":
+			captures = append(captures, path[i])
+		case s != path[i]:
+			return nil, false
+		}
+	}
+	return captures, true
+}
+
+// expand подставляет захваченные сегменты вместо $1..$n
+func expand(tmpl string, captures []string) string {
+	// Заменяем с конца, чтобы $1 не затронул $10
+	for i := len(captures); i >= 1; i-- {
+		tmpl = strings.ReplaceAll(tmpl, "$"+strconv.Itoa(i), captures[i-1])
+	}
+	return tmpl
+}
+
+// Resolve возвращает имя, метки и тип метрики для пути Graphite.
+// Если ни одно правило не подошло, путь сохраняется как gauge без изменений.
+func (rs Rules) Resolve(path string) (string, map[string]string, string) {
+	segments := strings.Split(path, ".")
+	for i := range rs {
+		captures, ok := rs[i].match(segments)
+		if !ok {
+			continue
+		}
+
+		name := path
+		if rs[i].Name != "" {
+			name = expand(rs[i].Name, captures)
+		}
+
+		var labels map[string]string
+		if len(rs[i].Labels) > 0 {
+			labels = make(map[string]string, len(rs[i].Labels))
+			for k, v := range rs[i].Labels {
+				labels[k] = expand(v, captures)
+			}
+		}
+		return name, labels, rs[i].Type
+	}
+	return path, nil, gaugeType
+}
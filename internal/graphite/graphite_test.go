@@ -0,0 +1,166 @@
+package graphite
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/RomanenkoDR/metrics/internal/audit"
+	"github.com/RomanenkoDR/metrics/internal/middleware/subnet"
+	"github.com/RomanenkoDR/metrics/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLine(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		path    string
+		value   float64
+		wantErr bool
+	}{
+		{"Valid", "servers.a.cpu 1.5 1700000000", "servers.a.cpu", 1.5, false},
+		{"Tagged", "cpu;host=a 2 1700000000", "cpu;host=a", 2, false},
+		{"Missing timestamp", "servers.a.cpu 1.5", "", 0, true},
+		{"Bad value", "servers.a.cpu abc 1700000000", "", 0, true},
+		{"NaN value", "servers.a.cpu NaN 1700000000", "", 0, true},
+		{"Bad timestamp", "servers.a.cpu 1 now", "", 0, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			path, value, err := ParseLine(tc.line)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.path, path)
+			assert.Equal(t, tc.value, value)
+		})
+	}
+}
+
+func TestRulesResolve(t *testing.T) {
+	rules := Rules{
+		{Match: "servers.*.cpu.load", Name: "cpu_load", Labels: map[string]string{"host": "$1"}},
+		{Match: "jobs.*.processed", Name: "$1_processed", Type: counterType},
+	}
+	require.NoError(t, rules.compile())
+
+	name, labels, typ := rules.Resolve("servers.web1.cpu.load")
+	assert.Equal(t, "cpu_load", name)
+	assert.Equal(t, map[string]string{"host": "web1"}, labels)
+	assert.Equal(t, gaugeType, typ)
+
+	name, labels, typ = rules.Resolve("jobs.backup.processed")
+	assert.Equal(t, "backup_processed", name)
+	assert.Nil(t, labels)
+	assert.Equal(t, counterType, typ)
+
+	name, _, typ = rules.Resolve("unknown.path")
+	assert.Equal(t, "unknown.path", name)
+	assert.Equal(t, gaugeType, typ)
+
+	assert.Error(t, Rules{{Match: "a.b", Type: "histogram"}}.compile())
+}
+
+func TestListener(t *testing.T) {
+	rules := Rules{
+		{Match: "servers.*.cpu.load", Name: "cpu_load", Labels: map[string]string{"host": "$1"}},
+		{Match: "jobs.*.processed", Name: "$1_processed", Type: counterType},
+	}
+	require.NoError(t, rules.compile())
+
+	log, err := audit.Open(filepath.Join(t.TempDir(), "audit.log"))
+	require.NoError(t, err)
+	defer log.Close()
+
+	store := storage.New()
+	l := NewListener("127.0.0.1:0", rules, store)
+	l.Audit = log
+	require.NoError(t, l.Listen())
+
+	done := make(chan error, 1)
+	go func() { done <- l.Serve() }()
+
+	conn, err := net.Dial("tcp", l.ln.Addr().String())
+	require.NoError(t, err)
+
+	ts := time.Now().Unix()
+	fmt.Fprintf(conn, "servers.web1.cpu.load 0.75 %d\n", ts)
+	fmt.Fprintf(conn, "jobs.backup.processed 3 %d\n", ts)
+	fmt.Fprintf(conn, "jobs.backup.processed 2 %d\n", ts)
+	fmt.Fprintf(conn, "broken line\n")
+	fmt.Fprintf(conn, "metrics_server_series 100 %d\n", ts)
+	conn.Close()
+
+	require.Eventually(t, func() bool {
+		v, ok := store.GetCounter("backup_processed")
+		return ok && v == 5
+	}, time.Second, 10*time.Millisecond)
+
+	v, ok := store.GetGauge("cpu_load;host=web1")
+	assert.True(t, ok)
+	assert.Equal(t, storage.Gauge(0.75), v)
+	_, ok = store.GetGauge("metrics_server_series")
+	assert.False(t, ok, "reserved prefix is rejected")
+
+	require.NoError(t, l.Close())
+	assert.NoError(t, <-done)
+
+	entries, err := log.Query(audit.Filter{})
+	require.NoError(t, err)
+	require.Len(t, entries, 1, "one entry per connection")
+	assert.Equal(t, "ip:127.0.0.1", entries[0].Actor)
+	assert.Equal(t, AuditMethod, entries[0].Method)
+	assert.Equal(t, http.StatusOK, entries[0].Status)
+	assert.Equal(t, 3, entries[0].Metrics, "rejected lines are not counted")
+}
+
+func TestListenerUntrusted(t *testing.T) {
+	trusted, err := subnet.Parse("10.0.0.0/8")
+	require.NoError(t, err)
+
+	log, err := audit.Open(filepath.Join(t.TempDir(), "audit.log"))
+	require.NoError(t, err)
+	defer log.Close()
+
+	store := storage.New()
+	l := NewListener("127.0.0.1:0", nil, store)
+	l.Trusted = trusted
+	l.Audit = log
+	require.NoError(t, l.Listen())
+
+	done := make(chan error, 1)
+	go func() { done <- l.Serve() }()
+
+	conn, err := net.Dial("tcp", l.ln.Addr().String())
+	require.NoError(t, err)
+	fmt.Fprintf(conn, "cpu.load 0.75 %d\n", time.Now().Unix())
+
+	// Соединение не из доверенной подсети закрывается без чтения
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, err = conn.Read(make([]byte, 1))
+	var netErr net.Error
+	require.Error(t, err)
+	assert.False(t, errors.As(err, &netErr) && netErr.Timeout(), "connection should be closed by the server")
+	conn.Close()
+
+	_, ok := store.GetGauge("cpu.load")
+	assert.False(t, ok)
+
+	require.NoError(t, l.Close())
+	assert.NoError(t, <-done)
+
+	entries, err := log.Query(audit.Filter{})
+	require.NoError(t, err)
+	require.Len(t, entries, 1, "rejected connection is recorded")
+	assert.Equal(t, http.StatusForbidden, entries[0].Status)
+	assert.Zero(t, entries[0].Metrics)
+}
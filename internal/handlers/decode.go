@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// decodeJSONArray читает массив JSON поэлементно, не загружая его в память целиком,
+// и передаёт каждый элемент в add. Данные после закрывающей скобки считаются ошибкой.
+func decodeJSONArray[T any](r io.Reader, add func(T) error) error {
+	dec := json.NewDecoder(r)
+	t, err := dec.Token()
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	if t != json.Delim('[') {
+		return errors.New("body should be a JSON array")
+	}
+
+	for n := 1; dec.More(); n++ {
+		var item T
+		if err := dec.Decode(&item); err != nil {
+			return fmt.Errorf("record %d: %w", n, err)
+		}
+		if err := add(item); err != nil {
+			return fmt.Errorf("record %d: %w", n, err)
+		}
+	}
+
+	if _, err := dec.Token(); err != nil {
+		return err
+	}
+	return decodeEnd(dec)
+}
+
+// decodeJSON читает из r один объект JSON. Данные после объекта считаются ошибкой.
+func decodeJSON(r io.Reader, v any) error {
+	dec := json.NewDecoder(r)
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	return decodeEnd(dec)
+}
+
+// decodeEnd проверяет, что после разобранного значения в потоке нет других данных
+func decodeEnd(dec *json.Decoder) error {
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		if err != nil {
+			return err
+		}
+		return errors.New("unexpected data after JSON value")
+	}
+	return nil
+}
@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/RomanenkoDR/metrics/internal/audit"
+	"github.com/RomanenkoDR/metrics/internal/middleware/logger"
+	"github.com/RomanenkoDR/metrics/internal/problem"
+	"github.com/RomanenkoDR/metrics/internal/storage"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// logLevel — тело запроса и ответа /admin/log-level
+type logLevel struct {
+	Level string `json:"level"`
+}
+
+// HandleAdminFlush немедленно сохраняет метрики во внешнее хранилище. Запись идёт через тот же
+// h.Writer, что и периодическое сохранение, и выполняется в очереди с ним.
+func (h *Handler) HandleAdminFlush(w http.ResponseWriter, r *http.Request) {
+	if h.Writer == nil {
+		problem.Write(w, r, http.StatusServiceUnavailable, problem.CodeStorageUnavailable, "storage is not configured")
+		return
+	}
+
+	if err := h.Writer.Write(h.Store); err != nil {
+		logger.Error("Ошибка принудительного сохранения метрик", zap.Error(err))
+		problem.Write(w, r, http.StatusInternalServerError, problem.CodeStorageUnavailable, "can't save metrics")
+		return
+	}
+
+	logger.Info("Метрики сохранены по запросу администратора")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleAdminResetCounters обнуляет счётчик, заданный параметром name, или все счётчики
+func (h *Handler) HandleAdminResetCounters(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("name")
+
+	var reset int
+	if name == "" {
+		reset = h.Store.ResetCounters()
+	} else {
+		if !h.Store.ResetCounter(name) {
+			problem.Write(w, r, http.StatusNotFound, problem.CodeMetricNotFound, "counter "+name+" not found")
+			return
+		}
+		reset = 1
+	}
+
+	audit.Touch(r, reset)
+	logger.Info("Счётчики обнулены", zap.String("name", name), zap.Int("reset", reset))
+	writeJSON(w, http.StatusOK, map[string]int{"reset": reset})
+}
+
+// HandleAdminDrop удаляет метрики, имена которых соответствуют шаблону pattern
+// (синтаксис path.Match). Параметр type ограничивает удаление одним типом метрик.
+// Метрики удаляются и из внешнего хранилища, чтобы не вернуться после перезапуска,
+// а состояние алертов и аномалий по ним сбрасывается.
+func (h *Handler) HandleAdminDrop(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	pattern := q.Get("pattern")
+	if pattern == "" {
+		problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidQuery, "pattern is required, use * to drop all metrics")
+		return
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidQuery, "pattern is malformed")
+		return
+	}
+
+	counters, gauges := true, true
+	switch q.Get("type") {
+	case "":
+	case counterType:
+		gauges = false
+	case gaugeType:
+		counters = false
+	default:
+		problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidMetricType, "metric type should be counter or gauge")
+		return
+	}
+
+	droppedCounters, droppedGauges := h.Store.Drop(func(metric string) bool {
+		ok, _ := path.Match(pattern, metric)
+		return ok
+	}, counters, gauges)
+	h.Alerts.Forget(droppedCounters)
+	h.Anomalies.Forget(droppedGauges)
+
+	dropped := append(append([]string{}, droppedCounters...), droppedGauges...)
+	sort.Strings(dropped)
+	audit.Touch(r, len(dropped))
+
+	if d, ok := h.Writer.(storage.Dropper); ok && len(dropped) > 0 {
+		if err := d.Drop(h.Store, droppedCounters, droppedGauges); err != nil {
+			logger.Error("Ошибка удаления метрик из хранилища", zap.String("pattern", pattern), zap.Error(err))
+			problem.Write(w, r, http.StatusInternalServerError, problem.CodeStorageUnavailable, "metrics are dropped from memory but can't be removed from storage")
+			return
+		}
+	}
+
+	logger.Info("Метрики удалены", zap.String("pattern", pattern), zap.Int("dropped", len(dropped)))
+	writeJSON(w, http.StatusOK, map[string][]string{"dropped": dropped})
+}
+
+// HandleAdminReloadKeys перечитывает ключ подписи и приватный ключ расшифровки
+func (h *Handler) HandleAdminReloadKeys(w http.ResponseWriter, r *http.Request) {
+	if h.Keys == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if err := h.Keys.Reload(); err != nil {
+		logger.Error("Ошибка перечитывания ключей", zap.Error(err))
+		problem.Write(w, r, http.StatusInternalServerError, problem.CodeKeyReloadFailed, err.Error())
+		return
+	}
+
+	logger.Info("Ключи перечитаны")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleAdminLogLevel возвращает текущий уровень журнала
+func (h *Handler) HandleAdminLogLevel(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, logLevel{Level: logger.Level.String()})
+}
+
+// HandleAdminSetLogLevel меняет уровень журнала: debug, info, warn или error
+func (h *Handler) HandleAdminSetLogLevel(w http.ResponseWriter, r *http.Request) {
+	var req logLevel
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		problem.WriteBodyError(w, r, problem.CodeInvalidJSON, err)
+		return
+	}
+
+	level, err := zapcore.ParseLevel(req.Level)
+	if err != nil || level > zapcore.ErrorLevel {
+		problem.Write(w, r, http.StatusBadRequest, problem.CodeBadRequest, "level should be one of debug, info, warn, error")
+		return
+	}
+
+	logger.Level.SetLevel(level)
+	logger.Info("Уровень журнала изменён", zap.Stringer("level", level))
+	writeJSON(w, http.StatusOK, logLevel{Level: level.String()})
+}
+
+// auditResult — ответ /admin/audit
+type auditResult struct {
+	Head    audit.Head    `json:"head"`
+	Entries []audit.Entry `json:"entries"`
+}
+
+// HandleAdminAudit возвращает записи журнала аудита. Параметры from и to (RFC 3339) ограничивают
+// время записи, actor — субъект токена или идентификатор клиента, limit — число последних записей.
+func (h *Handler) HandleAdminAudit(w http.ResponseWriter, r *http.Request) {
+	if h.Audit == nil {
+		problem.Write(w, r, http.StatusNotFound, problem.CodeAuditDisabled, "audit log is not configured")
+		return
+	}
+
+	q := r.URL.Query()
+	filter := audit.Filter{Actor: q.Get("actor")}
+	for _, p := range []struct {
+		name string
+		t    *time.Time
+	}{{"from", &filter.From}, {"to", &filter.To}} {
+		if s := q.Get(p.name); s != "" {
+			v, err := time.Parse(time.RFC3339, s)
+			if err != nil {
+				problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidQuery, p.name+" should be RFC 3339 time")
+				return
+			}
+			*p.t = v
+		}
+	}
+	if s := q.Get("limit"); s != "" {
+		limit, err := strconv.Atoi(s)
+		if err != nil || limit <= 0 {
+			problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidQuery, "limit should be a positive integer")
+			return
+		}
+		filter.Limit = limit
+	}
+
+	entries, err := h.Audit.Query(filter)
+	if err != nil {
+		logger.Error("Ошибка чтения журнала аудита", zap.Error(err))
+		problem.Write(w, r, http.StatusInternalServerError, problem.CodeInternal, "can't read audit log")
+		return
+	}
+	writeJSON(w, http.StatusOK, auditResult{Head: h.Audit.Head(), Entries: entries})
+}
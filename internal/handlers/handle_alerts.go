@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/RomanenkoDR/metrics/internal/alerts"
+	"github.com/RomanenkoDR/metrics/internal/anomaly"
+	"github.com/RomanenkoDR/metrics/internal/notify"
+	"github.com/RomanenkoDR/metrics/internal/problem"
+	"github.com/go-chi/chi/v5"
+)
+
+// HandleAlerts возвращает активные алерты (pending и firing)
+func (h *Handler) HandleAlerts(w http.ResponseWriter, r *http.Request) {
+	active := []alerts.Alert{}
+	if h.Alerts != nil {
+		active = append(active, h.Alerts.Active()...)
+	}
+
+	writeJSON(w, http.StatusOK, active)
+}
+
+// HandleNotifications возвращает состояние доставки уведомлений по каналам
+func (h *Handler) HandleNotifications(w http.ResponseWriter, r *http.Request) {
+	status := notify.Status{Receivers: []notify.ReceiverStatus{}, Deliveries: []notify.Delivery{}}
+	if h.Notifications != nil {
+		status = h.Notifications.Status()
+	}
+
+	writeJSON(w, http.StatusOK, status)
+}
+
+// HandleSilences возвращает список тишин
+func (h *Handler) HandleSilences(w http.ResponseWriter, r *http.Request) {
+	silences := []alerts.Silence{}
+	if h.Alerts != nil {
+		silences = h.Alerts.Silences()
+	}
+	writeJSON(w, http.StatusOK, silences)
+}
+
+// HandleSilenceCreate создаёт тишину по матчерам меток алертов
+func (h *Handler) HandleSilenceCreate(w http.ResponseWriter, r *http.Request) {
+	if h.Alerts == nil {
+		problem.Write(w, r, http.StatusNotFound, problem.CodeAlertingDisabled, "alerting is not configured")
+		return
+	}
+
+	var s alerts.Silence
+	if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
+		problem.WriteBodyError(w, r, problem.CodeInvalidJSON, err)
+		return
+	}
+
+	s, err := h.Alerts.AddSilence(s)
+	if err != nil {
+		problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidSilence, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusCreated, s)
+}
+
+// HandleSilenceExpire досрочно завершает тишину
+func (h *Handler) HandleSilenceExpire(w http.ResponseWriter, r *http.Request) {
+	if h.Alerts == nil {
+		problem.Write(w, r, http.StatusNotFound, problem.CodeAlertingDisabled, "alerting is not configured")
+		return
+	}
+
+	err := h.Alerts.ExpireSilence(chi.URLParam(r, "id"))
+	if err != nil {
+		problem.Write(w, r, http.StatusNotFound, problem.CodeSilenceNotFound, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ackRequest — тело запроса подтверждения алерта
+type ackRequest struct {
+	Author  string `json:"author"`
+	Comment string `json:"comment"`
+}
+
+// HandleAlertAck подтверждает горящий алерт, после чего напоминания по нему не отправляются
+func (h *Handler) HandleAlertAck(w http.ResponseWriter, r *http.Request) {
+	if h.Alerts == nil {
+		problem.Write(w, r, http.StatusNotFound, problem.CodeAlertingDisabled, "alerting is not configured")
+		return
+	}
+
+	var req ackRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		problem.WriteBodyError(w, r, problem.CodeInvalidJSON, err)
+		return
+	}
+
+	a, err := h.Alerts.Ack(chi.URLParam(r, "rule"), req.Author, req.Comment)
+	switch {
+	case errors.Is(err, alerts.ErrAlertNotFound):
+		problem.Write(w, r, http.StatusNotFound, problem.CodeAlertNotFound, err.Error())
+		return
+	case errors.Is(err, alerts.ErrAlertNotFiring):
+		problem.Write(w, r, http.StatusConflict, problem.CodeAlertNotFiring, err.Error())
+		return
+	case err != nil:
+		problem.Write(w, r, http.StatusBadRequest, problem.CodeBadRequest, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, a)
+}
+
+// writeJSON сериализует ответ и отправляет его с заданным статусом
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	resp, err := json.Marshal(v)
+	if err != nil {
+		problem.Write(w, nil, http.StatusInternalServerError, problem.CodeInternal, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(resp)
+}
+
+// HandleAnomalies возвращает текущие и недавно завершившиеся аномалии gauge-метрик
+func (h *Handler) HandleAnomalies(w http.ResponseWriter, r *http.Request) {
+	list := []anomaly.Anomaly{}
+	if h.Anomalies != nil {
+		list = h.Anomalies.Anomalies()
+	}
+	writeJSON(w, http.StatusOK, list)
+}
@@ -0,0 +1,352 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/RomanenkoDR/metrics/internal/audit"
+	"github.com/RomanenkoDR/metrics/internal/middleware/logger"
+	"github.com/RomanenkoDR/metrics/internal/problem"
+	"github.com/RomanenkoDR/metrics/internal/storage"
+	"github.com/RomanenkoDR/metrics/internal/telemetry"
+	"go.uber.org/zap"
+)
+
+// Форматы выгрузки и загрузки
+const (
+	formatNDJSON = "ndjson"
+	formatCSV    = "csv"
+	formatJSON   = "json"
+)
+
+// Режимы загрузки счётчиков
+const (
+	importMerge   = "merge"   // значения прибавляются к текущим
+	importReplace = "replace" // значения заменяют текущие
+)
+
+// csvHeader — заголовок CSV: для counter в value записывается целое значение
+var csvHeader = []string{"id", "type", "value", "timestamp"}
+
+// exportRecord — метрика с временем последнего обновления
+type exportRecord struct {
+	Metrics
+	Timestamp *time.Time `json:"timestamp,omitempty"`
+}
+
+// importResult — итог загрузки
+type importResult struct {
+	Mode     string `json:"mode"`
+	Counters int    `json:"counters"`
+	Gauges   int    `json:"gauges"`
+}
+
+// contentTypes — тип содержимого для каждого формата
+var contentTypes = map[string]string{
+	formatNDJSON: "application/x-ndjson",
+	formatCSV:    "text/csv; charset=utf-8",
+	formatJSON:   "application/json",
+}
+
+// requestFormat определяет формат по параметру format, затем по Content-Type; по умолчанию ndjson
+func requestFormat(r *http.Request) (string, error) {
+	if f := r.URL.Query().Get("format"); f != "" {
+		if _, ok := contentTypes[f]; !ok {
+			return "", fmt.Errorf("format should be one of %s, %s, %s", formatNDJSON, formatCSV, formatJSON)
+		}
+		return f, nil
+	}
+
+	ct, _, _ := strings.Cut(r.Header.Get("Content-Type"), ";")
+	for f, t := range contentTypes {
+		if mediaType, _, _ := strings.Cut(t, ";"); strings.TrimSpace(ct) == mediaType {
+			return f, nil
+		}
+	}
+	return formatNDJSON, nil
+}
+
+// HandleExport выгружает все метрики, отсортированные по типу и имени, в формате ndjson, csv или json
+func (h *Handler) HandleExport(w http.ResponseWriter, r *http.Request) {
+	format, err := requestFormat(r)
+	if err != nil {
+		problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidQuery, err.Error())
+		return
+	}
+
+	records := h.exportRecords()
+
+	w.Header().Set("Content-Type", contentTypes[format])
+	w.Header().Set("Content-Disposition", `attachment; filename="metrics.`+format+`"`)
+	w.WriteHeader(http.StatusOK)
+
+	bw := bufio.NewWriter(w)
+	switch format {
+	case formatNDJSON:
+		enc := json.NewEncoder(bw)
+		for _, rec := range records {
+			if err = enc.Encode(rec); err != nil {
+				break
+			}
+		}
+	case formatJSON:
+		bw.WriteString("[")
+		for i, rec := range records {
+			if i > 0 {
+				bw.WriteString(",")
+			}
+			bw.WriteString("\n")
+			var b []byte
+			if b, err = json.Marshal(rec); err != nil {
+				break
+			}
+			bw.Write(b)
+		}
+		bw.WriteString("\n]\n")
+	case formatCSV:
+		cw := csv.NewWriter(bw)
+		cw.Write(csvHeader)
+		for _, rec := range records {
+			cw.Write(rec.csvRow())
+		}
+		cw.Flush()
+		err = cw.Error()
+	}
+	if err == nil {
+		err = bw.Flush()
+	}
+	// Заголовки уже отправлены, поэтому ошибку можно только записать в журнал
+	if err != nil {
+		logger.Error("Ошибка выгрузки метрик", zap.String("format", format), zap.Error(err))
+	}
+}
+
+// exportRecords собирает снимок всех метрик
+func (h *Handler) exportRecords() []exportRecord {
+	var records []exportRecord
+	add := func(m Metrics) {
+		rec := exportRecord{Metrics: m}
+		if t, ok := h.Store.LastUpdated(m.MType, m.ID); ok {
+			rec.Timestamp = &t
+		}
+		records = append(records, rec)
+	}
+
+	for k, v := range h.Store.GetAllCounters() {
+		delta := int64(v)
+		add(Metrics{ID: k, MType: counterType, Delta: &delta})
+	}
+	for k, v := range h.Store.GetAllGauge() {
+		value := float64(v)
+		add(Metrics{ID: k, MType: gaugeType, Value: &value})
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].MType != records[j].MType {
+			return records[i].MType < records[j].MType
+		}
+		return records[i].ID < records[j].ID
+	})
+	return records
+}
+
+// csvRow записывает метрику строкой CSV
+func (rec exportRecord) csvRow() []string {
+	var value, ts string
+	if rec.Delta != nil {
+		value = strconv.FormatInt(*rec.Delta, 10)
+	}
+	if rec.Value != nil {
+		value = strconv.FormatFloat(*rec.Value, 'g', -1, 64)
+	}
+	if rec.Timestamp != nil {
+		ts = rec.Timestamp.Format(time.RFC3339Nano)
+	}
+	return []string{rec.ID, rec.MType, value, ts}
+}
+
+// importBatch — проверенные значения, ожидающие применения
+type importBatch struct {
+	replace  bool
+	counters map[string]storage.Counter
+	gauges   map[string]storage.Gauge
+	updated  map[string]time.Time
+}
+
+// add проверяет запись и добавляет её в пакет
+func (b *importBatch) add(rec exportRecord) error {
+	if rec.ID == "" {
+		return errors.New("id should not be empty")
+	}
+	if telemetry.Reserved(rec.ID) {
+		return errors.New(reservedDetail)
+	}
+
+	switch rec.MType {
+	case counterType:
+		if rec.Delta == nil {
+			return errors.New("counter should have delta")
+		}
+		// При слиянии повторяющиеся счётчики суммируются, при замене берётся последнее значение
+		if b.replace {
+			b.counters[rec.ID] = storage.Counter(*rec.Delta)
+		} else {
+			b.counters[rec.ID] += storage.Counter(*rec.Delta)
+		}
+	case gaugeType:
+		if rec.Value == nil {
+			return errors.New("gauge should have value")
+		}
+		b.gauges[rec.ID] = storage.Gauge(*rec.Value)
+	default:
+		return errors.New("metric type should be counter or gauge")
+	}
+
+	if rec.Timestamp != nil {
+		b.updated[storage.Key(rec.MType, rec.ID)] = *rec.Timestamp
+	}
+	return nil
+}
+
+// HandleImport загружает метрики в формате выгрузки. Параметр mode задаёт обработку счётчиков:
+// merge (по умолчанию) прибавляет значения к текущим, replace заменяет их. Все записи проверяются
+// до применения; при ошибке проверки или сохранения хранилище не меняется.
+func (h *Handler) HandleImport(w http.ResponseWriter, r *http.Request) {
+	format, err := requestFormat(r)
+	if err != nil {
+		problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidQuery, err.Error())
+		return
+	}
+
+	mode := r.URL.Query().Get("mode")
+	switch mode {
+	case "":
+		mode = importMerge
+	case importMerge, importReplace:
+	default:
+		problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidQuery, "mode should be merge or replace")
+		return
+	}
+
+	batch := importBatch{
+		replace:  mode == importReplace,
+		counters: map[string]storage.Counter{},
+		gauges:   map[string]storage.Gauge{},
+		updated:  map[string]time.Time{},
+	}
+
+	switch format {
+	case formatNDJSON:
+		err = decodeNDJSON(r.Body, batch.add)
+	case formatJSON:
+		err = decodeJSONArray(r.Body, batch.add)
+	case formatCSV:
+		err = decodeCSV(r.Body, batch.add)
+	}
+	if err != nil {
+		problem.WriteBodyError(w, r, problem.CodeInvalidImport, err)
+		return
+	}
+
+	if err := h.Store.Import(batch.counters, batch.gauges, batch.updated, batch.replace, h.Writer); err != nil {
+		logger.Error("Ошибка сохранения загруженных метрик", zap.Error(err))
+		problem.Write(w, r, http.StatusInternalServerError, problem.CodeStorageUnavailable, "can't save imported metrics")
+		return
+	}
+
+	audit.Touch(r, len(batch.counters)+len(batch.gauges))
+	logger.Info("Метрики загружены", zap.String("format", format), zap.String("mode", mode),
+		zap.Int("counters", len(batch.counters)), zap.Int("gauges", len(batch.gauges)))
+	writeJSON(w, http.StatusOK, importResult{Mode: mode, Counters: len(batch.counters), Gauges: len(batch.gauges)})
+}
+
+// decodeNDJSON читает записи по одной на строку
+func decodeNDJSON(r io.Reader, add func(exportRecord) error) error {
+	dec := json.NewDecoder(r)
+	for n := 1; ; n++ {
+		var rec exportRecord
+		err := dec.Decode(&rec)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("record %d: %w", n, err)
+		}
+		if err := add(rec); err != nil {
+			return fmt.Errorf("record %d: %w", n, err)
+		}
+	}
+}
+
+// decodeCSV читает записи CSV с заголовком id,type,value,timestamp
+func decodeCSV(r io.Reader, add func(exportRecord) error) error {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = len(csvHeader)
+
+	header, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if strings.Join(header, ",") != strings.Join(csvHeader, ",") {
+		return fmt.Errorf("header should be %s", strings.Join(csvHeader, ","))
+	}
+
+	for n := 1; ; n++ {
+		row, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		rec, err := parseCSVRow(row)
+		if err == nil {
+			err = add(rec)
+		}
+		if err != nil {
+			return fmt.Errorf("record %d: %w", n, err)
+		}
+	}
+}
+
+// parseCSVRow разбирает строку CSV в запись
+func parseCSVRow(row []string) (exportRecord, error) {
+	rec := exportRecord{Metrics: Metrics{ID: row[0], MType: row[1]}}
+
+	switch rec.MType {
+	case counterType:
+		v, err := strconv.ParseInt(row[2], 10, 64)
+		if err != nil {
+			return rec, errors.New("counter value should be an integer")
+		}
+		rec.Delta = &v
+	case gaugeType:
+		v, err := strconv.ParseFloat(row[2], 64)
+		if err != nil {
+			return rec, errors.New("gauge value should be a number")
+		}
+		rec.Value = &v
+	}
+
+	if row[3] != "" {
+		t, err := time.Parse(time.RFC3339Nano, row[3])
+		if err != nil {
+			return rec, errors.New("timestamp should be in RFC 3339 format")
+		}
+		rec.Timestamp = &t
+	}
+	return rec, nil
+}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/RomanenkoDR/metrics/internal/problem"
+	"github.com/RomanenkoDR/metrics/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingWriter — внешнее хранилище, которое не может сохранить данные
+type failingWriter struct{}
+
+func (failingWriter) Write(storage.MemStorage) error        { return errors.New("disk full") }
+func (failingWriter) RestoreData(*storage.MemStorage) error { return nil }
+func (failingWriter) Save(int, storage.MemStorage) error    { return nil }
+func (failingWriter) Close()                                {}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	src := NewHandler()
+	src.Store.UpdateCounter("PollCount", 5)
+	src.Store.UpdateGauge("HeapAlloc", 1.5)
+
+	for _, format := range []string{formatNDJSON, formatCSV, formatJSON} {
+		t.Run(format, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			src.HandleExport(w, httptest.NewRequest(http.MethodGet, "/export?format="+format, nil))
+			require.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, contentTypes[format], w.Header().Get("Content-Type"))
+
+			dst := NewHandler()
+			dst.Store.UpdateCounter("PollCount", 1)
+
+			// Формат определяется по Content-Type
+			r := httptest.NewRequest(http.MethodPost, "/import", strings.NewReader(w.Body.String()))
+			r.Header.Set("Content-Type", contentTypes[format])
+			w = httptest.NewRecorder()
+			dst.HandleImport(w, r)
+			require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+			var res importResult
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+			assert.Equal(t, importResult{Mode: importMerge, Counters: 1, Gauges: 1}, res)
+
+			c, _ := dst.Store.GetCounter("PollCount")
+			assert.Equal(t, storage.Counter(6), c)
+			g, _ := dst.Store.GetGauge("HeapAlloc")
+			assert.Equal(t, storage.Gauge(1.5), g)
+		})
+	}
+}
+
+func TestHandleImport(t *testing.T) {
+	h := NewHandler()
+	h.Store.UpdateCounter("PollCount", 10)
+
+	post := func(query, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.HandleImport(w, httptest.NewRequest(http.MethodPost, "/import?"+query, strings.NewReader(body)))
+		return w
+	}
+	counter := func() storage.Counter {
+		v, _ := h.Store.GetCounter("PollCount")
+		return v
+	}
+
+	w := post("mode=replace", `{"id":"PollCount","type":"counter","delta":3}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, storage.Counter(3), counter())
+
+	assert.Equal(t, http.StatusBadRequest, post("mode=sum", "").Code)
+	assert.Equal(t, http.StatusBadRequest, post("format=xml", "").Code)
+
+	// Некорректная строка отклоняет всю загрузку
+	w = post("format=csv", "id,type,value,timestamp\nPollCount,counter,1,\nHeapAlloc,gauge,abc,\n")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	var p problem.Problem
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &p))
+	assert.Equal(t, problem.CodeInvalidImport, p.Code)
+	assert.Contains(t, p.Detail, "record 2")
+	assert.Equal(t, storage.Counter(3), counter())
+
+	// Имена собственных метрик сервера не загружаются
+	w = post("", `{"id":"metrics_server_series","type":"gauge","value":1}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "reserved")
+
+	// Если внешнее хранилище недоступно, изменения откатываются
+	h.Writer = failingWriter{}
+	w = post("", `{"id":"PollCount","type":"counter","delta":1}`+"\n"+`{"id":"New","type":"gauge","value":1}`)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, storage.Counter(3), counter())
+	_, ok := h.Store.GetGauge("New")
+	assert.False(t, ok)
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/RomanenkoDR/metrics/internal/forecast"
+	"github.com/RomanenkoDR/metrics/internal/problem"
+	"github.com/RomanenkoDR/metrics/internal/storage"
+)
+
+// forecastResponse — тренд gauge и предсказанное пересечение порога
+type forecastResponse struct {
+	Metric string `json:"metric"`
+	forecast.Forecast
+	Threshold          *float64   `json:"threshold,omitempty"`
+	CrossesAt          *time.Time `json:"crosses_at,omitempty"`           // нет, если тренд не ведёт к порогу
+	SecondsToThreshold *float64   `json:"seconds_to_threshold,omitempty"` // от текущего момента
+}
+
+// HandleForecast строит тренд gauge по истории и предсказывает, когда он достигнет порога.
+// Параметры: threshold — порог, method — linear (по умолчанию) или holt,
+// window — учитывать только значения за последний период, например 1h.
+func (h *Handler) HandleForecast(w http.ResponseWriter, r *http.Request) {
+	name, ok := metricParam(w, r)
+	if !ok {
+		return
+	}
+	if _, ok := h.Store.GetGauge(name); !ok {
+		problem.Write(w, r, http.StatusNotFound, problem.CodeMetricNotFound, "gauge "+name+" not found")
+		return
+	}
+
+	q := r.URL.Query()
+	method := q.Get("method")
+	if method == "" {
+		method = forecast.Linear
+	}
+
+	points := h.Store.History(gaugeType, name)
+	if s := q.Get("window"); s != "" {
+		window, err := time.ParseDuration(s)
+		if err != nil || window <= 0 {
+			problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidQuery, "window should be a positive duration")
+			return
+		}
+		points = since(points, time.Now().Add(-window))
+	}
+
+	f, err := forecast.Fit(method, points)
+	switch {
+	case errors.Is(err, forecast.ErrNotEnoughData):
+		problem.Write(w, r, http.StatusUnprocessableEntity, problem.CodeNotEnoughData, err.Error())
+		return
+	case err != nil:
+		problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidQuery, err.Error())
+		return
+	}
+
+	resp := forecastResponse{Metric: name, Forecast: f}
+	if s := q.Get("threshold"); s != "" {
+		threshold, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidQuery, "threshold should be a number")
+			return
+		}
+		resp.Threshold = &threshold
+
+		if at, ok := f.Crossing(threshold); ok {
+			seconds := max(time.Until(at).Seconds(), 0)
+			resp.CrossesAt = &at
+			resp.SecondsToThreshold = &seconds
+		}
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// since возвращает точки истории начиная с момента t
+func since(points []storage.Point, t time.Time) []storage.Point {
+	for i, p := range points {
+		if !p.Time.Before(t) {
+			return points[i:]
+		}
+	}
+	return nil
+}
@@ -1,39 +1,229 @@
 package handlers
 
 import (
+	"bytes"
 	"fmt"
+	"html/template"
+	"io/fs"
 	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/RomanenkoDR/metrics/internal/middleware/logger"
+	"github.com/RomanenkoDR/metrics/internal/problem"
+	assets "github.com/RomanenkoDR/metrics/internal/template"
+	"go.uber.org/zap"
+)
+
+// Шаблоны разбираются один раз при старте, html/template экранирует имена метрик
+var pages = template.Must(template.ParseFS(assets.Pages, "*.html"))
+
+const (
+	timeLayout     = "2006-01-02 15:04:05"
+	defaultRefresh = 10
 )
 
+// Допустимые интервалы автообновления страницы в секундах, 0 — выключено
+var refreshOptions = []int{0, 5, 10, 30, 60}
+
+type metricRow struct {
+	Name    string
+	Type    string
+	Value   string
+	Updated string
+	Link    string
+
+	value   float64
+	updated time.Time
+}
+
+type column struct {
+	Title  string
+	Link   string
+	Active bool
+	Arrow  string
+}
+
+type refreshOption struct {
+	Value    int
+	Title    string
+	Selected bool
+}
+
+type dashboardPage struct {
+	Rows           []metricRow
+	Columns        []column
+	RefreshOptions []refreshOption
+	Query          string
+	Sort           string
+	Order          string
+	Refresh        int
+	Total          int
+}
+
+// HandleMain выводит таблицу всех метрик с сортировкой, фильтром по имени и автообновлением
 func (h *Handler) HandleMain(w http.ResponseWriter, r *http.Request) {
-	body := `
-        <!DOCTYPE html>
-        <html>
-            <head>
-                <title>All tuples</title>
-            </head>
-            <body>
-            <table>
-                <tr>
-                    <td>Metric</td>
-                    <td>Value</td>
-                </tr>
-    `
-	listC := h.Store.GetAllCounters()
-	for k, v := range listC {
-		body = body + fmt.Sprintf("<tr>\n<td>%s</td>\n", k)
-		body = body + fmt.Sprintf("<td>%v</td>\n</tr>\n", v)
-	}
-
-	listG := h.Store.GetAllGauge()
-	for k, v := range listG {
-		body = body + fmt.Sprintf("<tr>\n<td>%s</td>\n", k)
-		body = body + fmt.Sprintf("<td>%v</td>\n</tr>\n", v)
-	}
-
-	body = body + " </table>\n </body>\n</html>"
+	q := r.URL.Query()
+	page := dashboardPage{
+		Query:   strings.TrimSpace(q.Get("q")),
+		Sort:    q.Get("sort"),
+		Order:   q.Get("order"),
+		Refresh: parseRefresh(q.Get("refresh")),
+	}
+	if page.Sort == "" {
+		page.Sort = "name"
+	}
+	if page.Order != "desc" {
+		page.Order = "asc"
+	}
+
+	rows := h.metricRows()
+	page.Total = len(rows)
+
+	if page.Query != "" {
+		filtered := rows[:0]
+		needle := strings.ToLower(page.Query)
+		for _, row := range rows {
+			if strings.Contains(strings.ToLower(row.Name), needle) {
+				filtered = append(filtered, row)
+			}
+		}
+		rows = filtered
+	}
+
+	sortRows(rows, page.Sort, page.Order == "desc")
+	page.Rows = rows
+
+	for _, c := range []struct{ key, title string }{
+		{"name", "Metric"}, {"type", "Type"}, {"value", "Value"}, {"updated", "Last updated"},
+	} {
+		order := "asc"
+		active := c.key == page.Sort
+		if active && page.Order == "asc" {
+			order = "desc"
+		}
+		arrow := "▲"
+		if page.Order == "desc" {
+			arrow = "▼"
+		}
+		link := url.Values{"sort": {c.key}, "order": {order}, "refresh": {strconv.Itoa(page.Refresh)}}
+		if page.Query != "" {
+			link.Set("q", page.Query)
+		}
+		page.Columns = append(page.Columns, column{Title: c.title, Link: "/?" + link.Encode(), Active: active, Arrow: arrow})
+	}
+
+	for _, v := range refreshOptions {
+		title := "off"
+		if v > 0 {
+			title = fmt.Sprintf("%ds", v)
+		}
+		page.RefreshOptions = append(page.RefreshOptions, refreshOption{Value: v, Title: title, Selected: v == page.Refresh})
+	}
+
+	renderPage(w, "index.html", page)
+}
+
+// HandleStatic отдаёт встроенные стили и скрипты веб-интерфейса
+func (h *Handler) HandleStatic() http.Handler {
+	static, err := fs.Sub(assets.Static, "static")
+	if err != nil {
+		panic(err)
+	}
+	return http.StripPrefix("/static/", http.FileServer(http.FS(static)))
+}
+
+// metricRows собирает строки таблицы из всех счётчиков и gauge-метрик
+func (h *Handler) metricRows() []metricRow {
+	counters := h.Store.GetAllCounters()
+	gauges := h.Store.GetAllGauge()
+
+	rows := make([]metricRow, 0, len(counters)+len(gauges))
+	for k, v := range counters {
+		rows = append(rows, h.newRow(k, counterType, fmt.Sprintf("%v", v), float64(v)))
+	}
+	for k, v := range gauges {
+		rows = append(rows, h.newRow(k, gaugeType, fmt.Sprintf("%v", v), float64(v)))
+	}
+	return rows
+}
+
+func (h *Handler) newRow(name, metricType, value string, raw float64) metricRow {
+	row := metricRow{
+		Name:    name,
+		Type:    metricType,
+		Value:   value,
+		Updated: "—",
+		Link:    "/metric/" + metricType + "/" + url.PathEscape(name),
+		value:   raw,
+	}
+	if t, ok := h.Store.LastUpdated(metricType, name); ok {
+		row.updated = t
+		row.Updated = t.Format(timeLayout)
+	}
+	return row
+}
+
+// sortRows сортирует строки по выбранной колонке, при равенстве — по имени и типу
+func sortRows(rows []metricRow, by string, desc bool) {
+	sort.SliceStable(rows, func(i, j int) bool {
+		a, b := rows[i], rows[j]
+		if desc {
+			a, b = b, a
+		}
+
+		switch by {
+		case "type":
+			if a.Type != b.Type {
+				return a.Type < b.Type
+			}
+		case "value":
+			if a.value != b.value {
+				return a.value < b.value
+			}
+		case "updated":
+			if !a.updated.Equal(b.updated) {
+				return a.updated.Before(b.updated)
+			}
+		}
+
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.Type < b.Type
+	})
+}
+
+// parseRefresh возвращает интервал автообновления, если он входит в список допустимых
+func parseRefresh(v string) int {
+	if v == "" {
+		return defaultRefresh
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return defaultRefresh
+	}
+	for _, opt := range refreshOptions {
+		if n == opt {
+			return n
+		}
+	}
+	return defaultRefresh
+}
+
+// renderPage выполняет шаблон в буфер, чтобы при ошибке не отдавать клиенту половину страницы
+func renderPage(w http.ResponseWriter, name string, data interface{}) {
+	var buf bytes.Buffer
+	if err := pages.ExecuteTemplate(&buf, name, data); err != nil {
+		logger.Error("Ошибка формирования страницы", zap.String("template", name), zap.Error(err))
+		problem.Write(w, nil, http.StatusInternalServerError, problem.CodeInternal, "can't render page")
+		return
+	}
 
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(body))
+	w.Write(buf.Bytes())
 }
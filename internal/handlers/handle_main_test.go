@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleMainEscapesNames(t *testing.T) {
+	h := NewHandler()
+	h.Store.UpdateGauge(`<script>alert(1)</script>`, 1)
+
+	w := httptest.NewRecorder()
+	h.HandleMain(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	result := w.Result()
+	defer result.Body.Close()
+	body, err := io.ReadAll(result.Body)
+	require.NoError(t, err)
+
+	require.Equal(t, http.StatusOK, result.StatusCode)
+	require.Equal(t, "text/html; charset=utf-8", result.Header.Get("Content-Type"))
+	assert.NotContains(t, string(body), "<script>alert(1)</script>")
+	assert.Contains(t, string(body), "&lt;script&gt;")
+}
+
+func TestHandleMainSortAndFilter(t *testing.T) {
+	h := NewHandler()
+	h.Store.UpdateGauge("b_gauge", 3)
+	h.Store.UpdateGauge("a_gauge", 1)
+	h.Store.UpdateCounter("c_counter", 2)
+
+	tests := []struct {
+		name    string
+		query   string
+		order   []string
+		missing string
+	}{
+		{"Default by name", "/", []string{"a_gauge", "b_gauge", "c_counter"}, ""},
+		{"By value desc", "/?sort=value&order=desc", []string{"b_gauge", "c_counter", "a_gauge"}, ""},
+		{"Filter", "/?q=GAUGE", []string{"a_gauge", "b_gauge"}, "c_counter"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.HandleMain(w, httptest.NewRequest(http.MethodGet, tc.query, nil))
+			body := w.Body.String()
+
+			last := -1
+			for _, name := range tc.order {
+				idx := strings.Index(body, `data-name="`+name+`"`)
+				require.Greater(t, idx, last, name)
+				last = idx
+			}
+			if tc.missing != "" {
+				assert.NotContains(t, body, `data-name="`+tc.missing+`"`)
+			}
+		})
+	}
+}
+
+func TestHandleMetricPage(t *testing.T) {
+	h := NewHandler()
+	h.Store.UpdateGauge("load", 1)
+	h.Store.UpdateGauge("load", 2)
+
+	tests := []struct {
+		name       string
+		metricType string
+		metric     string
+		wantCode   int
+	}{
+		{"Existing gauge", "gauge", "load", http.StatusOK},
+		{"Missing counter", "counter", "load", http.StatusNotFound},
+		{"Wrong type", "histogram", "load", http.StatusBadRequest},
+		{"Bad escape", "gauge", "load%zz", http.StatusBadRequest},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/metric/"+tc.metricType+"/"+url.PathEscape(tc.metric), nil)
+			rCtx := chi.NewRouteContext()
+			rCtx.URLParams.Add("type", tc.metricType)
+			rCtx.URLParams.Add("metric", tc.metric)
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rCtx))
+
+			w := httptest.NewRecorder()
+			h.HandleMetricPage(w, req)
+
+			require.Equal(t, tc.wantCode, w.Code)
+			if tc.wantCode == http.StatusOK {
+				assert.Contains(t, w.Body.String(), "<polyline")
+			}
+		})
+	}
+}
+
+func TestHandleMetricPageLink(t *testing.T) {
+	h := NewHandler()
+	router := chi.NewRouter()
+	router.Get("/metric/{type}/{metric}", h.HandleMetricPage)
+
+	// Имена с метками Graphite и со слешем проходят по ссылке с главной страницы
+	for _, name := range []string{"cpu;host=a", "disk/sda", "plain"} {
+		h.Store.UpdateGauge(name, 1)
+		link := h.newRow(name, gaugeType, "1", 1).Link
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, link, nil))
+		require.Equal(t, http.StatusOK, w.Code, "%s: %s", link, w.Body.String())
+		assert.Contains(t, w.Body.String(), "<polyline", link)
+	}
+}
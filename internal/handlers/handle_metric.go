@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/RomanenkoDR/metrics/internal/problem"
+	"github.com/RomanenkoDR/metrics/internal/storage"
+	"github.com/go-chi/chi/v5"
+)
+
+// Размеры графика на странице метрики
+const (
+	sparklineWidth  = 600
+	sparklineHeight = 120
+)
+
+type metricPage struct {
+	Name    string
+	Type    string
+	Value   string
+	Updated string
+	Refresh int
+
+	Points  string
+	Width   int
+	Height  int
+	Min     string
+	Max     string
+	Samples int
+	Since   string
+}
+
+// metricParam возвращает имя метрики из пути. Ссылки на метрики строятся через url.PathEscape,
+// а chi берёт параметры из экранированного пути, поэтому имена с метками (cpu;host=a)
+// и с "/" приходят экранированными.
+func metricParam(w http.ResponseWriter, r *http.Request) (string, bool) {
+	name, err := url.PathUnescape(chi.URLParam(r, "metric"))
+	if err != nil {
+		problem.Write(w, r, http.StatusBadRequest, problem.CodeBadRequest, "incorrect metric name: "+err.Error())
+		return "", false
+	}
+	return name, true
+}
+
+// HandleMetricPage выводит страницу одной метрики с графиком последних значений
+func (h *Handler) HandleMetricPage(w http.ResponseWriter, r *http.Request) {
+	metricType := chi.URLParam(r, "type")
+	name, ok := metricParam(w, r)
+	if !ok {
+		return
+	}
+
+	page := metricPage{
+		Name:    name,
+		Type:    metricType,
+		Updated: "—",
+		Refresh: parseRefresh(r.URL.Query().Get("refresh")),
+		Width:   sparklineWidth,
+		Height:  sparklineHeight,
+	}
+
+	switch metricType {
+	case counterType:
+		v, ok := h.Store.GetCounter(name)
+		if !ok {
+			problem.Write(w, r, http.StatusNotFound, problem.CodeMetricNotFound, "metric "+name+" not found")
+			return
+		}
+		page.Value = fmt.Sprintf("%v", v)
+	case gaugeType:
+		v, ok := h.Store.GetGauge(name)
+		if !ok {
+			problem.Write(w, r, http.StatusNotFound, problem.CodeMetricNotFound, "metric "+name+" not found")
+			return
+		}
+		page.Value = fmt.Sprintf("%v", v)
+	default:
+		problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidMetricType, "metric type should be counter or gauge")
+		return
+	}
+
+	if t, ok := h.Store.LastUpdated(metricType, name); ok {
+		page.Updated = t.Format(timeLayout)
+	}
+
+	points := h.Store.History(metricType, name)
+	if len(points) > 0 {
+		minV, maxV := points[0].Value, points[0].Value
+		for _, p := range points {
+			minV = min(minV, p.Value)
+			maxV = max(maxV, p.Value)
+		}
+		page.Points = sparkline(points, sparklineWidth, sparklineHeight)
+		page.Min = strconv.FormatFloat(minV, 'g', -1, 64)
+		page.Max = strconv.FormatFloat(maxV, 'g', -1, 64)
+		page.Samples = len(points)
+		page.Since = points[0].Time.Format(timeLayout)
+	}
+
+	renderPage(w, "metric.html", page)
+}
+
+// sparkline переводит значения в координаты ломаной SVG заданного размера
+func sparkline(points []storage.Point, width, height int) string {
+	minV, maxV := points[0].Value, points[0].Value
+	for _, p := range points {
+		minV = min(minV, p.Value)
+		maxV = max(maxV, p.Value)
+	}
+
+	// Оставляем по пикселю сверху и снизу, чтобы линия не обрезалась рамкой
+	const pad = 1.0
+	span := maxV - minV
+	step := 0.0
+	if len(points) > 1 {
+		step = float64(width) / float64(len(points)-1)
+	}
+
+	coords := make([]string, 0, len(points))
+	for i, p := range points {
+		y := float64(height) / 2
+		if span > 0 {
+			y = pad + (maxV-p.Value)/span*(float64(height)-2*pad)
+		}
+		coords = append(coords, fmt.Sprintf("%.1f,%.1f", float64(i)*step, y))
+	}
+	return strings.Join(coords, " ")
+}
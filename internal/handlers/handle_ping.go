@@ -4,15 +4,39 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+
+	"github.com/RomanenkoDR/metrics/internal/health"
+	"github.com/RomanenkoDR/metrics/internal/problem"
 )
 
+// HandlePing проверяет соединение с базой данных. Если база не настроена, проверять нечего
+// и сервер просто отвечает pong; полная проверка готовности доступна по /readyz.
 func (h *Handler) HandlePing(w http.ResponseWriter, r *http.Request) {
 	v := "pong\n"
-	err := h.DBconn.Ping(context.Background())
-	if err != nil {
-		http.Error(w, "Connection to DB is lost", http.StatusInternalServerError)
-		return
+	if h.DB != nil {
+		err := h.DB.Ping(context.Background())
+		if err != nil {
+			problem.Write(w, r, http.StatusInternalServerError, problem.CodeStorageUnavailable, "connection to DB is lost")
+			return
+		}
 	}
 	w.WriteHeader(http.StatusOK)
 	fmt.Fprint(w, v)
 }
+
+// HandleHealthz сообщает, что процесс жив и обрабатывает запросы
+func (h *Handler) HandleHealthz(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": health.StatusOK})
+}
+
+// HandleReadyz выполняет зарегистрированные проверки готовности и возвращает их результаты.
+// Если хотя бы одна проверка не прошла, отвечает 503.
+func (h *Handler) HandleReadyz(w http.ResponseWriter, r *http.Request) {
+	report := h.Health.Run(r.Context())
+
+	status := http.StatusOK
+	if !report.OK() {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, report)
+}
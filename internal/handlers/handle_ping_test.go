@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/RomanenkoDR/metrics/internal/health"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandlePingWithoutDB(t *testing.T) {
+	h := NewHandler()
+
+	w := httptest.NewRecorder()
+	h.HandlePing(w, httptest.NewRequest(http.MethodGet, "/ping", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "pong\n", w.Body.String())
+}
+
+func TestHandleReadyz(t *testing.T) {
+	h := NewHandler()
+
+	w := httptest.NewRecorder()
+	h.HandleReadyz(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusOK, w.Code, "no checks registered")
+
+	h.Health = health.New(0)
+	h.Health.Add("disk", func(ctx context.Context) error { return nil })
+	h.Health.Add("database", func(ctx context.Context) error { return errors.New("connection refused") })
+
+	w = httptest.NewRecorder()
+	h.HandleReadyz(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	var report health.Report
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	assert.Equal(t, health.StatusFail, report.Status)
+	require.Len(t, report.Checks, 2)
+	assert.Equal(t, "database", report.Checks[1].Name)
+	assert.Equal(t, "connection refused", report.Checks[1].Error)
+
+	w = httptest.NewRecorder()
+	h.HandleHealthz(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, http.StatusOK, w.Code, "liveness doesn't depend on checks")
+}
@@ -2,165 +2,275 @@ package handlers
 
 import (
 	"bytes"
+	"crypto/rsa"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/RomanenkoDR/metrics/internal/audit"
 	"github.com/RomanenkoDR/metrics/internal/crypto"
 	"github.com/RomanenkoDR/metrics/internal/middleware/logger"
+	"github.com/RomanenkoDR/metrics/internal/openapi"
+	"github.com/RomanenkoDR/metrics/internal/problem"
 	"github.com/RomanenkoDR/metrics/internal/storage"
+	"github.com/RomanenkoDR/metrics/internal/telemetry"
 	"github.com/go-chi/chi/v5"
 	"go.uber.org/zap"
+	"io"
 	"net/http"
 	"strconv"
+	"time"
 )
 
-// SetCryptoKey устанавливает путь к приватному ключу для расшифровки.
-func (h *Handler) SetCryptoKey(path string) {
-	h.PrivateKeyPath = path
-}
-
 func (h *Handler) HandleUpdate(w http.ResponseWriter, r *http.Request) {
 	logger.Debug("Обработка обновления метрики")
 	metricType := chi.URLParam(r, "type")
 	metric := chi.URLParam(r, "metric")
 	value := chi.URLParam(r, "value")
 
+	if telemetry.Reserved(metric) {
+		problem.Write(w, r, http.StatusBadRequest, problem.CodeReservedName, reservedDetail)
+		return
+	}
+
 	switch metricType {
 	case counterType:
 		v, err := strconv.Atoi(value)
 		if err != nil {
 			logger.Error("Ошибка парсинга значения метрики", zap.Error(err))
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidMetricValue, "counter value should be an integer")
 			return
 		}
 		h.Store.UpdateCounter(metric, storage.Counter(v))
+		audit.Touch(r, 1)
 	case gaugeType:
 		v, err := strconv.ParseFloat(value, 64)
 		if err != nil {
 			logger.Error("Ошибка парсинга значения метрики", zap.Error(err))
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidMetricValue, "gauge value should be a number")
 			return
 		}
 		h.Store.UpdateGauge(metric, storage.Gauge(v))
+		audit.Touch(r, 1)
 	default:
 		logger.Warn("Некорректный тип метрики", zap.String("metricType", metricType))
-		http.Error(w, "Incorrect metric type", http.StatusBadRequest)
+		problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidMetricType, "metric type should be counter or gauge")
 	}
 }
 
 // HandleUpdateJSON обрабатывает обновление одной метрики в формате JSON
 func (h *Handler) HandleUpdateJSON(w http.ResponseWriter, r *http.Request) {
-	var m Metrics
-	var buf bytes.Buffer
-
-	_, err := buf.ReadFrom(r.Body)
-	if err != nil {
-		logger.Error("Ошибка чтения тела запроса", zap.Error(err))
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	body, ok := h.requestBody(w, r)
+	if !ok {
 		return
 	}
 
-	data := buf.Bytes()
+	var m Metrics
+	if err := decodeJSON(body, &m); err != nil {
+		logger.Error("Ошибка десериализации JSON", zap.Error(err))
+		problem.WriteBodyError(w, r, problem.CodeInvalidJSON, err)
+		return
+	}
 
-	// Если передан ключ, расшифровываем AES-ключ, затем данные
-	if h.PrivateKeyPath != "" {
-		data, err = h.decryptPayload(data)
-		if err != nil {
-			http.Error(w, "Ошибка расшифровки данных", http.StatusBadRequest)
-			return
-		}
+	if p := validateMetric(m); p != nil {
+		p.Instance = r.URL.Path
+		p.Write(w)
+		return
 	}
+	h.applyMetric(m)
+	audit.Touch(r, 1)
 
-	err = json.Unmarshal(data, &m)
-	if err != nil {
-		logger.Error("Ошибка десериализации JSON", zap.Error(err))
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	logger.Info("Метрика успешно обновлена", zap.Any("metric", m))
+	w.WriteHeader(http.StatusOK)
+}
+
+// HandleUpdateBatch обрабатывает обновление нескольких метрик в формате JSON.
+// Массив разбирается поэлементно, а метрики копятся в batch, который занимает память по числу
+// разных метрик, а не записей. Метрики применяются, только если всё тело прочитано и корректно,
+// в том числе совпала подпись, которая проверяется в конце тела.
+func (h *Handler) HandleUpdateBatch(w http.ResponseWriter, r *http.Request) {
+	body, ok := h.requestBody(w, r)
+	if !ok {
 		return
 	}
 
-	switch m.MType {
-	case counterType:
-		if m.Delta == nil {
-			http.Error(w, "metric value should not be empty", http.StatusBadRequest)
-			return
+	b := newBatch()
+	err := decodeJSONArray(body, func(raw json.RawMessage) error {
+		if err := openapi.ValidateItem(r, b.records, raw); err != nil {
+			var verr *openapi.ValidationError
+			if errors.As(err, &verr) {
+				return problem.New(http.StatusBadRequest, problem.CodeSchemaViolation, verr.Error())
+			}
+			return err
 		}
-		h.Store.UpdateCounter(m.ID, storage.Counter(*m.Delta))
-	case gaugeType:
-		if m.Value == nil {
-			http.Error(w, "metric value should not be empty", http.StatusBadRequest)
+
+		var m Metrics
+		if err := json.Unmarshal(raw, &m); err != nil {
+			return err
+		}
+		if p := validateMetric(m); p != nil {
+			p.Detail = fmt.Sprintf("record %d: %s", b.records+1, p.Detail)
+			return p
+		}
+		b.add(m)
+		return nil
+	})
+	if err != nil {
+		logger.Error("Ошибка разбора батча метрик", zap.Error(err))
+		var p *problem.Problem
+		if errors.As(err, &p) {
+			p.Instance = r.URL.Path
+			p.Write(w)
 			return
 		}
-		h.Store.UpdateGauge(m.ID, storage.Gauge(*m.Value))
-	default:
-		logger.Warn("Некорректный тип метрики", zap.String("MType", m.MType))
-		http.Error(w, "Incorrect metric type", http.StatusBadRequest)
+		problem.WriteBodyError(w, r, problem.CodeInvalidJSON, err)
+		return
 	}
-	logger.Info("Метрика успешно обновлена", zap.Any("metric", m))
+
+	telemetry.Default.Observe("batch_size", nil, float64(b.records))
+	b.apply(h.Store)
+	audit.Touch(r, b.records)
+
+	logger.Info("Батч метрик успешно обработан", zap.Int("batch_size", b.records))
 	w.WriteHeader(http.StatusOK)
 }
 
-// HandleUpdateBatch обрабатывает обновление нескольких метрик в формате JSON
-func (h *Handler) HandleUpdateBatch(w http.ResponseWriter, r *http.Request) {
-	var metrics []Metrics
-	var buf bytes.Buffer
+// batch копит проверенные метрики пакета до применения: приращения счётчика складываются,
+// у gauge остаётся последнее значение, как если бы записи применялись по очереди
+type batch struct {
+	records  int
+	counters map[string]int64
+	gauges   map[string]float64
+}
+
+func newBatch() *batch {
+	return &batch{counters: map[string]int64{}, gauges: map[string]float64{}}
+}
 
-	// Читаем тело запроса
-	_, err := buf.ReadFrom(r.Body)
+// add добавляет проверенную метрику
+func (b *batch) add(m Metrics) {
+	b.records++
+	if m.MType == counterType {
+		b.counters[m.ID] += *m.Delta
+		return
+	}
+	b.gauges[m.ID] = *m.Value
+}
+
+// apply сохраняет метрики пакета в хранилище
+func (b *batch) apply(store storage.MemStorage) {
+	for id, delta := range b.counters {
+		store.UpdateCounter(id, storage.Counter(delta))
+	}
+	for id, value := range b.gauges {
+		store.UpdateGauge(id, storage.Gauge(value))
+	}
+}
+
+// requestBody возвращает тело запроса. Зашифрованное тело читается целиком и расшифровывается,
+// иначе тело передаётся как есть для потокового разбора. При ошибке ответ уже отправлен.
+func (h *Handler) requestBody(w http.ResponseWriter, r *http.Request) (io.Reader, bool) {
+	private := h.Keys.PrivateKeys()
+	if len(private) == 0 {
+		return r.Body, true
+	}
+
+	data, err := io.ReadAll(r.Body)
 	if err != nil {
 		logger.Error("Ошибка чтения тела запроса", zap.Error(err))
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
+		problem.WriteBodyError(w, r, problem.CodeBadRequest, err)
+		return nil, false
 	}
 
-	data := buf.Bytes()
-	logger.Debug("Полученные данные для батча", zap.ByteString("raw_data", data))
+	data, p := h.decrypt(data, private, r.URL.Path)
+	if p != nil {
+		telemetry.Default.Inc("decrypt_failures_total", nil)
+		p.Instance = r.URL.Path
+		p.Write(w)
+		return nil, false
+	}
+	return bytes.NewReader(data), true
+}
 
-	// Расшифровка, если передан ключ
-	if h.PrivateKeyPath != "" {
-		data, err = h.decryptPayload(data)
-		if err != nil {
-			http.Error(w, "Ошибка расшифровки данных", http.StatusBadRequest)
-			return
+// decrypt расшифровывает конверт. Прежний формат без версии принимается, только если задан LegacyKey.
+func (h *Handler) decrypt(payload []byte, private []*crypto.PrivateKey, path string) ([]byte, *problem.Problem) {
+	data, ts, err := crypto.Open(payload, private, path)
+	if errors.Is(err, crypto.ErrLegacyEnvelope) {
+		if h.LegacyKey == nil {
+			return nil, problem.New(http.StatusBadRequest, problem.CodeDecryptionFailed, "legacy encryption format is disabled, use envelope version 2")
 		}
+		if err := CheckLegacyKey(h.LegacyKey, private); err != nil {
+			logger.Error("Ключ прежнего формата совпадает с ключом расшифровки", zap.Error(err))
+			return nil, problem.New(http.StatusBadRequest, problem.CodeDecryptionFailed, "legacy encryption format is disabled, use envelope version 2")
+		}
+		telemetry.Default.Inc("decrypt_legacy_total", nil)
+		if data, err = decryptPayload(payload, h.LegacyKey.RSA()); err != nil {
+			return nil, problem.New(http.StatusBadRequest, problem.CodeDecryptionFailed, "can't decrypt request body")
+		}
+		return data, nil
 	}
-
-	// Десериализуем JSON как массив метрик
-	err = json.Unmarshal(data, &metrics)
 	if err != nil {
-		logger.Error("Ошибка десериализации JSON (массив метрик)", zap.Error(err))
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
+		logger.Error("Ошибка расшифровки конверта", zap.Error(err))
+		return nil, problem.New(http.StatusBadRequest, problem.CodeDecryptionFailed, "can't decrypt request body")
 	}
 
-	// Логируем успешную десериализацию
-	logger.Debug("Успешно десериализовано", zap.Int("batch_size", len(metrics)))
+	if h.ClockSkew > 0 {
+		if d := time.Since(ts); d > h.ClockSkew || d < -h.ClockSkew {
+			return nil, problem.New(http.StatusBadRequest, problem.CodeDecryptionFailed, "envelope timestamp is outside the allowed window")
+		}
+	}
+	return data, nil
+}
 
-	// Обрабатываем каждую метрику
-	for _, v := range metrics {
-		switch v.MType {
-		case counterType:
-			if v.Delta == nil {
-				http.Error(w, "metric value should not be empty", http.StatusBadRequest)
-				return
-			}
-			h.Store.UpdateCounter(v.ID, storage.Counter(*v.Delta))
-		case gaugeType:
-			if v.Value == nil {
-				http.Error(w, "metric value should not be empty", http.StatusBadRequest)
-				return
-			}
-			h.Store.UpdateGauge(v.ID, storage.Gauge(*v.Value))
-		default:
-			logger.Warn("Некорректный тип метрики", zap.String("MType", v.MType))
-			http.Error(w, "Incorrect metric type", http.StatusBadRequest)
-			return
+// reservedDetail — описание ошибки для имени с зарезервированным префиксом
+const reservedDetail = "metric name prefix " + telemetry.Prefix + " is reserved for server metrics"
+
+// validateMetric проверяет имя и тип метрики и наличие значения
+func validateMetric(m Metrics) *problem.Problem {
+	if telemetry.Reserved(m.ID) {
+		return problem.New(http.StatusBadRequest, problem.CodeReservedName, reservedDetail)
+	}
+
+	switch m.MType {
+	case counterType:
+		if m.Delta == nil {
+			return problem.New(http.StatusBadRequest, problem.CodeMissingValue, "metric value should not be empty")
+		}
+	case gaugeType:
+		if m.Value == nil {
+			return problem.New(http.StatusBadRequest, problem.CodeMissingValue, "metric value should not be empty")
 		}
+	default:
+		logger.Warn("Некорректный тип метрики", zap.String("MType", m.MType))
+		return problem.New(http.StatusBadRequest, problem.CodeInvalidMetricType, "metric type should be counter or gauge")
 	}
+	return nil
+}
 
-	logger.Info("Батч метрик успешно обработан", zap.Int("batch_size", len(metrics)))
-	w.WriteHeader(http.StatusOK)
+// applyMetric сохраняет проверенную метрику в хранилище
+func (h *Handler) applyMetric(m Metrics) {
+	if m.MType == counterType {
+		h.Store.UpdateCounter(m.ID, storage.Counter(*m.Delta))
+		return
+	}
+	h.Store.UpdateGauge(m.ID, storage.Gauge(*m.Value))
+}
+
+// CheckLegacyKey проверяет ключ прежнего формата: он должен быть RSA и не совпадать ни с одним
+// ключом расшифровки конвертов private
+func CheckLegacyKey(legacy *crypto.PrivateKey, private []*crypto.PrivateKey) error {
+	if legacy.RSA() == nil {
+		return errors.New("legacy key should be an RSA key")
+	}
+	for _, k := range private {
+		if k.ID == legacy.ID {
+			return fmt.Errorf("legacy key %s is also used for envelope decryption, use a separate key", legacy.ID)
+		}
+	}
+	return nil
 }
 
-// decryptPayload расшифровывает полученные данные, если указан приватный ключ
-func (h *Handler) decryptPayload(data []byte) ([]byte, error) {
+// decryptPayload расшифровывает данные в прежнем формате: AES-CFB и RSA PKCS #1 v1.5
+func decryptPayload(data []byte, priv *rsa.PrivateKey) ([]byte, error) {
 	var encryptedPayload map[string][]byte
 	err := json.Unmarshal(data, &encryptedPayload)
 	if err != nil {
@@ -169,7 +279,7 @@ func (h *Handler) decryptPayload(data []byte) ([]byte, error) {
 	}
 
 	// Расшифровка AES-ключа
-	aesKey, err := crypto.DecryptRSA(encryptedPayload["key"], h.PrivateKeyPath)
+	aesKey, err := crypto.DecryptRSAWithKey(encryptedPayload["key"], priv)
 	if err != nil {
 		logger.Error("Ошибка расшифровки AES-ключа", zap.Error(err))
 		return nil, err
@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RomanenkoDR/metrics/internal/crypto"
+	"github.com/RomanenkoDR/metrics/internal/keys"
+	"github.com/RomanenkoDR/metrics/internal/middleware/bodylimit"
+	"github.com/RomanenkoDR/metrics/internal/problem"
+	"github.com/RomanenkoDR/metrics/internal/storage"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleUpdateBatch(t *testing.T) {
+	h := NewHandler()
+	post := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.HandleUpdateBatch(w, httptest.NewRequest(http.MethodPost, "/updates/", strings.NewReader(body)))
+		return w
+	}
+
+	w := post(`[{"id":"PollCount","type":"counter","delta":2},{"id":"Alloc","type":"gauge","value":1.5}]`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	c, _ := h.Store.GetCounter("PollCount")
+	assert.Equal(t, storage.Counter(2), c)
+
+	tests := []struct {
+		name string
+		body string
+		code problem.Code
+	}{
+		{name: "not array", body: `{"id":"PollCount","type":"counter","delta":1}`, code: problem.CodeInvalidJSON},
+		{name: "empty", body: ``, code: problem.CodeInvalidJSON},
+		{name: "truncated", body: `[{"id":"PollCount","type":"counter","delta":1},`, code: problem.CodeInvalidJSON},
+		{name: "trailing data", body: `[{"id":"PollCount","type":"counter","delta":1}] []`, code: problem.CodeInvalidJSON},
+		{name: "missing value", body: `[{"id":"PollCount","type":"counter","delta":1},{"id":"PollCount","type":"counter"}]`, code: problem.CodeMissingValue},
+		{name: "bad type", body: `[{"id":"PollCount","type":"summary","value":1}]`, code: problem.CodeInvalidMetricType},
+		{name: "reserved name", body: `[{"id":"metrics_server_series","type":"gauge","value":1}]`, code: problem.CodeReservedName},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w := post(tc.body)
+			require.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), `"code":"`+string(tc.code)+`"`)
+
+			// Батч применяется только целиком
+			c, _ := h.Store.GetCounter("PollCount")
+			assert.Equal(t, storage.Counter(2), c)
+		})
+	}
+
+	w = post(`[{"id":"PollCount","type":"counter","delta":1},{"id":"PollCount","type":"counter"}]`)
+	assert.Contains(t, w.Body.String(), "record 2")
+}
+
+func TestHandleUpdateBatchRepeated(t *testing.T) {
+	h := NewHandler()
+	body := `[{"id":"PollCount","type":"counter","delta":2},{"id":"Alloc","type":"gauge","value":1},` +
+		`{"id":"PollCount","type":"counter","delta":3},{"id":"Alloc","type":"gauge","value":4}]`
+	w := httptest.NewRecorder()
+	h.HandleUpdateBatch(w, httptest.NewRequest(http.MethodPost, "/updates/", strings.NewReader(body)))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	c, _ := h.Store.GetCounter("PollCount")
+	assert.Equal(t, storage.Counter(5), c, "counter deltas are summed")
+	g, _ := h.Store.GetGauge("Alloc")
+	assert.Equal(t, storage.Gauge(4), g, "last gauge value wins")
+}
+
+func TestHandleUpdateReserved(t *testing.T) {
+	h := NewHandler()
+	router := chi.NewRouter()
+	router.Post("/update/{type}/{metric}/{value}", h.HandleUpdate)
+	router.Post("/update/", h.HandleUpdateJSON)
+
+	for _, r := range []*http.Request{
+		httptest.NewRequest(http.MethodPost, "/update/counter/metrics_server_http_requests_total/1", nil),
+		httptest.NewRequest(http.MethodPost, "/update/", strings.NewReader(`{"id":"metrics_server_series","type":"gauge","value":1}`)),
+	} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		require.Equal(t, http.StatusBadRequest, w.Code, r.URL.Path)
+		assert.Contains(t, w.Body.String(), string(problem.CodeReservedName))
+	}
+	counters, gauges := h.Store.Len()
+	assert.Zero(t, counters+gauges)
+}
+
+func TestHandleUpdateBatchTooLarge(t *testing.T) {
+	h := NewHandler()
+	handler := bodylimit.Handler(64)(http.HandlerFunc(h.HandleUpdateBatch))
+
+	body := "[" + strings.Repeat(`{"id":"PollCount","type":"counter","delta":1},`, 10) + `{"id":"PollCount","type":"counter","delta":1}]`
+	r := httptest.NewRequest(http.MethodPost, "/updates/", strings.NewReader(body))
+	r.ContentLength = -1
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	c, _ := h.Store.GetCounter("PollCount")
+	assert.Equal(t, storage.Counter(0), c)
+}
+
+func TestHandleUpdateEncrypted(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	privPath := filepath.Join(t.TempDir(), "private.pem")
+	require.NoError(t, os.WriteFile(privPath, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(priv)}), 0o600))
+
+	h := NewHandler()
+	h.Keys, err = keys.New(keys.Config{PrivateKeyPath: privPath})
+	require.NoError(t, err)
+	h.ClockSkew = time.Minute
+
+	post := func(body []byte) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.HandleUpdateBatch(w, httptest.NewRequest(http.MethodPost, "/updates/", bytes.NewReader(body)))
+		return w
+	}
+	batch := []byte(`[{"id":"PollCount","type":"counter","delta":1}]`)
+
+	pub, err := crypto.NewPublicKey(&priv.PublicKey)
+	require.NoError(t, err)
+
+	sealed, err := crypto.Seal(batch, pub, "/updates/", time.Now())
+	require.NoError(t, err)
+	w := post(sealed)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	stale, err := crypto.Seal(batch, pub, "/updates/", time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, post(stale).Code)
+
+	// Прежний формат принимается только с отдельным ключом LegacyKey
+	legacyPriv, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	sealLegacy := func(pub *rsa.PublicKey) []byte {
+		aesKey := make([]byte, 32)
+		data, err := crypto.EncryptAES(batch, aesKey)
+		require.NoError(t, err)
+		wrapped, err := rsa.EncryptPKCS1v15(rand.Reader, pub, aesKey)
+		require.NoError(t, err)
+		legacy, _ := json.Marshal(map[string][]byte{"data": data, "key": wrapped})
+		return legacy
+	}
+	legacy := sealLegacy(&legacyPriv.PublicKey)
+
+	w = post(legacy)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), string(problem.CodeDecryptionFailed))
+
+	h.LegacyKey, err = crypto.NewPrivateKey(legacyPriv)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, post(legacy).Code)
+	assert.Equal(t, http.StatusBadRequest, post(sealLegacy(&priv.PublicKey)).Code, "envelope keys are not used for legacy format")
+
+	// Ключ расшифровки конвертов не может быть ключом прежнего формата
+	h.LegacyKey, err = crypto.NewPrivateKey(priv)
+	require.NoError(t, err)
+	assert.Error(t, CheckLegacyKey(h.LegacyKey, h.Keys.PrivateKeys()))
+	assert.Equal(t, http.StatusBadRequest, post(sealLegacy(&priv.PublicKey)).Code)
+
+	c, _ := h.Store.GetCounter("PollCount")
+	assert.Equal(t, storage.Counter(2), c)
+}
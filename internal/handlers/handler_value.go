@@ -3,6 +3,7 @@ package handlers
 import (
 	"encoding/json"
 	"fmt"
+	"github.com/RomanenkoDR/metrics/internal/problem"
 	"github.com/go-chi/chi/v5"
 	"net/http"
 )
@@ -12,7 +13,8 @@ func (h *Handler) HandleValue(w http.ResponseWriter, r *http.Request) {
 	metric := chi.URLParam(r, "metric")
 	v, err := h.Store.Get(metric)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		problem.Write(w, r, http.StatusNotFound, problem.CodeMetricNotFound, err.Error())
+		return
 	}
 	fmt.Fprint(w, v)
 }
@@ -24,32 +26,35 @@ func (h *Handler) HandleValueJSON(w http.ResponseWriter, r *http.Request) {
 	decoder := json.NewDecoder(r.Body)
 	err := decoder.Decode(&m)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		problem.WriteBodyError(w, r, problem.CodeInvalidJSON, err)
 		return
 	}
 
 	switch m.MType {
 	case counterType:
-		v, ok := h.Store.CounterData[m.ID]
+		v, ok := h.Store.GetCounter(m.ID)
 		if !ok {
-			http.Error(w, "not found", http.StatusNotFound)
+			problem.Write(w, r, http.StatusNotFound, problem.CodeMetricNotFound, "metric "+m.ID+" not found")
 			return
 		}
 		vPtr := int64(v)
 		m.Delta = &vPtr
 	case gaugeType:
-		v, ok := h.Store.GaugeData[m.ID]
+		v, ok := h.Store.GetGauge(m.ID)
 		if !ok {
-			http.Error(w, "not found", http.StatusNotFound)
+			problem.Write(w, r, http.StatusNotFound, problem.CodeMetricNotFound, "metric "+m.ID+" not found")
 			return
 		}
 		vPtr := float64(v)
 		m.Value = &vPtr
+	default:
+		problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidMetricType, "metric type should be counter or gauge")
+		return
 	}
 
 	resp, err := json.Marshal(m)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		problem.Write(w, r, http.StatusInternalServerError, problem.CodeInternal, err.Error())
 		return
 	}
 
@@ -0,0 +1,288 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/RomanenkoDR/metrics/internal/problem"
+	"github.com/RomanenkoDR/metrics/internal/storage"
+)
+
+const (
+	defaultListLimit = 100
+	maxListLimit     = 1000
+
+	// nextCursorHeader — заголовок с курсором следующей страницы, тело ответа остаётся []Metrics
+	nextCursorHeader = "X-Next-Cursor"
+)
+
+// ListQuery — параметры выборки списка метрик
+type ListQuery struct {
+	Type   string            `json:"type,omitempty"`   // counter или gauge
+	Prefix string            `json:"prefix,omitempty"` // префикс имени
+	Glob   string            `json:"glob,omitempty"`   // шаблон имени в формате path.Match
+	Regex  string            `json:"regex,omitempty"`  // регулярное выражение для имени
+	Labels map[string]string `json:"labels,omitempty"` // метки, которые должны совпасть
+	Sort   string            `json:"sort,omitempty"`   // name, type или value
+	Order  string            `json:"order,omitempty"`  // asc или desc
+	Limit  int               `json:"limit,omitempty"`  // размер страницы
+	Cursor string            `json:"cursor,omitempty"` // курсор из заголовка X-Next-Cursor
+}
+
+// listCursor — позиция последнего отданного элемента
+type listCursor struct {
+	ID    string  `json:"id"`
+	Type  string  `json:"type"`
+	Value float64 `json:"value"`
+}
+
+type listItem struct {
+	metric Metrics
+	value  float64
+}
+
+// HandleValues GET /values — список метрик с фильтрами из параметров запроса
+func (h *Handler) HandleValues(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	query := ListQuery{
+		Type:   q.Get("type"),
+		Prefix: q.Get("prefix"),
+		Glob:   q.Get("glob"),
+		Regex:  q.Get("regex"),
+		Sort:   q.Get("sort"),
+		Order:  q.Get("order"),
+		Cursor: q.Get("cursor"),
+	}
+
+	for _, l := range q["label"] {
+		k, v, ok := strings.Cut(l, "=")
+		if !ok {
+			problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidQuery, "label filter should be in format key=value")
+			return
+		}
+		if query.Labels == nil {
+			query.Labels = map[string]string{}
+		}
+		query.Labels[k] = v
+	}
+
+	if limit := q.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil {
+			problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidQuery, "limit should be an integer")
+			return
+		}
+		query.Limit = n
+	}
+
+	h.writeList(w, r, query)
+}
+
+// HandleValuesJSON POST /values/ — список метрик с фильтрами из тела запроса
+func (h *Handler) HandleValuesJSON(w http.ResponseWriter, r *http.Request) {
+	var query ListQuery
+
+	// Пустое тело означает выборку без фильтров
+	err := json.NewDecoder(r.Body).Decode(&query)
+	if err != nil && !errors.Is(err, io.EOF) {
+		problem.WriteBodyError(w, r, problem.CodeInvalidJSON, err)
+		return
+	}
+
+	h.writeList(w, r, query)
+}
+
+func (h *Handler) writeList(w http.ResponseWriter, r *http.Request, query ListQuery) {
+	metrics, next, err := h.listMetrics(query)
+	if err != nil {
+		problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidQuery, err.Error())
+		return
+	}
+
+	resp, err := json.Marshal(metrics)
+	if err != nil {
+		problem.Write(w, r, http.StatusInternalServerError, problem.CodeInternal, err.Error())
+		return
+	}
+
+	if next != "" {
+		w.Header().Set(nextCursorHeader, next)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(resp)
+}
+
+// listMetrics выбирает, сортирует и разбивает на страницы метрики из хранилища.
+// Хранилище в памяти — единый источник для файлового режима и Postgres,
+// поэтому выборка одинакова для обоих бэкендов.
+func (h *Handler) listMetrics(query ListQuery) ([]Metrics, string, error) {
+	match, err := query.matcher()
+	if err != nil {
+		return nil, "", err
+	}
+
+	desc := false
+	switch query.Order {
+	case "", "asc":
+	case "desc":
+		desc = true
+	default:
+		return nil, "", fmt.Errorf("incorrect order %q", query.Order)
+	}
+
+	switch query.Sort {
+	case "", "name", "type", "value":
+	default:
+		return nil, "", fmt.Errorf("incorrect sort %q", query.Sort)
+	}
+
+	limit := query.Limit
+	switch {
+	case limit == 0:
+		limit = defaultListLimit
+	case limit < 0 || limit > maxListLimit:
+		return nil, "", fmt.Errorf("limit should be between 1 and %d", maxListLimit)
+	}
+
+	var items []listItem
+	if query.Type == "" || query.Type == counterType {
+		for k, v := range h.Store.GetAllCounters() {
+			if match(k) {
+				delta := int64(v)
+				items = append(items, listItem{metric: Metrics{ID: k, MType: counterType, Delta: &delta}, value: float64(v)})
+			}
+		}
+	}
+	if query.Type == "" || query.Type == gaugeType {
+		for k, v := range h.Store.GetAllGauge() {
+			if match(k) {
+				value := float64(v)
+				items = append(items, listItem{metric: Metrics{ID: k, MType: gaugeType, Value: &value}, value: value})
+			}
+		}
+	}
+
+	less := func(a, b listCursor) bool {
+		if desc {
+			a, b = b, a
+		}
+		switch query.Sort {
+		case "type":
+			if a.Type != b.Type {
+				return a.Type < b.Type
+			}
+		case "value":
+			if a.Value != b.Value {
+				return a.Value < b.Value
+			}
+		}
+		if a.ID != b.ID {
+			return a.ID < b.ID
+		}
+		return a.Type < b.Type
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		return less(items[i].cursor(), items[j].cursor())
+	})
+
+	// Пропускаем всё, что не идёт строго после позиции курсора
+	start := 0
+	if query.Cursor != "" {
+		after, err := decodeCursor(query.Cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		start = sort.Search(len(items), func(i int) bool {
+			return less(after, items[i].cursor())
+		})
+	}
+
+	end := min(start+limit, len(items))
+	res := make([]Metrics, 0, end-start)
+	for _, it := range items[start:end] {
+		res = append(res, it.metric)
+	}
+
+	next := ""
+	if end < len(items) {
+		next = encodeCursor(items[end-1].cursor())
+	}
+	return res, next, nil
+}
+
+func (it listItem) cursor() listCursor {
+	return listCursor{ID: it.metric.ID, Type: it.metric.MType, Value: it.value}
+}
+
+// matcher собирает фильтр по имени и меткам
+func (q ListQuery) matcher() (func(id string) bool, error) {
+	switch q.Type {
+	case "", counterType, gaugeType:
+	default:
+		return nil, fmt.Errorf("incorrect metric type %q", q.Type)
+	}
+
+	if q.Glob != "" {
+		if _, err := path.Match(q.Glob, ""); err != nil {
+			return nil, fmt.Errorf("incorrect glob: %w", err)
+		}
+	}
+
+	var re *regexp.Regexp
+	if q.Regex != "" {
+		var err error
+		re, err = regexp.Compile(q.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("incorrect regex: %w", err)
+		}
+	}
+
+	return func(id string) bool {
+		name, labels := storage.SplitLabels(id)
+		if q.Prefix != "" && !strings.HasPrefix(name, q.Prefix) {
+			return false
+		}
+		if q.Glob != "" {
+			if ok, _ := path.Match(q.Glob, name); !ok {
+				return false
+			}
+		}
+		if re != nil && !re.MatchString(name) {
+			return false
+		}
+		for k, v := range q.Labels {
+			if labels[k] != v {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+func encodeCursor(c listCursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string) (listCursor, error) {
+	var c listCursor
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, fmt.Errorf("incorrect cursor")
+	}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return c, fmt.Errorf("incorrect cursor")
+	}
+	return c, nil
+}
@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RomanenkoDR/metrics/internal/problem"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func listIDs(t *testing.T, w *httptest.ResponseRecorder) []string {
+	t.Helper()
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var metrics []Metrics
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &metrics))
+
+	ids := []string{}
+	for _, m := range metrics {
+		ids = append(ids, m.ID)
+	}
+	return ids
+}
+
+func TestHandleValues(t *testing.T) {
+	h := NewHandler()
+	h.Store.UpdateGauge("HeapAlloc", 30)
+	h.Store.UpdateGauge("HeapSys", 10)
+	h.Store.UpdateGauge("cpu_load;host=a", 0.5)
+	h.Store.UpdateGauge("cpu_load;host=b", 0.7)
+	h.Store.UpdateCounter("PollCount", 20)
+
+	tests := []struct {
+		name     string
+		query    string
+		wantCode int
+		want     []string
+	}{
+		{"All", "", http.StatusOK, []string{"HeapAlloc", "HeapSys", "PollCount", "cpu_load;host=a", "cpu_load;host=b"}},
+		{"By type", "type=counter", http.StatusOK, []string{"PollCount"}},
+		{"By prefix", "prefix=Heap", http.StatusOK, []string{"HeapAlloc", "HeapSys"}},
+		{"By glob", "glob=*Sys", http.StatusOK, []string{"HeapSys"}},
+		{"By regex", "regex=^cpu", http.StatusOK, []string{"cpu_load;host=a", "cpu_load;host=b"}},
+		{"By label", "label=host%3Db", http.StatusOK, []string{"cpu_load;host=b"}},
+		{"By value desc", "sort=value&order=desc&prefix=Heap", http.StatusOK, []string{"HeapAlloc", "HeapSys"}},
+		{"Wrong type", "type=histogram", http.StatusBadRequest, nil},
+		{"Wrong regex", "regex=(", http.StatusBadRequest, nil},
+		{"Wrong label", "label=host", http.StatusBadRequest, nil},
+		{"Wrong cursor", "cursor=%21", http.StatusBadRequest, nil},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.HandleValues(w, httptest.NewRequest(http.MethodGet, "/values?"+tc.query, nil))
+
+			if tc.wantCode != http.StatusOK {
+				assert.Equal(t, tc.wantCode, w.Code)
+				return
+			}
+			assert.Equal(t, tc.want, listIDs(t, w))
+		})
+	}
+}
+
+func TestHandleValuesPagination(t *testing.T) {
+	h := NewHandler()
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		h.Store.UpdateGauge(name, 1)
+	}
+
+	var got []string
+	cursor := ""
+	for pages := 0; pages < 10; pages++ {
+		w := httptest.NewRecorder()
+		h.HandleValues(w, httptest.NewRequest(http.MethodGet, "/values?limit=2&cursor="+url.QueryEscape(cursor), nil))
+		got = append(got, listIDs(t, w)...)
+
+		cursor = w.Header().Get(nextCursorHeader)
+		if cursor == "" {
+			break
+		}
+	}
+	assert.Equal(t, []string{"a", "b", "c", "d", "e"}, got)
+}
+
+func TestHandleValuesJSON(t *testing.T) {
+	h := NewHandler()
+	h.Store.UpdateGauge("HeapAlloc", 30)
+	h.Store.UpdateCounter("PollCount", 20)
+
+	w := httptest.NewRecorder()
+	h.HandleValuesJSON(w, httptest.NewRequest(http.MethodPost, "/values/", strings.NewReader(`{"type":"gauge"}`)))
+	assert.Equal(t, []string{"HeapAlloc"}, listIDs(t, w))
+
+	w = httptest.NewRecorder()
+	h.HandleValuesJSON(w, httptest.NewRequest(http.MethodPost, "/values/", nil))
+	assert.Equal(t, []string{"HeapAlloc", "PollCount"}, listIDs(t, w))
+}
+
+func TestHandleForecast(t *testing.T) {
+	h := NewHandler()
+	h.Store.UpdateGauge("HeapAlloc", 10)
+	h.Store.UpdateGauge("HeapSys", 10)
+	time.Sleep(time.Millisecond)
+	h.Store.UpdateGauge("HeapSys", 20)
+	h.Store.UpdateGauge("cpu;host=a", 1)
+	time.Sleep(time.Millisecond)
+	h.Store.UpdateGauge("cpu;host=a", 2)
+
+	router := chi.NewRouter()
+	router.Get("/forecast/{metric}", h.HandleForecast)
+
+	get := func(target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		return w
+	}
+
+	assert.Equal(t, http.StatusNotFound, get("/forecast/Unknown").Code)
+	assert.Equal(t, http.StatusUnprocessableEntity, get("/forecast/HeapAlloc").Code)
+	assert.Equal(t, http.StatusBadRequest, get("/forecast/HeapSys?method=arima").Code)
+	assert.Equal(t, http.StatusBadRequest, get("/forecast/HeapSys?threshold=abc").Code)
+
+	// Имя с метками передаётся экранированным, как в ссылках на метрики
+	w := get("/forecast/" + url.PathEscape("cpu;host=a"))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"metric":"cpu;host=a"`)
+
+	w = get("/forecast/HeapSys?threshold=100&method=holt")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp forecastResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "HeapSys", resp.Metric)
+	assert.Equal(t, "holt", resp.Method)
+	assert.Greater(t, resp.Slope, 0.0)
+	require.NotNil(t, resp.CrossesAt)
+
+	w = get("/forecast/HeapSys?threshold=0")
+	require.Equal(t, http.StatusOK, w.Code)
+	resp = forecastResponse{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Nil(t, resp.CrossesAt, "growing trend never reaches lower threshold")
+}
+
+func TestHandleValueNotFound(t *testing.T) {
+	h := NewHandler()
+	router := chi.NewRouter()
+	router.Get("/value/gauge/{metric}", h.HandleValue)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/value/gauge/Unknown", nil))
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, problem.ContentType, w.Header().Get("Content-Type"))
+
+	var p problem.Problem
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &p), "body should contain only the problem")
+	assert.Equal(t, problem.CodeMetricNotFound, p.Code)
+}
@@ -1,8 +1,16 @@
 package handlers
 
 import (
+	"github.com/RomanenkoDR/metrics/internal/alerts"
+	"github.com/RomanenkoDR/metrics/internal/anomaly"
+	"github.com/RomanenkoDR/metrics/internal/audit"
+	"github.com/RomanenkoDR/metrics/internal/crypto"
+	"github.com/RomanenkoDR/metrics/internal/health"
+	"github.com/RomanenkoDR/metrics/internal/keys"
+	"github.com/RomanenkoDR/metrics/internal/notify"
 	"github.com/RomanenkoDR/metrics/internal/storage"
-	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"time"
 )
 
 type Metrics struct {
@@ -13,9 +21,22 @@ type Metrics struct {
 }
 
 type Handler struct {
-	Store          storage.MemStorage
-	DBconn         *pgx.Conn
-	PrivateKeyPath string // Добавляем поле для хранения пути к приватному ключу
+	Store         storage.MemStorage
+	Writer        storage.StorageWriter // внешнее хранилище метрик, может быть nil
+	DB            *pgxpool.Pool         // пул соединений с базой данных, может быть nil
+	Keys          *keys.Store           // ключи подписи и расшифровки, может быть nil
+	Alerts        *alerts.Engine
+	Notifications *notify.Dispatcher
+	Anomalies     *anomaly.Detector
+	Health        *health.Checker // проверки готовности, может быть nil
+	Audit         *audit.Log      // журнал аудита изменений, может быть nil
+
+	// LegacyKey — отдельный RSA-ключ для тел в прежнем формате AES-CFB и RSA PKCS #1 v1.5, может быть nil.
+	// Расшифровка PKCS #1 v1.5 позволяет атаку на дополнение, поэтому ключи из Keys для неё
+	// не используются, а совпадающий с ними LegacyKey не принимается.
+	LegacyKey *crypto.PrivateKey
+	// ClockSkew — допустимое расхождение метки времени конверта с часами сервера; 0 — не проверять
+	ClockSkew time.Duration
 }
 
 const counterType = "counter"
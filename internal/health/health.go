@@ -0,0 +1,142 @@
+// Package health выполняет проверки готовности компонентов сервера: базы данных,
+// хранилища снимков, ключей. Проверки регистрируются при запуске и выполняются параллельно.
+package health
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultTimeout — сколько ждать одну проверку
+const DefaultTimeout = 2 * time.Second
+
+// Статусы проверок и отчёта
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// Check проверяет компонент и возвращает ошибку, если он не готов
+type Check func(ctx context.Context) error
+
+// Result — итог одной проверки
+type Result struct {
+	Name      string  `json:"name"`
+	Status    string  `json:"status"`
+	Error     string  `json:"error,omitempty"`
+	LatencyMs float64 `json:"latency_ms"`
+}
+
+// Report — итог всех проверок. Status равен ok, только если прошли все проверки.
+type Report struct {
+	Status string   `json:"status"`
+	Checks []Result `json:"checks"`
+}
+
+// OK сообщает, прошли ли все проверки
+func (r Report) OK() bool {
+	return r.Status == StatusOK
+}
+
+// ErrStillRunning — предыдущий запуск проверки ещё не завершился
+var ErrStillRunning = errors.New("previous check is still running")
+
+type namedCheck struct {
+	name  string
+	check Check
+	busy  *atomic.Bool // проверка выполняется, в том числе после истечения времени ожидания
+}
+
+// Checker хранит зарегистрированные проверки
+type Checker struct {
+	timeout time.Duration
+
+	mu     sync.RWMutex
+	checks []namedCheck
+}
+
+// New создаёт набор проверок; timeout ограничивает время каждой проверки
+func New(timeout time.Duration) *Checker {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return &Checker{timeout: timeout}
+}
+
+// Add регистрирует проверку. Проверки выполняются в порядке регистрации.
+func (c *Checker) Add(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks = append(c.checks, namedCheck{name: name, check: check, busy: &atomic.Bool{}})
+}
+
+// Run выполняет все проверки параллельно
+func (c *Checker) Run(ctx context.Context) Report {
+	report := Report{Status: StatusOK, Checks: []Result{}}
+	if c == nil {
+		return report
+	}
+
+	c.mu.RLock()
+	checks := append([]namedCheck(nil), c.checks...)
+	c.mu.RUnlock()
+
+	report.Checks = make([]Result, len(checks))
+	var wg sync.WaitGroup
+	for i, nc := range checks {
+		wg.Add(1)
+		go func(i int, nc namedCheck) {
+			defer wg.Done()
+			report.Checks[i] = c.run(ctx, nc)
+		}(i, nc)
+	}
+	wg.Wait()
+
+	for _, res := range report.Checks {
+		if res.Status != StatusOK {
+			report.Status = StatusFail
+		}
+	}
+	return report
+}
+
+// run выполняет одну проверку с ограничением по времени. Если проверка не уложилась
+// в отведённое время, она считается неуспешной, а её результат не дожидается. Пока зависшая
+// проверка не завершилась, новые запуски сразу считаются неуспешными, чтобы частые запросы
+// /readyz не накапливали зависшие проверки.
+func (c *Checker) run(ctx context.Context, nc namedCheck) Result {
+	start := time.Now()
+
+	var err error
+	if nc.busy.CompareAndSwap(false, true) {
+		ctx, cancel := context.WithTimeout(ctx, c.timeout)
+		done := make(chan error, 1)
+		go func() {
+			defer nc.busy.Store(false)
+			defer cancel()
+			done <- nc.check(ctx)
+		}()
+
+		select {
+		case err = <-done:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+	} else {
+		err = ErrStillRunning
+	}
+
+	res := Result{
+		Name:      nc.name,
+		Status:    StatusOK,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		res.Status = StatusFail
+		res.Error = err.Error()
+	}
+	return res
+}
@@ -0,0 +1,65 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun(t *testing.T) {
+	c := New(50 * time.Millisecond)
+	c.Add("ok", func(ctx context.Context) error { return nil })
+	c.Add("broken", func(ctx context.Context) error { return errors.New("disk is read-only") })
+	c.Add("slow", func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	})
+
+	start := time.Now()
+	report := c.Run(context.Background())
+	assert.Less(t, time.Since(start), time.Second, "slow check should time out")
+
+	assert.False(t, report.OK())
+	require.Len(t, report.Checks, 3)
+	assert.Equal(t, Result{Name: "ok", Status: StatusOK, LatencyMs: report.Checks[0].LatencyMs}, report.Checks[0])
+	assert.Equal(t, "disk is read-only", report.Checks[1].Error)
+	assert.Equal(t, StatusFail, report.Checks[2].Status)
+	assert.GreaterOrEqual(t, report.Checks[2].LatencyMs, 50.0)
+}
+
+func TestRunEmpty(t *testing.T) {
+	var c *Checker
+	report := c.Run(context.Background())
+	assert.True(t, report.OK())
+	assert.Empty(t, report.Checks)
+}
+
+func TestRunHungCheck(t *testing.T) {
+	c := New(20 * time.Millisecond)
+	release := make(chan struct{})
+	var calls atomic.Int32
+	c.Add("hung", func(ctx context.Context) error {
+		calls.Add(1)
+		<-release
+		return nil
+	})
+
+	report := c.Run(context.Background())
+	assert.Equal(t, context.DeadlineExceeded.Error(), report.Checks[0].Error)
+
+	// Пока зависшая проверка не завершилась, новая не запускается
+	report = c.Run(context.Background())
+	assert.Equal(t, ErrStillRunning.Error(), report.Checks[0].Error)
+	assert.Equal(t, int32(1), calls.Load())
+
+	close(release)
+	assert.Eventually(t, func() bool {
+		return c.Run(context.Background()).OK()
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, int32(2), calls.Load())
+}
@@ -0,0 +1,185 @@
+// Package identity определяет, от какого клиента пришёл запрос: по адресу, заголовку X-Real-IP,
+// арендатору, идентификатору агента или клиентскому сертификату TLS. Агента с проверенным
+// сертификатом определяет имя субъекта сертификата, а заголовок X-Agent-ID учитывается,
+// только если запрос подписан.
+// Заголовкам X-Real-IP и X-Tenant-ID доверяют, только если соединение пришло от доверенного
+// прокси, иначе клиент мог бы представляться кем угодно.
+package identity
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+)
+
+// Заголовки, по которым клиент сообщает о себе
+const (
+	HeaderRealIP  = "X-Real-IP"
+	HeaderTenant  = "X-Tenant-ID"
+	HeaderAgentID = "X-Agent-ID"
+)
+
+// Source — источник идентификатора клиента
+type Source string
+
+// Поддерживаемые источники
+const (
+	SourceIP     Source = "ip"      // адрес TCP-соединения
+	SourceRealIP Source = "real-ip" // заголовок X-Real-IP от доверенного прокси
+	SourceTenant Source = "tenant"  // заголовок X-Tenant-ID от доверенного прокси
+	SourceAgent  Source = "agent"   // агент: имя субъекта сертификата mTLS или X-Agent-ID подписанного запроса
+	SourceCert   Source = "cert"    // имя субъекта проверенного клиентского сертификата (mTLS)
+)
+
+// ParseSource проверяет имя источника
+func ParseSource(s string) (Source, error) {
+	switch src := Source(s); src {
+	case SourceIP, SourceRealIP, SourceTenant, SourceAgent, SourceCert:
+		return src, nil
+	}
+	return "", fmt.Errorf("identity: unknown source %q, should be ip, real-ip, tenant, agent or cert", s)
+}
+
+type signedKey struct{}
+
+// MarkSigned отмечает, что подпись запроса проверена и его заголовкам можно доверять
+func MarkSigned(r *http.Request) *http.Request {
+	r, verified := MarkVerifying(r)
+	verified()
+	return r
+}
+
+// MarkVerifying отмечает запрос, подпись которого проверяется по мере чтения тела:
+// заголовкам можно доверять после вызова возвращённой функции
+func MarkVerifying(r *http.Request) (*http.Request, func()) {
+	signed := &atomic.Bool{}
+	return r.WithContext(context.WithValue(r.Context(), signedKey{}, signed)), func() { signed.Store(true) }
+}
+
+// Signed сообщает, проверена ли подпись запроса
+func Signed(r *http.Request) bool {
+	signed, _ := r.Context().Value(signedKey{}).(*atomic.Bool)
+	return signed != nil && signed.Load()
+}
+
+// Certificate возвращает имя субъекта (CN) клиентского сертификата, проверенного сервером
+// при установке TLS-соединения, или пустую строку
+func Certificate(r *http.Request) string {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return ""
+	}
+	return r.TLS.VerifiedChains[0][0].Subject.CommonName
+}
+
+// Proxies — подсети доверенных обратных прокси, которые выставляют X-Real-IP и X-Tenant-ID.
+// В JSON задаётся списком адресов или подсетей в нотации CIDR.
+type Proxies []*net.IPNet
+
+// ParseProxies разбирает адреса и подсети прокси
+func ParseProxies(list []string) (Proxies, error) {
+	var proxies Proxies
+	for _, s := range list {
+		s = strings.TrimSpace(s)
+		if !strings.Contains(s, "/") {
+			ip := net.ParseIP(s)
+			if ip == nil {
+				return nil, fmt.Errorf("identity: incorrect proxy address %q", s)
+			}
+			bits := 8 * len(ip.To4())
+			if bits == 0 {
+				bits = 8 * net.IPv6len
+			}
+			s = fmt.Sprintf("%s/%d", s, bits)
+		}
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, fmt.Errorf("identity: %w", err)
+		}
+		proxies = append(proxies, n)
+	}
+	return proxies, nil
+}
+
+func (p *Proxies) UnmarshalJSON(data []byte) error {
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	proxies, err := ParseProxies(list)
+	if err != nil {
+		return err
+	}
+	*p = proxies
+	return nil
+}
+
+// Trusted сообщает, пришёл ли запрос от доверенного прокси
+func (p Proxies) Trusted(r *http.Request) bool {
+	ip := net.ParseIP(remoteIP(r))
+	if ip == nil {
+		return false
+	}
+	for _, n := range p {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Of возвращает идентификатор клиента из первого источника, который есть в запросе,
+// в виде "источник:значение". Если ни один источник не подошёл, используется адрес соединения.
+// Заголовки доверенного прокси не учитываются: для них нужен Proxies.Of.
+func Of(r *http.Request, sources ...Source) string {
+	return Proxies(nil).Of(r, sources...)
+}
+
+// Of возвращает идентификатор клиента, как функция Of, но учитывает заголовки X-Real-IP и
+// X-Tenant-ID запросов, пришедших от прокси p
+func (p Proxies) Of(r *http.Request, sources ...Source) string {
+	for _, src := range sources {
+		if v := p.value(r, src); v != "" {
+			return string(src) + ":" + v
+		}
+	}
+	return string(SourceIP) + ":" + remoteIP(r)
+}
+
+func (p Proxies) value(r *http.Request, src Source) string {
+	switch src {
+	case SourceIP:
+		return remoteIP(r)
+	case SourceRealIP:
+		if p.Trusted(r) {
+			return strings.TrimSpace(r.Header.Get(HeaderRealIP))
+		}
+	case SourceTenant:
+		if p.Trusted(r) {
+			return strings.TrimSpace(r.Header.Get(HeaderTenant))
+		}
+	case SourceAgent:
+		// Сертификат подтверждает, какой это агент, а заголовок клиент может указать любой
+		if cert := Certificate(r); cert != "" {
+			return cert
+		}
+		if Signed(r) {
+			return strings.TrimSpace(r.Header.Get(HeaderAgentID))
+		}
+	case SourceCert:
+		return Certificate(r)
+	}
+	return ""
+}
+
+// remoteIP возвращает адрес соединения без порта
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,67 @@
+package identity
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOf(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/updates/", nil)
+	r.RemoteAddr = "10.0.0.1:5000"
+	r.Header.Set(HeaderRealIP, "192.168.1.7")
+	r.Header.Set(HeaderAgentID, "host-1")
+
+	proxies, err := ParseProxies([]string{"10.0.0.0/8"})
+	require.NoError(t, err)
+
+	assert.Equal(t, "ip:10.0.0.1", Of(r))
+	assert.Equal(t, "real-ip:192.168.1.7", proxies.Of(r, SourceRealIP, SourceIP))
+	assert.Equal(t, "ip:10.0.0.1", proxies.Of(r, SourceTenant), "falls back to connection address")
+	assert.Equal(t, "real-ip:192.168.1.7", proxies.Of(r, SourceAgent, SourceRealIP), "agent ID of unsigned request is ignored")
+	assert.Equal(t, "agent:host-1", proxies.Of(MarkSigned(r), SourceAgent, SourceRealIP))
+}
+
+func TestOfUntrustedProxy(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/updates/", nil)
+	r.RemoteAddr = "203.0.113.5:5000"
+	r.Header.Set(HeaderRealIP, "192.168.1.7")
+	r.Header.Set(HeaderTenant, "acme")
+
+	proxies, err := ParseProxies([]string{"10.0.0.1"})
+	require.NoError(t, err)
+
+	assert.Equal(t, "ip:203.0.113.5", proxies.Of(r, SourceRealIP, SourceTenant), "headers from a client are ignored")
+	assert.Equal(t, "ip:203.0.113.5", Of(r, SourceRealIP), "no proxies are trusted by default")
+
+	r.RemoteAddr = "10.0.0.1:5000"
+	assert.Equal(t, "tenant:acme", proxies.Of(r, SourceTenant, SourceRealIP))
+}
+
+func TestParseProxies(t *testing.T) {
+	proxies, err := ParseProxies([]string{"10.0.0.0/8", "192.0.2.1", "::1"})
+	require.NoError(t, err)
+	assert.Len(t, proxies, 3)
+
+	_, err = ParseProxies([]string{"proxy.local"})
+	assert.Error(t, err)
+}
+
+func TestOfCertificate(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/updates/", nil)
+	r.RemoteAddr = "10.0.0.1:5000"
+	r.Header.Set(HeaderAgentID, "host-1")
+
+	assert.Equal(t, "ip:10.0.0.1", Of(r, SourceCert), "plain request has no certificate")
+
+	r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{Subject: pkix.Name{CommonName: "agent-7"}}}}}
+	assert.Equal(t, "cert:agent-7", Of(r, SourceCert))
+	assert.Equal(t, "agent:agent-7", Of(r, SourceAgent), "agent is named by the certificate, not the header")
+	assert.Equal(t, "agent:agent-7", Of(MarkSigned(r), SourceAgent))
+}
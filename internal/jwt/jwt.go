@@ -0,0 +1,274 @@
+// Package jwt проверяет и выпускает токены доступа JWT (RFC 7519), подписанные HS256 или RS256.
+// Поддерживается только компактная форма JWS; токены без подписи (alg "none") отклоняются.
+package jwt
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Алгоритмы подписи
+const (
+	HS256 = "HS256" // HMAC-SHA256 с общим секретом
+	RS256 = "RS256" // RSASSA-PKCS1-v1_5 с SHA-256, сервер знает только публичный ключ
+)
+
+// DefaultLeeway — допустимое расхождение часов при проверке exp и nbf
+const DefaultLeeway = time.Minute
+
+// MinSecretLength — наименьшая длина секрета HS256 в байтах: короткий секрет можно подобрать
+// по любому выпущенному токену
+const MinSecretLength = 32
+
+// Ошибки проверки токена
+var (
+	ErrMalformed = errors.New("jwt: malformed token")
+	ErrAlgorithm = errors.New("jwt: unsupported signing algorithm")
+	ErrSignature = errors.New("jwt: signature doesn't match")
+	ErrExpired   = errors.New("jwt: token is expired")
+	ErrNoExpiry  = errors.New("jwt: token has no expiration time")
+	ErrNotYet    = errors.New("jwt: token is not valid yet")
+	ErrIssuer    = errors.New("jwt: unexpected token issuer")
+	ErrAudience  = errors.New("jwt: token is not intended for this audience")
+)
+
+// CheckSecret проверяет, что секрет HS256 не короче MinSecretLength
+func CheckSecret(secret []byte) error {
+	if len(secret) < MinSecretLength {
+		return fmt.Errorf("jwt: HS256 secret should be at least %d bytes, got %d", MinSecretLength, len(secret))
+	}
+	return nil
+}
+
+// Claims — утверждения токена. Области доступа передаются в поле scope через пробел (RFC 8693).
+type Claims struct {
+	Subject   string   `json:"sub,omitempty"`
+	Issuer    string   `json:"iss,omitempty"`
+	Audience  Audience `json:"aud,omitempty"`
+	Scope     string   `json:"scope,omitempty"`
+	ExpiresAt int64    `json:"exp,omitempty"`
+	NotBefore int64    `json:"nbf,omitempty"`
+	IssuedAt  int64    `json:"iat,omitempty"`
+	ID        string   `json:"jti,omitempty"`
+}
+
+// Audience — получатели токена. В JSON это строка или массив строк (RFC 7519, раздел 4.1.3).
+type Audience []string
+
+func (a Audience) MarshalJSON() ([]byte, error) {
+	if len(a) == 1 {
+		return json.Marshal(a[0])
+	}
+	return json.Marshal([]string(a))
+}
+
+func (a *Audience) UnmarshalJSON(data []byte) error {
+	var one string
+	if err := json.Unmarshal(data, &one); err == nil {
+		*a = Audience{one}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return err
+	}
+	*a = many
+	return nil
+}
+
+// Scopes возвращает области доступа токена
+func (c *Claims) Scopes() []string {
+	return strings.Fields(c.Scope)
+}
+
+// HasScope сообщает, выдана ли токену область доступа scope
+func (c *Claims) HasScope(scope string) bool {
+	return slices.Contains(c.Scopes(), scope)
+}
+
+// header — заголовок JWS
+type header struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ,omitempty"`
+}
+
+// Verifier проверяет подпись и срок действия токенов. Алгоритм выбирается по заголовку токена,
+// но для каждого алгоритма используется только ключ своего типа, поэтому токен, подписанный
+// HS256 публичным RSA-ключом, не пройдёт проверку. Токены без срока действия (exp) не принимаются.
+type Verifier struct {
+	// Secret — общий секрет для HS256, алгоритм не принимается, если секрет пуст.
+	// Длину секрета проверяет CheckSecret.
+	Secret []byte
+	// PublicKey — публичный ключ для RS256, алгоритм не принимается, если ключ не задан
+	PublicKey *rsa.PublicKey
+	// Leeway — допустимое расхождение часов, по умолчанию DefaultLeeway
+	Leeway time.Duration
+	// Issuer — ожидаемый издатель (iss), если не пуст
+	Issuer string
+	// Audience — получатель, который должен быть в aud токена, если не пуст
+	Audience string
+
+	now func() time.Time
+}
+
+// Parse проверяет токен и возвращает его утверждения
+func (v *Verifier) Parse(token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrMalformed
+	}
+
+	var h header
+	if err := decodeSegment(parts[0], &h); err != nil {
+		return nil, err
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrMalformed
+	}
+
+	signed := []byte(parts[0] + "." + parts[1])
+	switch {
+	case h.Alg == HS256 && len(v.Secret) > 0:
+		if !hmac.Equal(sig, hmacSHA256(v.Secret, signed)) {
+			return nil, ErrSignature
+		}
+	case h.Alg == RS256 && v.PublicKey != nil:
+		sum := sha256.Sum256(signed)
+		if rsa.VerifyPKCS1v15(v.PublicKey, crypto.SHA256, sum[:], sig) != nil {
+			return nil, ErrSignature
+		}
+	default:
+		return nil, fmt.Errorf("%w %q", ErrAlgorithm, h.Alg)
+	}
+
+	var c Claims
+	if err := decodeSegment(parts[1], &c); err != nil {
+		return nil, err
+	}
+
+	now := time.Now
+	if v.now != nil {
+		now = v.now
+	}
+	leeway := v.Leeway
+	if leeway <= 0 {
+		leeway = DefaultLeeway
+	}
+	t := now()
+	if c.ExpiresAt == 0 {
+		return nil, ErrNoExpiry
+	}
+	if t.After(time.Unix(c.ExpiresAt, 0).Add(leeway)) {
+		return nil, ErrExpired
+	}
+	if c.NotBefore != 0 && t.Before(time.Unix(c.NotBefore, 0).Add(-leeway)) {
+		return nil, ErrNotYet
+	}
+	if v.Issuer != "" && c.Issuer != v.Issuer {
+		return nil, ErrIssuer
+	}
+	if v.Audience != "" && !slices.Contains(c.Audience, v.Audience) {
+		return nil, ErrAudience
+	}
+	return &c, nil
+}
+
+// Sign выпускает токен с утверждениями c. Ключ key — []byte для HS256 или *rsa.PrivateKey для RS256.
+func Sign(c Claims, key any) (string, error) {
+	var alg string
+	switch key.(type) {
+	case []byte:
+		alg = HS256
+	case *rsa.PrivateKey:
+		alg = RS256
+	default:
+		return "", fmt.Errorf("%w for key %T", ErrAlgorithm, key)
+	}
+
+	h, err := encodeSegment(header{Alg: alg, Typ: "JWT"})
+	if err != nil {
+		return "", err
+	}
+	payload, err := encodeSegment(c)
+	if err != nil {
+		return "", err
+	}
+	signed := h + "." + payload
+
+	var sig []byte
+	switch k := key.(type) {
+	case []byte:
+		sig = hmacSHA256(k, []byte(signed))
+	case *rsa.PrivateKey:
+		sum := sha256.Sum256([]byte(signed))
+		if sig, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, sum[:]); err != nil {
+			return "", err
+		}
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// LoadPublicKey читает публичный RSA-ключ для RS256 из PEM-файла в формате PKIX или PKCS #1
+func LoadPublicKey(path string) (*rsa.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("jwt: no PEM data in %s", path)
+	}
+	if key, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("jwt: parse public key: %w", err)
+	}
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("jwt: RS256 requires an RSA key, got %T", key)
+	}
+	return rsaKey, nil
+}
+
+func hmacSHA256(key, data []byte) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+func encodeSegment(v any) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodeSegment разбирает часть токена. Неизвестные поля допускаются: токены могут
+// выпускаться внешним сервером авторизации.
+func decodeSegment(s string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return ErrMalformed
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return ErrMalformed
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package jwt
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignParse(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	v := &Verifier{Secret: []byte("secret"), PublicKey: &rsaKey.PublicKey}
+
+	for name, key := range map[string]any{HS256: []byte("secret"), RS256: rsaKey} {
+		t.Run(name, func(t *testing.T) {
+			token, err := Sign(Claims{Subject: "agent-1", Scope: "read write", ExpiresAt: time.Now().Add(time.Hour).Unix()}, key)
+			require.NoError(t, err)
+
+			c, err := v.Parse(token)
+			require.NoError(t, err)
+			assert.Equal(t, "agent-1", c.Subject)
+			assert.True(t, c.HasScope("write"))
+			assert.False(t, c.HasScope("admin"))
+
+			parts := strings.Split(token, ".")
+			forged, _ := encodeSegment(Claims{Subject: "agent-1", Scope: "admin"})
+			_, err = v.Parse(parts[0] + "." + forged + "." + parts[2])
+			assert.ErrorIs(t, err, ErrSignature)
+		})
+	}
+
+	_, err = (&Verifier{Secret: []byte("other")}).Parse(mustSign(t, Claims{}, []byte("secret")))
+	assert.ErrorIs(t, err, ErrSignature)
+	_, err = (&Verifier{Secret: []byte("secret")}).Parse(mustSign(t, Claims{}, rsaKey))
+	assert.ErrorIs(t, err, ErrAlgorithm, "RS256 isn't accepted without public key")
+	_, err = v.Parse("not.a.token.at-all")
+	assert.ErrorIs(t, err, ErrMalformed)
+}
+
+func TestParseRejectsUnsignedAndConfusion(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	v := &Verifier{PublicKey: &rsaKey.PublicKey}
+
+	h := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","typ":"JWT"}`))
+	payload, _ := encodeSegment(Claims{Scope: "admin"})
+	_, err = v.Parse(h + "." + payload + ".")
+	assert.ErrorIs(t, err, ErrAlgorithm)
+
+	// Токен HS256, подписанный публичным ключом как секретом, не проходит проверку
+	pemKey := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: x509.MarshalPKCS1PublicKey(&rsaKey.PublicKey)})
+	_, err = v.Parse(mustSign(t, Claims{Scope: "admin"}, pemKey))
+	assert.ErrorIs(t, err, ErrAlgorithm)
+}
+
+func TestParseExpiry(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	v := &Verifier{Secret: []byte("secret"), Leeway: 30 * time.Second, now: func() time.Time { return now }}
+
+	_, err := v.Parse(mustSign(t, Claims{ExpiresAt: now.Add(-20 * time.Second).Unix()}, []byte("secret")))
+	assert.NoError(t, err, "within leeway")
+	_, err = v.Parse(mustSign(t, Claims{ExpiresAt: now.Add(-time.Minute).Unix()}, []byte("secret")))
+	assert.ErrorIs(t, err, ErrExpired)
+	_, err = v.Parse(mustSign(t, Claims{NotBefore: now.Add(time.Minute).Unix(), ExpiresAt: now.Add(time.Hour).Unix()}, []byte("secret")))
+	assert.ErrorIs(t, err, ErrNotYet)
+	_, err = v.Parse(mustSign(t, Claims{}, []byte("secret")))
+	assert.ErrorIs(t, err, ErrNoExpiry)
+}
+
+func TestParseIssuerAudience(t *testing.T) {
+	v := &Verifier{Secret: []byte("secret"), Issuer: "auth.example", Audience: "metrics"}
+	exp := time.Now().Add(time.Hour).Unix()
+
+	_, err := v.Parse(mustSign(t, Claims{Issuer: "auth.example", Audience: Audience{"metrics"}, ExpiresAt: exp}, []byte("secret")))
+	assert.NoError(t, err)
+	_, err = v.Parse(mustSign(t, Claims{Issuer: "auth.example", Audience: Audience{"billing", "metrics"}, ExpiresAt: exp}, []byte("secret")))
+	assert.NoError(t, err, "audience may be a list")
+	_, err = v.Parse(mustSign(t, Claims{Issuer: "other", Audience: Audience{"metrics"}, ExpiresAt: exp}, []byte("secret")))
+	assert.ErrorIs(t, err, ErrIssuer)
+	_, err = v.Parse(mustSign(t, Claims{Issuer: "auth.example", ExpiresAt: exp}, []byte("secret")))
+	assert.ErrorIs(t, err, ErrAudience)
+
+	var c Claims
+	require.NoError(t, json.Unmarshal([]byte(`{"aud":"metrics"}`), &c))
+	assert.Equal(t, Audience{"metrics"}, c.Audience)
+}
+
+func TestCheckSecret(t *testing.T) {
+	assert.Error(t, CheckSecret([]byte("secret")))
+	assert.NoError(t, CheckSecret([]byte(strings.Repeat("s", MinSecretLength))))
+}
+
+func TestLoadPublicKey(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "public.pem")
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o600))
+
+	key, err := LoadPublicKey(path)
+	require.NoError(t, err)
+	assert.True(t, key.Equal(&rsaKey.PublicKey))
+}
+
+func mustSign(t *testing.T, c Claims, key any) string {
+	t.Helper()
+	token, err := Sign(c, key)
+	require.NoError(t, err)
+	return token
+}
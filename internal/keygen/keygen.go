@@ -0,0 +1,390 @@
+// Package keygen реализует утилиту cmd/keygen: создание пар ключей для -crypto-key,
+// вывод отпечатков, проверку пары, пробное шифрование, генерацию ключа подписи для -k
+// и выпуск токенов доступа JWT.
+package keygen
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/RomanenkoDR/metrics/internal/crypto"
+	"github.com/RomanenkoDR/metrics/internal/jwt"
+)
+
+const usage = `Usage: keygen <command> [flags]
+
+Commands:
+  generate     create a private key for the server and a public key for the agent (-crypto-key)
+  fingerprint  print key ID and SHA-256 fingerprint of PEM key files
+  verify       check that a private and a public key form a pair
+  encrypt      encrypt stdin to an envelope as the agent does
+  decrypt      decrypt an envelope from stdin as the server does
+  secret       generate a random sign key for -k
+  token        issue a bearer JWT with read, write or admin scopes
+
+Run "keygen <command> -h" for command flags.
+`
+
+// command — подкоманда утилиты
+type command func(args []string, stdin io.Reader, stdout io.Writer) error
+
+var commands = map[string]command{
+	"generate":    generate,
+	"fingerprint": fingerprint,
+	"verify":      verify,
+	"encrypt":     encrypt,
+	"decrypt":     decrypt,
+	"secret":      secret,
+	"token":       issueToken,
+}
+
+// Run выполняет подкоманду из args и возвращает код завершения процесса
+func Run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "keygen: unknown command %q\n\n%s", args[0], usage)
+		return 2
+	}
+
+	if err := cmd(args[1:], stdin, stdout); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		fmt.Fprintf(stderr, "keygen %s: %v\n", args[0], err)
+		return 1
+	}
+	return 0
+}
+
+// newFlagSet создаёт набор флагов подкоманды, который возвращает ошибку вместо выхода из процесса
+func newFlagSet(name string, stdout io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stdout)
+	return fs
+}
+
+// generate создаёт пару ключей. Приватный RSA-ключ сохраняется в PKCS #1, который читают и прежние
+// версии сервера, эллиптический — в PKCS #8; публичный ключ — в PKIX.
+func generate(args []string, _ io.Reader, stdout io.Writer) error {
+	fs := newFlagSet("generate", stdout)
+	typ := fs.String("type", "rsa", "Key type: rsa, p256, p384, p521 or x25519")
+	bits := fs.Int("bits", 2048, "RSA key size in bits")
+	privPath := fs.String("private", "private.pem", "Output file for the private key (server -crypto-key)")
+	pubPath := fs.String("public", "public.pem", "Output file for the public key (agent -crypto-key)")
+	force := fs.Bool("force", false, "Overwrite existing files")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	privBlock, pubDER, err := newKeyPair(*typ, *bits)
+	if err != nil {
+		return err
+	}
+	pub, err := crypto.ParsePublicKey(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER}))
+	if err != nil {
+		return err
+	}
+
+	if err := writeFile(*privPath, pem.EncodeToMemory(privBlock), 0o600, *force); err != nil {
+		return err
+	}
+	if err := writeFile(*pubPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER}), 0o644, *force); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdout, "private key: %s\npublic key:  %s\ntype:        %s\nkey id:      %s\n", *privPath, *pubPath, pub.Type(), pub.ID)
+	return nil
+}
+
+// newKeyPair создаёт ключ и возвращает приватный ключ в PEM-блоке и публичный в DER (PKIX)
+func newKeyPair(typ string, bits int) (*pem.Block, []byte, error) {
+	var key, pub any
+	switch typ {
+	case "rsa":
+		if bits < 2048 {
+			return nil, nil, fmt.Errorf("RSA key should be at least 2048 bits, got %d", bits)
+		}
+		k, err := rsa.GenerateKey(rand.Reader, bits)
+		if err != nil {
+			return nil, nil, err
+		}
+		key, pub = k, &k.PublicKey
+	case "p256", "p384", "p521":
+		curve := map[string]elliptic.Curve{"p256": elliptic.P256(), "p384": elliptic.P384(), "p521": elliptic.P521()}[typ]
+		k, err := ecdsa.GenerateKey(curve, rand.Reader)
+		if err != nil {
+			return nil, nil, err
+		}
+		key, pub = k, &k.PublicKey
+	case "x25519":
+		k, err := ecdh.X25519().GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, nil, err
+		}
+		key, pub = k, k.PublicKey()
+	default:
+		return nil, nil, fmt.Errorf("unknown key type %q, should be rsa, p256, p384, p521 or x25519", typ)
+	}
+
+	pubDER, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return nil, nil, err
+	}
+	if k, ok := key.(*rsa.PrivateKey); ok {
+		return &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(k)}, pubDER, nil
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &pem.Block{Type: "PRIVATE KEY", Bytes: der}, pubDER, nil
+}
+
+// writeFile записывает файл, не перезаписывая существующий без force
+func writeFile(path string, data []byte, perm os.FileMode, force bool) error {
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if !force {
+		flags |= os.O_EXCL
+	}
+	f, err := os.OpenFile(path, flags, perm)
+	if err != nil {
+		if errors.Is(err, os.ErrExist) {
+			return fmt.Errorf("%s already exists, use -force to overwrite", path)
+		}
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// loadPublic читает публичный ключ из файла с публичным или приватным ключом
+func loadPublic(path string) (*crypto.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if priv, err := crypto.ParsePrivateKey(data); err == nil {
+		return priv.Public(), nil
+	}
+	return crypto.ParsePublicKey(data)
+}
+
+// fingerprint выводит идентификатор ключа, по которому сервер выбирает ключ расшифровки,
+// и полный отпечаток SHA-256 публичного ключа
+func fingerprint(args []string, _ io.Reader, stdout io.Writer) error {
+	fs := newFlagSet("fingerprint", stdout)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return errors.New("no key files given")
+	}
+
+	for _, path := range fs.Args() {
+		pub, err := loadPublic(path)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		fmt.Fprintf(stdout, "%s\t%s\tkey id %s\tSHA256:%s\n", path, pub.Type(), pub.ID, publicFingerprint(pub))
+	}
+	return nil
+}
+
+// publicFingerprint — SHA-256 от публичного ключа в PKIX, как у openssl pkey -pubout -outform DER
+func publicFingerprint(pub *crypto.PublicKey) string {
+	sum := sha256.Sum256(pub.PKIX())
+	return hex.EncodeToString(sum[:])
+}
+
+// verify проверяет, что ключи составляют пару, и расшифровывает пробный конверт
+func verify(args []string, _ io.Reader, stdout io.Writer) error {
+	fs := newFlagSet("verify", stdout)
+	privPath := fs.String("private", "private.pem", "Private key file")
+	pubPath := fs.String("public", "public.pem", "Public key file")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	priv, err := crypto.LoadPrivateKey(*privPath)
+	if err != nil {
+		return fmt.Errorf("%s: %w", *privPath, err)
+	}
+	pub, err := crypto.LoadPublicKey(*pubPath)
+	if err != nil {
+		return fmt.Errorf("%s: %w", *pubPath, err)
+	}
+	if priv.ID != pub.ID {
+		return fmt.Errorf("keys don't match: private key id %s, public key id %s", priv.ID, pub.ID)
+	}
+
+	sample := []byte("keygen verify")
+	sealed, err := crypto.Seal(sample, pub, "/", time.Now())
+	if err != nil {
+		return err
+	}
+	opened, _, err := crypto.Open(sealed, []*crypto.PrivateKey{priv}, "/")
+	if err != nil || !bytes.Equal(opened, sample) {
+		return fmt.Errorf("sample envelope doesn't decrypt: %v", err)
+	}
+
+	fmt.Fprintf(stdout, "OK: %s key pair, key id %s\n", pub.Type(), pub.ID)
+	return nil
+}
+
+// encrypt шифрует stdin так же, как агент шифрует тело запроса на путь -path
+func encrypt(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := newFlagSet("encrypt", stdout)
+	pubPath := fs.String("public", "public.pem", "Public key file")
+	path := fs.String("path", "/updates/", "Request path the envelope is bound to")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	pub, err := crypto.LoadPublicKey(*pubPath)
+	if err != nil {
+		return fmt.Errorf("%s: %w", *pubPath, err)
+	}
+	data, err := io.ReadAll(stdin)
+	if err != nil {
+		return err
+	}
+	sealed, err := crypto.Seal(data, pub, *path, time.Now())
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(stdout, "%s\n", sealed)
+	return err
+}
+
+// decrypt расшифровывает конверт из stdin так же, как сервер расшифровывает тело запроса
+func decrypt(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := newFlagSet("decrypt", stdout)
+	privPath := fs.String("private", "private.pem", "Private key file")
+	path := fs.String("path", "/updates/", "Request path the envelope is bound to")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	priv, err := crypto.LoadPrivateKey(*privPath)
+	if err != nil {
+		return fmt.Errorf("%s: %w", *privPath, err)
+	}
+	sealed, err := io.ReadAll(stdin)
+	if err != nil {
+		return err
+	}
+	data, _, err := crypto.Open(bytes.TrimSpace(sealed), []*crypto.PrivateKey{priv}, *path)
+	if err != nil {
+		return err
+	}
+	_, err = stdout.Write(data)
+	return err
+}
+
+// secret выводит случайный ключ подписи HMAC для -k агента и сервера
+func secret(args []string, _ io.Reader, stdout io.Writer) error {
+	fs := newFlagSet("secret", stdout)
+	size := fs.Int("bytes", 32, "Secret size in bytes")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *size < 16 {
+		return fmt.Errorf("secret should be at least 16 bytes, got %d", *size)
+	}
+
+	buf := make([]byte, *size)
+	if _, err := rand.Read(buf); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintln(stdout, hex.EncodeToString(buf))
+	return err
+}
+
+// issueToken выпускает токен доступа: HS256 с секретом сервера -jwt-secret или RS256
+// с приватным ключом, публичная часть которого задана серверу в -jwt-public-key
+func issueToken(args []string, _ io.Reader, stdout io.Writer) error {
+	fs := newFlagSet("token", stdout)
+	secret := fs.String("secret", "", "HS256 secret, same as server -jwt-secret")
+	privPath := fs.String("private", "", "RSA private key file for RS256, server -jwt-public-key is its public key")
+	subject := fs.String("sub", "", "Token subject, e.g. agent or user name")
+	scope := fs.String("scope", "read", "Comma-separated scopes: read, write, admin")
+	issuer := fs.String("iss", "", "Token issuer, same as server -jwt-issuer")
+	audience := fs.String("aud", "", "Token audience, same as server -jwt-audience")
+	ttl := fs.Duration("ttl", 24*time.Hour, "Token lifetime, server doesn't accept tokens without expiration")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *ttl <= 0 {
+		return errors.New("-ttl should be positive")
+	}
+
+	var key any
+	switch {
+	case *secret != "" && *privPath != "":
+		return errors.New("set either -secret or -private")
+	case *secret != "":
+		if err := jwt.CheckSecret([]byte(*secret)); err != nil {
+			return err
+		}
+		key = []byte(*secret)
+	case *privPath != "":
+		priv, err := crypto.LoadPrivateKey(*privPath)
+		if err != nil {
+			return fmt.Errorf("%s: %w", *privPath, err)
+		}
+		if priv.RSA() == nil {
+			return fmt.Errorf("%s: RS256 requires an RSA key", *privPath)
+		}
+		key = priv.RSA()
+	default:
+		return errors.New("-secret or -private is required")
+	}
+
+	scopes := strings.FieldsFunc(*scope, func(r rune) bool { return r == ',' || r == ' ' })
+	for _, s := range scopes {
+		if s != "read" && s != "write" && s != "admin" {
+			return fmt.Errorf("unknown scope %q, should be read, write or admin", s)
+		}
+	}
+
+	now := time.Now()
+	claims := jwt.Claims{
+		Subject:   *subject,
+		Issuer:    *issuer,
+		Scope:     strings.Join(scopes, " "),
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(*ttl).Unix(),
+	}
+	if *audience != "" {
+		claims.Audience = jwt.Audience{*audience}
+	}
+	token, err := jwt.Sign(claims, key)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(stdout, token)
+	return err
+}
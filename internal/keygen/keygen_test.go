@@ -0,0 +1,114 @@
+package keygen
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/RomanenkoDR/metrics/internal/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// run выполняет команду и возвращает код завершения, stdout и stderr
+func run(stdin string, args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := Run(args, strings.NewReader(stdin), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestGenerateVerifyEncrypt(t *testing.T) {
+	for _, typ := range []string{"rsa", "p256", "x25519"} {
+		t.Run(typ, func(t *testing.T) {
+			dir := t.TempDir()
+			priv, pub := filepath.Join(dir, "private.pem"), filepath.Join(dir, "public.pem")
+
+			code, out, errOut := run("", "generate", "-type", typ, "-private", priv, "-public", pub)
+			require.Equal(t, 0, code, errOut)
+			assert.Contains(t, out, "key id:")
+
+			info, err := os.Stat(priv)
+			require.NoError(t, err)
+			assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+			// Существующие файлы не перезаписываются
+			code, _, errOut = run("", "generate", "-type", typ, "-private", priv, "-public", pub)
+			assert.Equal(t, 1, code)
+			assert.Contains(t, errOut, "already exists")
+
+			code, out, errOut = run("", "verify", "-private", priv, "-public", pub)
+			require.Equal(t, 0, code, errOut)
+			assert.Contains(t, out, "OK")
+
+			code, sealed, errOut := run(`[{"id":"a"}]`, "encrypt", "-public", pub)
+			require.Equal(t, 0, code, errOut)
+			code, out, errOut = run(sealed, "decrypt", "-private", priv)
+			require.Equal(t, 0, code, errOut)
+			assert.Equal(t, `[{"id":"a"}]`, out)
+
+			// Конверт привязан к пути
+			code, _, _ = run(sealed, "decrypt", "-private", priv, "-path", "/update/")
+			assert.Equal(t, 1, code)
+
+			code, out, errOut = run("", "fingerprint", priv, pub)
+			require.Equal(t, 0, code, errOut)
+			lines := strings.Split(strings.TrimSpace(out), "\n")
+			require.Len(t, lines, 2)
+			assert.Equal(t, strings.Fields(lines[0])[1:], strings.Fields(lines[1])[1:], "private and public key have the same fingerprint")
+		})
+	}
+}
+
+func TestVerifyMismatch(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a", "b"} {
+		code, _, errOut := run("", "generate", "-type", "p256", "-private", filepath.Join(dir, name+".pem"), "-public", filepath.Join(dir, name+".pub"))
+		require.Equal(t, 0, code, errOut)
+	}
+
+	code, _, errOut := run("", "verify", "-private", filepath.Join(dir, "a.pem"), "-public", filepath.Join(dir, "b.pub"))
+	assert.Equal(t, 1, code)
+	assert.Contains(t, errOut, "don't match")
+}
+
+func TestSecretAndUsage(t *testing.T) {
+	code, out, _ := run("", "secret", "-bytes", "16")
+	require.Equal(t, 0, code)
+	assert.Len(t, strings.TrimSpace(out), 32)
+
+	code, _, _ = run("", "secret", "-bytes", "4")
+	assert.Equal(t, 1, code)
+
+	code, _, errOut := run("")
+	assert.Equal(t, 2, code)
+	assert.Contains(t, errOut, "Usage")
+
+	code, _, _ = run("", "unknown")
+	assert.Equal(t, 2, code)
+}
+
+func TestToken(t *testing.T) {
+	secret := strings.Repeat("s", jwt.MinSecretLength)
+	code, out, errOut := run("", "token", "-secret", secret, "-sub", "agent-1", "-scope", "write,read", "-iss", "metrics", "-aud", "server")
+	require.Equal(t, 0, code, errOut)
+
+	c, err := (&jwt.Verifier{Secret: []byte(secret), Issuer: "metrics", Audience: "server"}).Parse(strings.TrimSpace(out))
+	require.NoError(t, err)
+	assert.Equal(t, "agent-1", c.Subject)
+	assert.Equal(t, []string{"write", "read"}, c.Scopes())
+	assert.NotZero(t, c.ExpiresAt)
+
+	code, _, errOut = run("", "token", "-secret", secret, "-scope", "root")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, errOut, "unknown scope")
+	code, _, errOut = run("", "token", "-secret", "secret")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, errOut, "at least")
+	code, _, errOut = run("", "token", "-secret", secret, "-ttl", "0")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, errOut, "-ttl")
+	code, _, _ = run("", "token")
+	assert.Equal(t, 1, code)
+}
@@ -0,0 +1,217 @@
+// Package keys хранит ключи сервера: ключ подписи HMAC и набор приватных ключей для расшифровки.
+// Ключи загружаются один раз и могут быть перечитаны без перезапуска процесса.
+package keys
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/RomanenkoDR/metrics/internal/crypto"
+)
+
+// Config — источники ключей
+type Config struct {
+	SignKey     string // ключ подписи, заданный флагом -k или переменной KEY
+	SignKeyFile string // файл с ключом подписи; если задан, имеет приоритет над SignKey
+	// PrivateKeyPath — файлы приватных ключей в формате PEM или каталоги с файлами *.pem через запятую.
+	// Все найденные ключи действуют одновременно, нужный выбирается по идентификатору из конверта.
+	PrivateKeyPath string
+}
+
+// Store хранит текущие ключи. Методы безопасны для одновременного использования.
+type Store struct {
+	cfg Config
+
+	mu      sync.RWMutex
+	sign    string
+	private []*crypto.PrivateKey
+	stamp   string // размеры и время изменения файлов при последней загрузке
+}
+
+// New загружает ключи из источников конфигурации
+func New(cfg Config) (*Store, error) {
+	s := &Store{cfg: cfg}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Reload перечитывает ключи из файлов. Если хотя бы один ключ не удалось загрузить,
+// продолжают действовать прежние ключи.
+func (s *Store) Reload() error {
+	stamp := s.fileStamp()
+	sign, private, err := s.load()
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sign = sign
+	s.private = private
+	s.stamp = stamp
+	return nil
+}
+
+// Check проверяет, что ключи загружаются из файлов, не заменяя текущие
+func (s *Store) Check() error {
+	_, _, err := s.load()
+	return err
+}
+
+// load читает ключи из источников конфигурации
+func (s *Store) load() (string, []*crypto.PrivateKey, error) {
+	sign := s.cfg.SignKey
+	if s.cfg.SignKeyFile != "" {
+		data, err := os.ReadFile(s.cfg.SignKeyFile)
+		if err != nil {
+			return "", nil, fmt.Errorf("keys: read sign key: %w", err)
+		}
+		sign = string(bytes.TrimSpace(data))
+		if sign == "" {
+			return "", nil, errors.New("keys: sign key file is empty")
+		}
+	}
+
+	files, err := s.privateFiles()
+	if err != nil {
+		return "", nil, err
+	}
+	var private []*crypto.PrivateKey
+	seen := map[string]string{}
+	for _, file := range files {
+		key, err := crypto.LoadPrivateKey(file)
+		if err != nil {
+			return "", nil, fmt.Errorf("keys: load private key %s: %w", file, err)
+		}
+		if other, ok := seen[key.ID]; ok {
+			return "", nil, fmt.Errorf("keys: %s and %s contain the same key %s", other, file, key.ID)
+		}
+		seen[key.ID] = file
+		private = append(private, key)
+	}
+	return sign, private, nil
+}
+
+// privateFiles раскрывает PrivateKeyPath в список файлов ключей
+func (s *Store) privateFiles() ([]string, error) {
+	var files []string
+	for _, path := range strings.Split(s.cfg.PrivateKeyPath, ",") {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("keys: load private key: %w", err)
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+
+		matches, err := filepath.Glob(filepath.Join(path, "*.pem"))
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("keys: no *.pem files in %s", path)
+		}
+		sort.Strings(matches)
+		files = append(files, matches...)
+	}
+	return files, nil
+}
+
+// stamp описывает состояние файлов ключей, чтобы заметить их изменение
+func (s *Store) fileStamp() string {
+	var b strings.Builder
+	files, _ := s.privateFiles()
+	if s.cfg.SignKeyFile != "" {
+		files = append(files, s.cfg.SignKeyFile)
+	}
+	for _, file := range files {
+		b.WriteString(file)
+		if info, err := os.Stat(file); err == nil {
+			fmt.Fprintf(&b, ":%d:%d", info.Size(), info.ModTime().UnixNano())
+		}
+		b.WriteByte('\n')
+	}
+	// Каталоги проверяются и сами, чтобы заметить удаление ключа
+	for _, path := range strings.Split(s.cfg.PrivateKeyPath, ",") {
+		if info, err := os.Stat(strings.TrimSpace(path)); err == nil && info.IsDir() {
+			fmt.Fprintf(&b, "%s:%d\n", path, info.ModTime().UnixNano())
+		}
+	}
+	return b.String()
+}
+
+// Files сообщает, загружаются ли ключи из файлов, то есть имеет ли смысл их перечитывать
+func (s *Store) Files() bool {
+	return s != nil && (s.cfg.SignKeyFile != "" || s.cfg.PrivateKeyPath != "")
+}
+
+// SignKey возвращает ключ подписи HMAC; пустая строка означает, что подпись не проверяется
+func (s *Store) SignKey() string {
+	if s == nil {
+		return ""
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.sign
+}
+
+// PrivateKeys возвращает ключи для расшифровки; пустой список означает, что шифрование не настроено
+func (s *Store) PrivateKeys() []*crypto.PrivateKey {
+	if s == nil {
+		return nil
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.private
+}
+
+// Signing сообщает, настроен ли источник ключа подписи
+func (s *Store) Signing() bool {
+	return s != nil && (s.cfg.SignKey != "" || s.cfg.SignKeyFile != "")
+}
+
+// Watch проверяет файлы ключей каждые interval и перечитывает их при изменении,
+// пока не завершён ctx. Результат каждой перезагрузки передаётся в reloaded.
+func (s *Store) Watch(ctx context.Context, interval time.Duration, reloaded func(error)) {
+	if !s.Files() {
+		return
+	}
+
+	s.mu.RLock()
+	last := s.stamp
+	s.mu.RUnlock()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// Неудачная загрузка повторяется только после следующего изменения файлов
+			if stamp := s.fileStamp(); stamp != last {
+				last = stamp
+				reloaded(s.Reload())
+			}
+		}
+	}
+}
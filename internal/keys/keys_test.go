@@ -0,0 +1,98 @@
+package keys
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReload(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "sign.key")
+	require.NoError(t, os.WriteFile(file, []byte("first\n"), 0600))
+
+	s, err := New(Config{SignKey: "flag", SignKeyFile: file})
+	require.NoError(t, err)
+	assert.Equal(t, "first", s.SignKey(), "file has priority over flag")
+	assert.True(t, s.Signing())
+	assert.Empty(t, s.PrivateKeys())
+
+	require.NoError(t, os.WriteFile(file, []byte("second"), 0600))
+	require.NoError(t, s.Reload())
+	assert.Equal(t, "second", s.SignKey())
+
+	// Ошибка загрузки оставляет прежние ключи
+	require.NoError(t, os.WriteFile(file, nil, 0600))
+	assert.Error(t, s.Reload())
+	assert.Equal(t, "second", s.SignKey())
+}
+
+func TestPrivateKey(t *testing.T) {
+	s, err := New(Config{PrivateKeyPath: "../crypto/private.pem"})
+	require.NoError(t, err)
+	assert.Len(t, s.PrivateKeys(), 1)
+	assert.False(t, s.Signing())
+
+	_, err = New(Config{PrivateKeyPath: "missing.pem"})
+	assert.Error(t, err)
+
+	var none *Store
+	assert.Empty(t, none.SignKey())
+	assert.Empty(t, none.PrivateKeys())
+}
+
+// writeECKey сохраняет новый EC-ключ в формате PKCS #8
+func writeECKey(t *testing.T, path string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600))
+}
+
+func TestKeyRotation(t *testing.T) {
+	dir := t.TempDir()
+	writeECKey(t, filepath.Join(dir, "old.pem"))
+
+	s, err := New(Config{PrivateKeyPath: dir + ", ../crypto/private.pem"})
+	require.NoError(t, err)
+	require.Len(t, s.PrivateKeys(), 2)
+	oldID := s.PrivateKeys()[0].ID
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reloaded := make(chan error, 4)
+	go s.Watch(ctx, 10*time.Millisecond, func(err error) { reloaded <- err })
+
+	// Новый ключ подхватывается без перезапуска, старый продолжает действовать
+	writeECKey(t, filepath.Join(dir, "new.pem"))
+	select {
+	case err := <-reloaded:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("key change was not detected")
+	}
+	require.Len(t, s.PrivateKeys(), 3)
+
+	// После удаления старого ключа остаётся новый
+	require.NoError(t, os.Remove(filepath.Join(dir, "old.pem")))
+	select {
+	case err := <-reloaded:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("key removal was not detected")
+	}
+	require.Len(t, s.PrivateKeys(), 2)
+	for _, k := range s.PrivateKeys() {
+		assert.NotEqual(t, oldID, k.ID)
+	}
+}
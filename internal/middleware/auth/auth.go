@@ -0,0 +1,151 @@
+// Package auth проверяет токены доступа JWT из заголовка Authorization: Bearer и области доступа,
+// которые им выданы: read для чтения метрик и страниц, write для записи метрик, admin для
+// операционных действий.
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/RomanenkoDR/metrics/internal/jwt"
+	"github.com/RomanenkoDR/metrics/internal/problem"
+	"github.com/RomanenkoDR/metrics/internal/telemetry"
+)
+
+// Scope — область доступа токена
+type Scope string
+
+// Области доступа
+const (
+	ScopeRead  Scope = "read"  // чтение метрик, алертов и страниц, телеметрия
+	ScopeWrite Scope = "write" // запись метрик
+	ScopeAdmin Scope = "admin" // административный API, тишины и подтверждение алертов
+)
+
+// StaticSubject — субъект запроса, предъявившего статический токен вместо JWT
+const StaticSubject = "static-token"
+
+// Authorizer проверяет токены доступа. Нулевой *Authorizer пропускает все запросы,
+// что соответствует серверу без настроенных токенов.
+type Authorizer struct {
+	verifier *jwt.Verifier
+}
+
+// New создаёт проверку токенов, подписанных ключами verifier
+func New(verifier *jwt.Verifier) *Authorizer {
+	return &Authorizer{verifier: verifier}
+}
+
+// Enabled сообщает, проверяются ли токены JWT
+func (a *Authorizer) Enabled() bool {
+	return a != nil && a.verifier != nil
+}
+
+type claimsKey struct{}
+
+// ClaimsOf возвращает утверждения проверенного токена запроса или nil
+func ClaimsOf(r *http.Request) *jwt.Claims {
+	c, _ := r.Context().Value(claimsKey{}).(*jwt.Claims)
+	return c
+}
+
+type trackKey struct{}
+
+// Track позволяет middleware, подключённому до проверки токена, например журналу аудита,
+// узнать субъект запроса: возвращённая функция после обработки запроса отдаёт утверждения
+// проверенного токена, даже если ему не хватило области доступа, или nil
+func Track(r *http.Request) (*http.Request, func() *jwt.Claims) {
+	var claims *jwt.Claims
+	r = r.WithContext(context.WithValue(r.Context(), trackKey{}, &claims))
+	return r, func() *jwt.Claims { return claims }
+}
+
+// remember передаёт утверждения проверенного токена функции, полученной из Track
+func remember(r *http.Request, claims *jwt.Claims) {
+	if tracked, ok := r.Context().Value(trackKey{}).(**jwt.Claims); ok {
+		*tracked = claims
+	}
+}
+
+// withClaims сохраняет утверждения принятого токена в контексте запроса
+func withClaims(r *http.Request, claims *jwt.Claims) *http.Request {
+	remember(r, claims)
+	return r.WithContext(context.WithValue(r.Context(), claimsKey{}, claims))
+}
+
+// Require пропускает только запросы с действительным токеном, которому выдана область scope.
+// Вместо JWT можно предъявить один из статических токенов static, например -admin-key; пустые
+// значения не учитываются. Если токены JWT не настроены и статических токенов нет, запросы
+// пропускаются без проверки.
+func (a *Authorizer) Require(scope Scope, static ...string) func(http.Handler) http.Handler {
+	static = nonEmpty(static)
+
+	return func(next http.Handler) http.Handler {
+		if !a.Enabled() && len(static) == 0 {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			raw, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || raw == "" {
+				unauthorized(w, r, scope, "missing", "bearer token is required")
+				return
+			}
+
+			for _, token := range static {
+				if subtle.ConstantTimeCompare([]byte(raw), []byte(token)) == 1 {
+					claims := &jwt.Claims{Subject: StaticSubject, Scope: string(scope)}
+					next.ServeHTTP(w, withClaims(r, claims))
+					return
+				}
+			}
+			if !a.Enabled() {
+				unauthorized(w, r, scope, "invalid", "valid bearer token is required")
+				return
+			}
+
+			claims, err := a.verifier.Parse(raw)
+			if err != nil {
+				reason := "invalid"
+				if errors.Is(err, jwt.ErrExpired) {
+					reason = "expired"
+				}
+				unauthorized(w, r, scope, reason, err.Error())
+				return
+			}
+			remember(r, claims)
+			if !claims.HasScope(string(scope)) {
+				telemetry.Default.Inc("auth_failures_total", telemetry.Labels{"reason": "scope"})
+				w.Header().Set("WWW-Authenticate", `Bearer error="insufficient_scope", scope="`+string(scope)+`"`)
+				problem.Write(w, r, http.StatusForbidden, problem.CodeInsufficientScope, "token has no "+string(scope)+" scope")
+				return
+			}
+
+			next.ServeHTTP(w, withClaims(r, claims))
+		})
+	}
+}
+
+// unauthorized отвечает 401 с указанием, какая область доступа нужна
+func unauthorized(w http.ResponseWriter, r *http.Request, scope Scope, reason, detail string) {
+	telemetry.Default.Inc("auth_failures_total", telemetry.Labels{"reason": reason})
+	challenge := `Bearer scope="` + string(scope) + `"`
+	if reason != "missing" {
+		challenge = `Bearer error="invalid_token", scope="` + string(scope) + `"`
+	}
+	w.Header().Set("WWW-Authenticate", challenge)
+	problem.Write(w, r, http.StatusUnauthorized, problem.CodeUnauthorized, detail)
+}
+
+func nonEmpty(values []string) []string {
+	var out []string
+	for _, v := range values {
+		if v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,62 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/RomanenkoDR/metrics/internal/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequire(t *testing.T) {
+	a := New(&jwt.Verifier{Secret: []byte("secret")})
+	var subject string
+	send := func(a *Authorizer, scope Scope, bearer string, static ...string) *httptest.ResponseRecorder {
+		subject = ""
+		handler := a.Require(scope, static...)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if c := ClaimsOf(r); c != nil {
+				subject = c.Subject
+			}
+		}))
+		r := httptest.NewRequest(http.MethodGet, "/values", nil)
+		if bearer != "" {
+			r.Header.Set("Authorization", "Bearer "+bearer)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+	token := func(scope string) string {
+		s, err := jwt.Sign(jwt.Claims{Subject: "alice", Scope: scope, ExpiresAt: time.Now().Add(time.Hour).Unix()}, []byte("secret"))
+		require.NoError(t, err)
+		return s
+	}
+
+	assert.Equal(t, http.StatusOK, send(nil, ScopeRead, "").Code, "no token auth configured")
+
+	w := send(a, ScopeRead, "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, `Bearer scope="read"`, w.Header().Get("WWW-Authenticate"))
+
+	w = send(a, ScopeRead, "garbage")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Header().Get("WWW-Authenticate"), `error="invalid_token"`)
+
+	w = send(a, ScopeWrite, token("read"))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "insufficient_scope")
+
+	w = send(a, ScopeWrite, token("read write"))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "alice", subject)
+
+	// Статический токен принимается вместо JWT
+	assert.Equal(t, http.StatusOK, send(a, ScopeAdmin, "admin-key", "admin-key").Code)
+	assert.Equal(t, StaticSubject, subject)
+	assert.Equal(t, http.StatusOK, send(nil, ScopeAdmin, "admin-key", "admin-key").Code)
+	assert.Equal(t, http.StatusUnauthorized, send(nil, ScopeAdmin, "wrong", "admin-key").Code)
+	assert.Equal(t, http.StatusForbidden, send(a, ScopeAdmin, token("read"), "admin-key").Code)
+}
@@ -0,0 +1,30 @@
+// Package bodylimit ограничивает размер тела запроса, чтобы большой запрос не занял всю память сервера
+package bodylimit
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/RomanenkoDR/metrics/internal/problem"
+)
+
+// Handler ограничивает тело запроса max байтами. Если размер известен заранее из Content-Length,
+// запрос сразу отклоняется с ответом 413; иначе чтение сверх ограничения вернёт http.MaxBytesError.
+// Ограничение не действует, если max не больше нуля.
+func Handler(max int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if max <= 0 {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > max {
+				problem.Write(w, r, http.StatusRequestEntityTooLarge, problem.CodePayloadTooLarge, fmt.Sprintf("request body is larger than %d bytes", max))
+				return
+			}
+
+			r.Body = http.MaxBytesReader(w, r.Body, max)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package bodylimit
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/RomanenkoDR/metrics/internal/problem"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandler(t *testing.T) {
+	var read int
+	handler := Handler(8)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		read = 0
+		body, err := io.ReadAll(r.Body)
+		read = len(body)
+		if err != nil {
+			problem.WriteBodyError(w, r, problem.CodeBadRequest, err)
+		}
+	}))
+	send := func(body string, contentLength int64) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/updates/", strings.NewReader(body))
+		r.ContentLength = contentLength
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	assert.Equal(t, http.StatusOK, send("12345678", 8).Code)
+	assert.Equal(t, 8, read)
+
+	// Размер из Content-Length проверяется до обработчика
+	read = -1
+	w := send("123456789", 9)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	assert.Contains(t, w.Body.String(), string(problem.CodePayloadTooLarge))
+	assert.Equal(t, -1, read, "handler should not be called")
+
+	// Тело неизвестной длины обрывается при чтении
+	w = send(strings.Repeat("x", 100), -1)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	assert.LessOrEqual(t, read, 8)
+}
+
+func TestHandlerDisabled(t *testing.T) {
+	next := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})
+	handler := Handler(0)(next)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(strings.Repeat("x", 1<<10))))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
@@ -2,9 +2,12 @@ package gzip
 
 import (
 	"compress/gzip"
+	"fmt"
 	"io"
 	"net/http"
 	"strings"
+
+	"github.com/RomanenkoDR/metrics/internal/problem"
 )
 
 type gzipWriter struct {
@@ -27,29 +30,46 @@ func (w gzipWriter) Write(b []byte) (int, error) {
 //	return false
 //}
 
+// GzipHandle распаковывает тела запросов и сжимает ответы без ограничения размера распакованных данных
 func GzipHandle(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
-			next.ServeHTTP(w, r)
-			return
-		}
-
-		gz, err := gzip.NewWriterLevel(w, gzip.BestSpeed)
-		if err != nil {
-			io.WriteString(w, err.Error())
-			return
-		}
-		defer gz.Close()
-
-		if strings.Contains(r.Header.Get("Content-Encoding"), "gzip") {
-			r.Body, err = gzip.NewReader(r.Body)
+	return Handle(0)(next)
+}
+
+// Handle распаковывает тела запросов с Content-Encoding: gzip и сжимает ответы клиентам,
+// которые принимают gzip. maxDecompressed ограничивает размер распакованного тела, чтобы
+// небольшой сжатый запрос не занял всю память; 0 — без ограничения.
+func Handle(maxDecompressed int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Тело запроса распаковываем до сжатия ответа, чтобы ошибка ушла клиенту несжатой
+			if strings.Contains(r.Header.Get("Content-Encoding"), "gzip") {
+				body, err := gzip.NewReader(r.Body)
+				if err != nil {
+					problem.WriteBodyError(w, r, problem.CodeInvalidEncoding, fmt.Errorf("request body is not valid gzip: %w", err))
+					return
+				}
+				r.Body = body
+				if maxDecompressed > 0 {
+					r.Body = http.MaxBytesReader(w, body, maxDecompressed)
+				}
+				r.Header.Del("Content-Encoding")
+				r.ContentLength = -1
+			}
+
+			if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			gz, err := gzip.NewWriterLevel(w, gzip.BestSpeed)
 			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				problem.Write(w, r, http.StatusInternalServerError, problem.CodeInternal, err.Error())
 				return
 			}
-		}
+			defer gz.Close()
 
-		w.Header().Set("Content-Encoding", "gzip")
-		next.ServeHTTP(gzipWriter{ResponseWriter: w, Writer: gz}, r)
-	})
+			w.Header().Set("Content-Encoding", "gzip")
+			next.ServeHTTP(gzipWriter{ResponseWriter: w, Writer: gz}, r)
+		})
+	}
 }
@@ -0,0 +1,76 @@
+package gzip
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/RomanenkoDR/metrics/internal/problem"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func compress(t *testing.T, data []byte) []byte {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, err := zw.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+func TestHandleDecompressionLimit(t *testing.T) {
+	var got []byte
+	handler := Handle(1 << 10)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			problem.WriteBodyError(w, r, problem.CodeBadRequest, err)
+			return
+		}
+		got = body
+	}))
+	send := func(body []byte) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/updates/", bytes.NewReader(body))
+		r.Header.Set("Content-Encoding", "gzip")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	// Тело распаковывается и без Accept-Encoding
+	w := send(compress(t, []byte(`[]`)))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `[]`, string(got))
+
+	// Мегабайт нулей сжимается в пару килобайт, но распаковывается сверх ограничения
+	bomb := compress(t, make([]byte, 1<<20))
+	require.Less(t, len(bomb), 4<<10)
+	w = send(bomb)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	assert.Contains(t, w.Body.String(), string(problem.CodePayloadTooLarge))
+
+	w = send([]byte("not gzip"))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), string(problem.CodeInvalidEncoding))
+}
+
+func TestHandleCompressesResponse(t *testing.T) {
+	handler := GzipHandle(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, strings.Repeat("a", 100))
+	}))
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	zr, err := gzip.NewReader(w.Body)
+	require.NoError(t, err)
+	body, err := io.ReadAll(zr)
+	require.NoError(t, err)
+	assert.Equal(t, strings.Repeat("a", 100), string(body))
+}
@@ -23,6 +23,10 @@ const logfile string = "./info.log"
 
 var DebugLogger *zap.Logger
 
+// Level — минимальный уровень журнала, его можно менять во время работы.
+// В файл в любом случае пишутся сообщения не ниже Info.
+var Level = zap.NewAtomicLevelAt(zap.DebugLevel)
+
 // Инициализация логгера
 func init() {
 	dir := "./"
@@ -44,8 +48,10 @@ func init() {
 	consoleEncoder := zapcore.NewConsoleEncoder(encoderConfig)
 
 	core := zapcore.NewTee(
-		zapcore.NewCore(fileEncoder, zapcore.AddSync(file), zap.InfoLevel),
-		zapcore.NewCore(consoleEncoder, zapcore.Lock(os.Stdout), zap.DebugLevel),
+		zapcore.NewCore(fileEncoder, zapcore.AddSync(file), zap.LevelEnablerFunc(func(l zapcore.Level) bool {
+			return l >= zap.InfoLevel && Level.Enabled(l)
+		})),
+		zapcore.NewCore(consoleEncoder, zapcore.Lock(os.Stdout), Level),
 	)
 
 	DebugLogger = zap.New(core)
@@ -0,0 +1,172 @@
+// Package ratelimit ограничивает частоту запросов каждого клиента алгоритмом корзины токенов.
+// Клиент определяется пакетом identity, ограничения задаются для маршрутов отдельно.
+package ratelimit
+
+import (
+	"math"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/RomanenkoDR/metrics/internal/identity"
+	"github.com/RomanenkoDR/metrics/internal/problem"
+	"github.com/RomanenkoDR/metrics/internal/telemetry"
+)
+
+// apiPrefix не учитывается при сопоставлении маршрутов, чтобы одно правило действовало
+// и для /api/v1, и для прежних путей
+const apiPrefix = "/api/v1"
+
+// idleTimeout — через сколько удаляется корзина клиента, который не присылает запросы
+const idleTimeout = 10 * time.Minute
+
+// defaultRoute — имя маршрута для ограничения по умолчанию в метриках
+const defaultRoute = "default"
+
+// DefaultMaxClients — сколько корзин клиентов хранится, если MaxClients не задан
+const DefaultMaxClients = 10000
+
+// overflowClient — общая корзина маршрута для новых клиентов, когда число корзин достигло
+// MaxClients. Корзины известных клиентов не вытесняются, поэтому сменой идентификатора
+// нельзя получить новую корзину.
+const overflowClient = "\x00overflow"
+
+// bucket — корзина токенов одного клиента на одном маршруте
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// take забирает токен, если он есть. Иначе возвращает, через сколько токен появится.
+func (b *bucket) take(l Limit, now time.Time) (bool, time.Duration) {
+	b.tokens = math.Min(float64(l.Burst), b.tokens+now.Sub(b.last).Seconds()*l.Rate)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	wait := time.Duration((1 - b.tokens) / l.Rate * float64(time.Second))
+	return false, wait
+}
+
+// Limiter хранит корзины клиентов
+type Limiter struct {
+	cfg Config
+	now func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// New создаёт ограничитель
+func New(cfg Config) *Limiter {
+	if len(cfg.Identity) == 0 {
+		cfg.Identity = []identity.Source{identity.SourceIP}
+	}
+	if cfg.MaxClients <= 0 {
+		cfg.MaxClients = DefaultMaxClients
+	}
+
+	l := &Limiter{
+		cfg:     cfg,
+		now:     time.Now,
+		buckets: map[string]*bucket{},
+	}
+	telemetry.Default.GaugeFunc("ratelimit_clients", nil, func() float64 {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		return float64(len(l.buckets))
+	})
+	return l
+}
+
+// route находит ограничение для запроса. ok равен false, если запрос не ограничивается.
+func (l *Limiter) route(r *http.Request) (name string, limit Limit, ok bool) {
+	p := r.URL.Path
+	if rest, found := strings.CutPrefix(p, apiPrefix); found && rest != "" {
+		p = rest
+	}
+
+	for _, rt := range l.cfg.Routes {
+		if rt.Method != "" && rt.Method != r.Method {
+			continue
+		}
+		if matched, _ := path.Match(rt.Path, p); matched {
+			return rt.Method + " " + rt.Path, rt.Limit, true
+		}
+	}
+	if l.cfg.Default != nil {
+		return defaultRoute, *l.cfg.Default, true
+	}
+	return "", Limit{}, false
+}
+
+// allow забирает токен из корзины клиента на маршруте
+func (l *Limiter) allow(client, route string, limit Limit) (bool, time.Duration) {
+	now := l.now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now, false)
+
+	key := route + "\x00" + client
+	b, ok := l.buckets[key]
+	if !ok && len(l.buckets) >= l.cfg.MaxClients {
+		l.sweep(now, true)
+		if len(l.buckets) >= l.cfg.MaxClients {
+			telemetry.Default.Inc("ratelimit_overflow_total", telemetry.Labels{"route": route})
+			key = route + overflowClient
+			b, ok = l.buckets[key]
+		}
+	}
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), last: now}
+		l.buckets[key] = b
+	}
+	return b.take(limit, now)
+}
+
+// sweep удаляет корзины неактивных клиентов не чаще раза в idleTimeout, если force равен false.
+// Вызывается под блокировкой.
+func (l *Limiter) sweep(now time.Time, force bool) {
+	if !force && now.Sub(l.lastSweep) < idleTimeout {
+		return
+	}
+	l.lastSweep = now
+
+	for k, b := range l.buckets {
+		if now.Sub(b.last) > idleTimeout {
+			delete(l.buckets, k)
+		}
+	}
+}
+
+// Middleware отвечает 429 с заголовком Retry-After, если клиент превысил ограничение маршрута
+func (l *Limiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route, limit, ok := l.route(r)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		allowed, wait := l.allow(l.cfg.TrustedProxies.Of(r, l.cfg.Identity...), route, limit)
+		if !allowed {
+			telemetry.Default.Inc("ratelimit_rejected_total", telemetry.Labels{"route": route})
+
+			// Retry-After передаётся в целых секундах, округляем вверх
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			problem.Write(w, r, http.StatusTooManyRequests, problem.CodeRateLimited, "rate limit exceeded, retry in "+wait.Round(time.Millisecond).String())
+			return
+		}
+
+		telemetry.Default.Inc("ratelimit_allowed_total", telemetry.Labels{"route": route})
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,101 @@
+package ratelimit
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/RomanenkoDR/metrics/internal/identity"
+)
+
+// Limit — параметры корзины токенов: Rate запросов в секунду в среднем и не более Burst подряд
+type Limit struct {
+	Rate  float64 `json:"rate"`
+	Burst int     `json:"burst"`
+}
+
+// Route — ограничение для запросов, путь которых соответствует шаблону Path (синтаксис path.Match).
+// Префикс версии API /api/v1 при сопоставлении не учитывается. Пустой Method совпадает с любым.
+type Route struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	Limit
+}
+
+// Config — настройки ограничения частоты запросов
+type Config struct {
+	// Identity — источники идентификатора клиента в порядке приоритета, по умолчанию ip.
+	// Ограничение проверяется до подписи запроса, поэтому источник agent учитывается только
+	// для клиентов с проверенным сертификатом.
+	Identity []identity.Source `json:"identity"`
+	// TrustedProxies — адреса и подсети прокси, от которых принимаются X-Real-IP и X-Tenant-ID;
+	// обязательны для источников real-ip и tenant
+	TrustedProxies identity.Proxies `json:"trusted_proxies"`
+	// MaxClients — сколько корзин клиентов хранится, по умолчанию DefaultMaxClients
+	MaxClients int `json:"max_clients"`
+	// Default — ограничение для маршрутов, не перечисленных в Routes; если не задано, они не ограничиваются
+	Default *Limit `json:"default"`
+	// Routes — ограничения отдельных маршрутов, применяется первое совпавшее
+	Routes []Route `json:"routes"`
+}
+
+// LoadConfig загружает настройки из JSON-файла
+func LoadConfig(path string) (Config, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return Config{}, err
+	}
+	defer file.Close()
+
+	var cfg Config
+	if err := json.NewDecoder(file).Decode(&cfg); err != nil {
+		return Config{}, err
+	}
+	return cfg, cfg.validate()
+}
+
+// validate проверяет настройки
+func (cfg *Config) validate() error {
+	for _, src := range cfg.Identity {
+		if _, err := identity.ParseSource(string(src)); err != nil {
+			return err
+		}
+		if (src == identity.SourceRealIP || src == identity.SourceTenant) && len(cfg.TrustedProxies) == 0 {
+			return fmt.Errorf("identity source %s requires trusted_proxies", src)
+		}
+	}
+	if cfg.MaxClients < 0 {
+		return errors.New("max_clients should not be negative")
+	}
+	if cfg.Default != nil {
+		if err := cfg.Default.validate(); err != nil {
+			return fmt.Errorf("default: %w", err)
+		}
+	}
+	for i, r := range cfg.Routes {
+		if r.Path == "" {
+			return fmt.Errorf("route %d: empty path", i)
+		}
+		if _, err := path.Match(r.Path, ""); err != nil {
+			return fmt.Errorf("route %d: incorrect path pattern %q", i, r.Path)
+		}
+		if err := r.Limit.validate(); err != nil {
+			return fmt.Errorf("route %d: %w", i, err)
+		}
+		cfg.Routes[i].Method = strings.ToUpper(r.Method)
+	}
+	return nil
+}
+
+func (l Limit) validate() error {
+	if l.Rate <= 0 {
+		return errors.New("rate should be positive")
+	}
+	if l.Burst < 1 {
+		return errors.New("burst should be at least 1")
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/RomanenkoDR/metrics/internal/identity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMiddleware(t *testing.T) {
+	proxies, err := identity.ParseProxies([]string{"192.0.2.0/24"})
+	require.NoError(t, err)
+	l := New(Config{
+		Identity:       []identity.Source{identity.SourceTenant, identity.SourceIP},
+		TrustedProxies: proxies,
+		Routes: []Route{
+			{Method: http.MethodPost, Path: "/updates/", Limit: Limit{Rate: 1, Burst: 2}},
+		},
+	})
+	now := time.Unix(1000, 0)
+	l.now = func() time.Time { return now }
+
+	handler := l.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	send := func(target, tenant string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, target, nil)
+		if tenant != "" {
+			r.Header.Set(identity.HeaderTenant, tenant)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	assert.Equal(t, http.StatusOK, send("/updates/", "a").Code)
+	assert.Equal(t, http.StatusOK, send("/api/v1/updates/", "a").Code, "same limit for versioned path")
+	w := send("/updates/", "a")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+	assert.Contains(t, w.Body.String(), "rate_limited")
+
+	assert.Equal(t, http.StatusOK, send("/updates/", "b").Code, "other tenant has its own bucket")
+	assert.Equal(t, http.StatusOK, send("/update/", "a").Code, "route without limit")
+
+	now = now.Add(time.Second)
+	assert.Equal(t, http.StatusOK, send("/updates/", "a").Code, "token refilled")
+	assert.Equal(t, http.StatusTooManyRequests, send("/updates/", "a").Code)
+}
+
+func TestSpoofedTenant(t *testing.T) {
+	l := New(Config{
+		Identity: []identity.Source{identity.SourceTenant, identity.SourceIP},
+		Default:  &Limit{Rate: 1, Burst: 1},
+	})
+	handler := l.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	send := func(tenant string) int {
+		r := httptest.NewRequest(http.MethodPost, "/updates/", nil)
+		r.Header.Set(identity.HeaderTenant, tenant)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+	assert.Equal(t, http.StatusOK, send("a"))
+	assert.Equal(t, http.StatusTooManyRequests, send("b"), "tenant header from a non-proxy is ignored")
+}
+
+func TestMaxClients(t *testing.T) {
+	l := New(Config{Default: &Limit{Rate: 1, Burst: 1}, MaxClients: 2})
+	now := time.Unix(1000, 0)
+	l.now = func() time.Time { return now }
+
+	limit := Limit{Rate: 1, Burst: 1}
+	allowed, _ := l.allow("a", defaultRoute, limit)
+	assert.True(t, allowed)
+	allowed, _ = l.allow("b", defaultRoute, limit)
+	assert.True(t, allowed)
+
+	allowed, _ = l.allow("c", defaultRoute, limit)
+	assert.True(t, allowed, "first new client takes the overflow bucket")
+	allowed, _ = l.allow("d", defaultRoute, limit)
+	assert.False(t, allowed, "new clients share the overflow bucket")
+	allowed, _ = l.allow("a", defaultRoute, limit)
+	assert.False(t, allowed, "known buckets are not evicted")
+	assert.Len(t, l.buckets, 3)
+
+	now = now.Add(idleTimeout + time.Second)
+	allowed, _ = l.allow("d", defaultRoute, limit)
+	assert.True(t, allowed, "idle buckets are swept to make room")
+}
+
+func TestDefaultLimit(t *testing.T) {
+	l := New(Config{Default: &Limit{Rate: 0.5, Burst: 1}})
+	handler := l.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	send := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/values", nil))
+		return w
+	}
+	assert.Equal(t, http.StatusOK, send().Code)
+	w := send()
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "2", w.Header().Get("Retry-After"))
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	write := func(data string) string {
+		file := filepath.Join(dir, "limits.json")
+		require.NoError(t, os.WriteFile(file, []byte(data), 0600))
+		return file
+	}
+
+	cfg, err := LoadConfig(write(`{"identity":["agent","ip"],"routes":[{"method":"post","path":"/update/*/*/*","rate":5,"burst":10}]}`))
+	require.NoError(t, err)
+	assert.Equal(t, http.MethodPost, cfg.Routes[0].Method)
+
+	for _, data := range []string{
+		`{"identity":["cookie"]}`,
+		`{"identity":["tenant","ip"]}`,
+		`{"identity":["real-ip"],"trusted_proxies":["proxy.local"]}`,
+		`{"max_clients":-1}`,
+		`{"routes":[{"path":"/updates/","rate":0,"burst":1}]}`,
+		`{"routes":[{"path":"[","rate":1,"burst":1}]}`,
+		`{"default":{"rate":1}}`,
+	} {
+		_, err := LoadConfig(write(data))
+		assert.Error(t, err, data)
+	}
+}
@@ -0,0 +1,80 @@
+// Package subnet пропускает запросы только от клиентов из доверенных подсетей
+package subnet
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/RomanenkoDR/metrics/internal/identity"
+	"github.com/RomanenkoDR/metrics/internal/problem"
+	"github.com/RomanenkoDR/metrics/internal/telemetry"
+)
+
+// Trusted — список доверенных подсетей
+type Trusted []*net.IPNet
+
+// Parse разбирает подсети в нотации CIDR, перечисленные через запятую
+func Parse(s string) (Trusted, error) {
+	var nets Trusted
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		_, n, err := net.ParseCIDR(part)
+		if err != nil {
+			return nil, fmt.Errorf("subnet: %w", err)
+		}
+		nets = append(nets, n)
+	}
+	if len(nets) == 0 {
+		return nil, fmt.Errorf("subnet: no subnets in %q", s)
+	}
+	return nets, nil
+}
+
+// Contains сообщает, входит ли адрес ip в одну из подсетей
+func (t Trusted) Contains(ip net.IP) bool {
+	for _, n := range t {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Handler отклоняет с ответом 403 запросы, в которых адрес из заголовка X-Real-IP не входит
+// в доверенные подсети. Если checkRemote равен true, в доверенной подсети должен быть и адрес
+// соединения, чтобы клиент извне не мог указать в заголовке чужой адрес.
+func Handler(trusted Trusted, checkRemote bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			realIP := net.ParseIP(strings.TrimSpace(r.Header.Get(identity.HeaderRealIP)))
+			if realIP == nil || !trusted.Contains(realIP) {
+				reject(w, r, "header")
+				return
+			}
+			if checkRemote && !trusted.Contains(remoteIP(r)) {
+				reject(w, r, "remote")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func reject(w http.ResponseWriter, r *http.Request, reason string) {
+	telemetry.Default.Inc("untrusted_requests_total", telemetry.Labels{"reason": reason})
+	problem.Write(w, r, http.StatusForbidden, problem.CodeUntrustedNetwork, "client address is not in a trusted subnet")
+}
+
+// remoteIP возвращает адрес соединения без порта
+func remoteIP(r *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return net.ParseIP(host)
+}
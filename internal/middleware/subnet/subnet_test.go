@@ -0,0 +1,48 @@
+package subnet
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/RomanenkoDR/metrics/internal/identity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	nets, err := Parse("10.0.0.0/8, 192.168.1.0/24,fd00::/8")
+	require.NoError(t, err)
+	assert.Len(t, nets, 3)
+
+	_, err = Parse("10.0.0.1")
+	assert.Error(t, err, "address without prefix length")
+	_, err = Parse(" , ")
+	assert.Error(t, err)
+}
+
+func TestHandler(t *testing.T) {
+	nets, err := Parse("10.0.0.0/8,fd00::/8")
+	require.NoError(t, err)
+
+	send := func(checkRemote bool, realIP, remote string) int {
+		handler := Handler(nets, checkRemote)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		r := httptest.NewRequest(http.MethodPost, "/updates/", nil)
+		r.RemoteAddr = remote
+		if realIP != "" {
+			r.Header.Set(identity.HeaderRealIP, realIP)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, send(false, "10.1.2.3", "203.0.113.5:4000"))
+	assert.Equal(t, http.StatusOK, send(false, "fd00::1", "203.0.113.5:4000"))
+	assert.Equal(t, http.StatusForbidden, send(false, "192.168.1.1", "10.0.0.1:4000"))
+	assert.Equal(t, http.StatusForbidden, send(false, "", "10.0.0.1:4000"), "header is required")
+	assert.Equal(t, http.StatusForbidden, send(false, "not-an-ip", "10.0.0.1:4000"))
+
+	assert.Equal(t, http.StatusOK, send(true, "10.1.2.3", "10.0.0.1:4000"))
+	assert.Equal(t, http.StatusForbidden, send(true, "10.1.2.3", "203.0.113.5:4000"), "spoofed header from outside")
+}
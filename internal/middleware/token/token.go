@@ -5,52 +5,343 @@ import (
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"hash"
 	"io"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/RomanenkoDR/metrics/internal/identity"
+	"github.com/RomanenkoDR/metrics/internal/problem"
+	"github.com/RomanenkoDR/metrics/internal/telemetry"
 )
 
-func CheckReqSign(key string) func(http.Handler) http.Handler {
+// Header — заголовок с подписью тела запроса или ответа
+const Header = "HashSHA256"
+
+// Sign вычисляет подпись данных: HMAC-SHA256 с ключом key в hex-представлении.
+// Та же схема используется для заголовка HashSHA256 в запросах агента.
+func Sign(key string, data []byte) string {
+	h := hmac.New(sha256.New, []byte(key))
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Verify проверяет подпись sign, полученную от Sign
+func Verify(key string, data []byte, sign string) bool {
+	got, err := hex.DecodeString(sign)
+	if err != nil {
+		return false
+	}
+	h := hmac.New(sha256.New, []byte(key))
+	h.Write(data)
+	return hmac.Equal(got, h.Sum(nil))
+}
+
+// Options — настройки проверки подписи запросов
+type Options struct {
+	// Strict отклоняет неподписанные запросы, изменяющие данные, и подписи без метки времени и nonce
+	Strict bool
+	// Skew — допустимое расхождение метки времени запроса с часами сервера, по умолчанию DefaultSkew
+	Skew time.Duration
+	// Nonces — сколько использованных nonce помнить для защиты от повтора, по умолчанию DefaultNonces
+	Nonces int
+	// Skip — префиксы путей, запросы к которым в строгом режиме принимаются без подписи
+	Skip []string
+	// Stream — префиксы путей, тело которых проверяется по мере чтения, а не читается заранее.
+	// Обработчик таких запросов должен дочитать тело до конца, прежде чем что-либо менять,
+	// и отвечать описанием ошибки *problem.Problem, полученной при чтении.
+	Stream []string
+}
+
+// CheckReqSign проверяет подпись запроса в заголовке HashSHA256 и подписывает ответ на
+// подписанный запрос тем же ключом, в том числе ответ об ошибке проверки подписи. Ключ
+// запрашивается у key на каждый запрос, чтобы его можно было заменить без перезапуска.
+//
+// Если в запросе есть заголовки X-Signature-Timestamp и X-Signature-Nonce, подписываются
+// метка времени, nonce, метод, путь, строка запроса и тело; запрос с устаревшей меткой или
+// повторным nonce отклоняется, а если кэш nonce заполнен — отклоняется с кодом 503.
+// Иначе подписывается только тело, что допускается лишь в нестрогом режиме.
+//
+// Тело запросов к путям из Options.Stream не копится в памяти: подпись вычисляется по мере
+// чтения и сверяется в конце тела, см. verifiedBody.
+func CheckReqSign(key func() string, opt Options) func(http.Handler) http.Handler {
+	if opt.Skew <= 0 {
+		opt.Skew = DefaultSkew
+	}
+	if opt.Nonces <= 0 {
+		opt.Nonces = DefaultNonces
+	}
+	nonces := newNonceCache(opt.Nonces)
+
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			if r.Header.Get("HashSHA256") == "" {
-				fmt.Println("Skip hash calculation")
+			key := key()
+			sign := r.Header.Get(Header)
+			if key == "" || sign == "" && !opt.required(r) {
 				next.ServeHTTP(w, r)
 				return
 			}
-
-			var sign []byte
-
-			body, err := io.ReadAll(r.Body)
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusBadRequest)
+			if sign == "" {
+				telemetry.Default.Inc("signature_failures_total", telemetry.Labels{"reason": "missing"})
+				problem.Write(w, r, http.StatusUnauthorized, problem.CodeUnauthorized, "request signature is required")
 				return
 			}
 
-			// Calculate hash for request body
-			h := hmac.New(sha256.New, []byte(key))
-			h.Write(body)
-			sha256sum := h.Sum(nil)
+			timestamp, nonce := r.Header.Get(HeaderTimestamp), r.Header.Get(HeaderNonce)
+			sw := newSignedWriter(w, key, nonce)
+			defer sw.finish()
+			w = sw
 
-			// Read sign from request
-			sign, err = hex.DecodeString(r.Header.Get("HashSHA256"))
+			want, err := hex.DecodeString(sign)
 			if err != nil {
-				http.Error(w, err.Error(), http.StatusBadRequest)
+				telemetry.Default.Inc("signature_failures_total", telemetry.Labels{"reason": "malformed"})
+				problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidSignature, "HashSHA256 should be a hex string")
 				return
 			}
 
-			if !hmac.Equal(sign, sha256sum) {
-				http.Error(w, "Corrupted sign on request. ", http.StatusBadRequest)
+			replayable := timestamp == "" && nonce == ""
+			if replayable && opt.Strict {
+				telemetry.Default.Inc("signature_failures_total", telemetry.Labels{"reason": "malformed"})
+				problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidSignature, "signature should include "+HeaderTimestamp+" and "+HeaderNonce)
 				return
 			}
 
-			// Add calculated hash to headers
-			w.Header().Set("HashSHA256", hex.EncodeToString(sha256sum))
+			mac := hmac.New(sha256.New, []byte(key))
+			if !replayable {
+				mac.Write(material(timestamp, nonce, r.Method, r.URL.Path, r.URL.RawQuery, nil))
+			}
+			// Метка времени и nonce проверяются после подписи, чтобы чужие запросы не заполняли кэш
+			check := func() *problem.Problem {
+				if !hmac.Equal(want, mac.Sum(nil)) {
+					telemetry.Default.Inc("signature_failures_total", telemetry.Labels{"reason": "mismatch"})
+					return problem.New(http.StatusBadRequest, problem.CodeInvalidSignature, "request signature doesn't match")
+				}
+				if replayable {
+					return nil
+				}
+				var full *fullError
+				switch err := nonces.checkFresh(timestamp, nonce, time.Now(), opt.Skew); {
+				case errors.As(err, &full):
+					telemetry.Default.Inc("signature_failures_total", telemetry.Labels{"reason": "nonces_full"})
+					w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(full.Retry.Seconds()))))
+					return problem.New(http.StatusServiceUnavailable, problem.CodeTooManyNonces, err.Error())
+				case errors.Is(err, errReplay):
+					telemetry.Default.Inc("signature_failures_total", telemetry.Labels{"reason": "replay"})
+					return problem.New(http.StatusBadRequest, problem.CodeReplayedRequest, err.Error())
+				case err != nil:
+					telemetry.Default.Inc("signature_failures_total", telemetry.Labels{"reason": "stale"})
+					return problem.New(http.StatusBadRequest, problem.CodeStaleSignature, err.Error())
+				}
+				return nil
+			}
 
-			// Restore request body for further processing
-			r.Body = io.NopCloser(bytes.NewBuffer(body))
+			// Заголовки подписанного запроса, например X-Agent-ID, можно использовать для идентификации
+			if opt.streamed(r) {
+				var verified func()
+				r, verified = identity.MarkVerifying(r)
+				r.Body = &verifiedBody{body: r.Body, mac: mac, check: check, verified: verified}
+			} else {
+				body, err := io.ReadAll(io.TeeReader(r.Body, mac))
+				if err != nil {
+					problem.WriteBodyError(w, r, problem.CodeBadRequest, err)
+					return
+				}
+				if p := check(); p != nil {
+					p.Instance = r.URL.Path
+					p.Write(w)
+					return
+				}
+				// Восстанавливаем тело запроса для обработчика
+				r.Body = io.NopCloser(bytes.NewBuffer(body))
+				r = identity.MarkSigned(r)
+			}
 
 			next.ServeHTTP(w, r)
 		})
 	}
 }
+
+// required сообщает, должен ли запрос быть подписан. В строгом режиме подпись обязательна
+// для всех запросов, кроме чтения и путей из Skip.
+func (opt Options) required(r *http.Request) bool {
+	if !opt.Strict {
+		return false
+	}
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+	for _, prefix := range opt.Skip {
+		if strings.HasPrefix(r.URL.Path, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// streamed сообщает, проверяется ли тело запроса по мере чтения
+func (opt Options) streamed(r *http.Request) bool {
+	for _, prefix := range opt.Stream {
+		if strings.HasPrefix(r.URL.Path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// verifiedBody вычисляет подпись тела по мере чтения. В конце тела вместо io.EOF возвращается
+// *problem.Problem, если подпись не совпала или запрос повторный, поэтому обработчик узнаёт
+// о неверной подписи до того, как применит прочитанные данные.
+type verifiedBody struct {
+	body     io.ReadCloser
+	mac      hash.Hash
+	check    func() *problem.Problem
+	verified func()
+
+	done bool
+	err  error // результат проверки, возвращается при каждом чтении после конца тела
+}
+
+func (b *verifiedBody) Read(p []byte) (int, error) {
+	if b.done {
+		return 0, b.err
+	}
+
+	n, err := b.body.Read(p)
+	b.mac.Write(p[:n])
+	if !errors.Is(err, io.EOF) {
+		return n, err
+	}
+
+	b.done, b.err = true, io.EOF
+	if p := b.check(); p != nil {
+		b.err = p
+	} else {
+		b.verified()
+	}
+	return n, b.err
+}
+
+func (b *verifiedBody) Close() error {
+	return b.body.Close()
+}
+
+// maxBufferedResponse — ответ до этого размера копится, и подпись отправляется в заголовке.
+// Ответ больше отправляется по мере записи, а подпись — в трейлере HashSHA256.
+const maxBufferedResponse = 64 << 10
+
+// responseMAC возвращает HMAC-SHA256 ответа с кодом status на запрос с nonce, к которому
+// остаётся дописать тело ответа. Код ответа и nonce подписываются, чтобы ответ нельзя было
+// выдать за ответ на другой запрос или с другим кодом.
+func responseMAC(key string, status int, nonce string) hash.Hash {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(strconv.Itoa(status) + "\n" + nonce + "\n"))
+	return mac
+}
+
+// VerifyResponse проверяет подпись ответа resp на подписанный запрос с nonce. Подпись берётся
+// из заголовка HashSHA256, а у потокового ответа — из одноимённого трейлера. Тело ответа
+// читается целиком и заменяется копией для дальнейшего разбора.
+func VerifyResponse(key string, resp *http.Response, nonce string) error {
+	mac := responseMAC(key, resp.StatusCode, nonce)
+	body, err := io.ReadAll(io.TeeReader(resp.Body, mac))
+	if err != nil {
+		return err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	sign := resp.Header.Get(Header)
+	if sign == "" {
+		sign = resp.Trailer.Get(Header)
+	}
+	if sign == "" {
+		return fmt.Errorf("server response with status %d is not signed", resp.StatusCode)
+	}
+	got, err := hex.DecodeString(sign)
+	if err != nil || !hmac.Equal(got, mac.Sum(nil)) {
+		return errors.New("server response signature doesn't match")
+	}
+	return nil
+}
+
+// signedWriter подписывает ответ: небольшой ответ копит и отправляет с подписью в заголовке,
+// а ответ больше maxBufferedResponse или сброшенный через Flush отправляет по мере записи
+// с подписью в трейлере
+type signedWriter struct {
+	http.ResponseWriter
+	key, nonce string
+
+	mac       hash.Hash
+	status    int
+	streaming bool
+	body      bytes.Buffer
+}
+
+func newSignedWriter(w http.ResponseWriter, key, nonce string) *signedWriter {
+	return &signedWriter{ResponseWriter: w, key: key, nonce: nonce}
+}
+
+func (w *signedWriter) WriteHeader(status int) {
+	if w.mac != nil {
+		return
+	}
+	w.status = status
+	w.mac = responseMAC(w.key, status, w.nonce)
+}
+
+func (w *signedWriter) Write(b []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	w.mac.Write(b)
+	if w.streaming {
+		return w.ResponseWriter.Write(b)
+	}
+	w.body.Write(b)
+	if w.body.Len() > maxBufferedResponse {
+		if err := w.stream(); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// Flush отправляет накопленную часть ответа; подпись будет передана в трейлере
+func (w *signedWriter) Flush() {
+	w.WriteHeader(http.StatusOK)
+	if !w.streaming {
+		w.stream()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// stream переключает ответ на отправку по мере записи с подписью в трейлере
+func (w *signedWriter) stream() error {
+	w.streaming = true
+	h := w.Header()
+	h.Del("Content-Length")
+	h.Del(Header)
+	h.Add("Trailer", Header)
+	w.ResponseWriter.WriteHeader(w.status)
+	_, err := w.ResponseWriter.Write(w.body.Bytes())
+	w.body = bytes.Buffer{}
+	return err
+}
+
+// finish отправляет подпись ответа: в трейлере, если ответ уже отправляется, иначе в заголовке
+// вместе с накопленным ответом
+func (w *signedWriter) finish() {
+	w.WriteHeader(http.StatusOK)
+	sign := hex.EncodeToString(w.mac.Sum(nil))
+	w.Header().Set(Header, sign)
+	if w.streaming {
+		return
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	w.ResponseWriter.Write(w.body.Bytes())
+}
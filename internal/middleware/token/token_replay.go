@@ -0,0 +1,137 @@
+package token
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Заголовки подписи запроса со защитой от повтора
+const (
+	HeaderTimestamp = "X-Signature-Timestamp" // время подписи, секунды Unix
+	HeaderNonce     = "X-Signature-Nonce"     // случайная строка, уникальная для каждого запроса
+)
+
+// Значения по умолчанию для Options
+const (
+	DefaultSkew   = 5 * time.Minute
+	DefaultNonces = 100000
+)
+
+// maxNonceLen ограничивает длину nonce, чтобы кэш не занимал лишнюю память
+const maxNonceLen = 128
+
+// Ошибки проверки метки времени и nonce
+var (
+	errStale  = errors.New("request timestamp is outside the allowed window")
+	errReplay = errors.New("request nonce has already been used")
+	errNonce  = errors.New("request nonce should be 1-128 characters")
+)
+
+// fullError сообщает, что кэш nonce заполнен записями, срок которых ещё не истёк. Забыть такой
+// nonce нельзя, иначе запрос с ним можно было бы повторить, поэтому новые запросы отклоняются,
+// пока самая старая запись не истечёт через Retry.
+type fullError struct {
+	Retry time.Duration
+}
+
+func (e *fullError) Error() string {
+	return "too many signed requests, retry later"
+}
+
+// material — подписываемые данные запроса: метка времени, nonce, метод, путь, строка запроса и тело
+func material(timestamp, nonce, method, path, query string, body []byte) []byte {
+	data := make([]byte, 0, len(timestamp)+len(nonce)+len(method)+len(path)+len(query)+len(body)+5)
+	for _, s := range []string{timestamp, nonce, method, path, query} {
+		data = append(data, s...)
+		data = append(data, '\n')
+	}
+	return append(data, body...)
+}
+
+// SignRequest подписывает запрос с телом body: добавляет метку времени, случайный nonce
+// и подпись HMAC-SHA256 от них, метода, пути и строки запроса и тела
+func SignRequest(r *http.Request, key string, body []byte) error {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	nonce := hex.EncodeToString(buf)
+
+	// Пустой путь клиент отправляет как "/", так его и увидит сервер
+	path := r.URL.Path
+	if path == "" {
+		path = "/"
+	}
+
+	r.Header.Set(HeaderTimestamp, timestamp)
+	r.Header.Set(HeaderNonce, nonce)
+	r.Header.Set(Header, Sign(key, material(timestamp, nonce, r.Method, path, r.URL.RawQuery, body)))
+	return nil
+}
+
+// nonceEntry — использованный nonce и момент, после которого его можно забыть
+type nonceEntry struct {
+	nonce   string
+	expires time.Time
+}
+
+// nonceCache помнит использованные nonce, пока их метка времени не выйдет из допустимого окна.
+// Размер кэша ограничен: если он заполнен неистёкшими записями, новые nonce не принимаются.
+type nonceCache struct {
+	mu    sync.Mutex
+	max   int
+	seen  map[string]time.Time
+	queue []nonceEntry // в порядке добавления
+}
+
+func newNonceCache(max int) *nonceCache {
+	return &nonceCache{max: max, seen: map[string]time.Time{}}
+}
+
+// add запоминает nonce. Возвращает errReplay, если nonce уже использован, и *fullError,
+// если в кэше нет места.
+func (c *nonceCache) add(nonce string, now, expires time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if exp, ok := c.seen[nonce]; ok && now.Before(exp) {
+		return errReplay
+	}
+
+	for len(c.queue) > 0 && !now.Before(c.queue[0].expires) {
+		old := c.queue[0]
+		c.queue = c.queue[1:]
+		if c.seen[old.nonce].Equal(old.expires) {
+			delete(c.seen, old.nonce)
+		}
+	}
+	if len(c.queue) >= c.max {
+		return &fullError{Retry: c.queue[0].expires.Sub(now)}
+	}
+
+	c.seen[nonce] = expires
+	c.queue = append(c.queue, nonceEntry{nonce: nonce, expires: expires})
+	return nil
+}
+
+// checkFresh проверяет, что метка времени в пределах skew от now и nonce ещё не использован
+func (c *nonceCache) checkFresh(timestamp, nonce string, now time.Time, skew time.Duration) error {
+	sec, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errStale
+	}
+	if d := now.Sub(time.Unix(sec, 0)); d > skew || d < -skew {
+		return errStale
+	}
+	if nonce == "" || len(nonce) > maxNonceLen {
+		return errNonce
+	}
+	// Запрос с меткой до now+skew принимается до now+2*skew, столько и помним nonce
+	return c.add(nonce, now, now.Add(2*skew))
+}
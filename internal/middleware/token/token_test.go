@@ -0,0 +1,216 @@
+package token
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RomanenkoDR/metrics/internal/identity"
+	"github.com/RomanenkoDR/metrics/internal/problem"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newSignHandler(opt Options) http.Handler {
+	return CheckReqSign(func() string { return "secret" }, opt)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte("response"))
+	}))
+}
+
+func serve(h http.Handler, r *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestCheckReqSign(t *testing.T) {
+	handler := newSignHandler(Options{})
+	send := func(body, sign string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/updates/", strings.NewReader(body))
+		if sign != "" {
+			r.Header.Set(Header, sign)
+		}
+		return serve(handler, r)
+	}
+
+	w := send("[]", Sign("secret", []byte("[]")))
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Equal(t, "response", w.Body.String())
+	assert.NoError(t, VerifyResponse("secret", w.Result(), ""), "response should be signed")
+
+	// Отказ в проверке подписи тоже подписан
+	w = send("[]", Sign("other", []byte("[]")))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.NoError(t, VerifyResponse("secret", w.Result(), ""))
+	assert.Equal(t, http.StatusBadRequest, send("[]", "not hex").Code)
+
+	// Неподписанный запрос пропускается, ответ не подписывается
+	w = send("[]", "")
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Empty(t, w.Header().Get(Header))
+}
+
+func TestCheckReqSignStrict(t *testing.T) {
+	handler := newSignHandler(Options{Strict: true, Skew: time.Minute, Skip: []string{"/admin/"}})
+	signed := func(path, body string) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		require.NoError(t, SignRequest(r, "secret", []byte(body)))
+		return r
+	}
+
+	r := signed("/updates/", "[]")
+	assert.Equal(t, http.StatusAccepted, serve(handler, r).Code)
+
+	// Повтор того же запроса
+	replay := httptest.NewRequest(http.MethodPost, "/updates/", strings.NewReader("[]"))
+	replay.Header = r.Header.Clone()
+	w := serve(handler, replay)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), string(problem.CodeReplayedRequest))
+
+	// Подпись привязана к методу и строке запроса
+	r = signed("/updates/?a=1", "[]")
+	r.Method = http.MethodPut
+	assert.Contains(t, serve(handler, r).Body.String(), string(problem.CodeInvalidSignature))
+	r = signed("/updates/?a=1", "[]")
+	r.URL.RawQuery = "a=2"
+	assert.Contains(t, serve(handler, r).Body.String(), string(problem.CodeInvalidSignature))
+
+	// Подпись привязана к пути
+	moved := httptest.NewRequest(http.MethodPost, "/update/", strings.NewReader("[]"))
+	moved.Header = signed("/updates/", "[]").Header
+	assert.Contains(t, serve(handler, moved).Body.String(), string(problem.CodeInvalidSignature))
+
+	// Устаревшая метка времени
+	stale := httptest.NewRequest(http.MethodPost, "/updates/", strings.NewReader("[]"))
+	ts := strconv.FormatInt(time.Now().Add(-2*time.Minute).Unix(), 10)
+	stale.Header.Set(HeaderTimestamp, ts)
+	stale.Header.Set(HeaderNonce, "n1")
+	stale.Header.Set(Header, Sign("secret", material(ts, "n1", http.MethodPost, "/updates/", "", []byte("[]"))))
+	w = serve(handler, stale)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), string(problem.CodeStaleSignature))
+
+	// Подпись только тела не защищает от повтора и в строгом режиме не принимается
+	legacy := httptest.NewRequest(http.MethodPost, "/updates/", strings.NewReader("[]"))
+	legacy.Header.Set(Header, Sign("secret", []byte("[]")))
+	assert.Equal(t, http.StatusBadRequest, serve(handler, legacy).Code)
+
+	// Неподписанные запросы на изменение отклоняются, чтение и пути из Skip пропускаются
+	w = serve(handler, httptest.NewRequest(http.MethodPost, "/updates/", strings.NewReader("[]")))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, http.StatusAccepted, serve(handler, httptest.NewRequest(http.MethodGet, "/values", nil)).Code)
+	assert.Equal(t, http.StatusAccepted, serve(handler, httptest.NewRequest(http.MethodPost, "/admin/flush", nil)).Code)
+}
+
+func TestResponseSignature(t *testing.T) {
+	large := strings.Repeat("x", maxBufferedResponse+1)
+	var flush bool
+	handler := CheckReqSign(func() string { return "secret" }, Options{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.Query().Get("body")))
+		if flush {
+			w.(http.Flusher).Flush()
+		}
+		if r.URL.Query().Get("large") != "" {
+			w.Write([]byte(large))
+		}
+	}))
+	send := func(target string) (*http.Response, string) {
+		r := httptest.NewRequest(http.MethodPost, target, nil)
+		require.NoError(t, SignRequest(r, "secret", nil))
+		return serve(handler, r).Result(), r.Header.Get(HeaderNonce)
+	}
+
+	resp, nonce := send("/export?body=a")
+	assert.NotEmpty(t, resp.Header.Get(Header), "small response is signed in the header")
+	assert.NoError(t, VerifyResponse("secret", resp, nonce))
+
+	resp, _ = send("/export?body=a")
+	assert.Error(t, VerifyResponse("secret", resp, nonce), "signature is bound to the request nonce")
+
+	resp, nonce = send("/export?large=1")
+	assert.Empty(t, resp.Header.Get(Header))
+	assert.NotEmpty(t, resp.Trailer.Get(Header), "large response is signed in the trailer")
+	assert.NoError(t, VerifyResponse("secret", resp, nonce))
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, large, string(body))
+
+	flush = true
+	resp, nonce = send("/export?body=a")
+	assert.NotEmpty(t, resp.Trailer.Get(Header), "flushed response is signed in the trailer")
+	assert.NoError(t, VerifyResponse("secret", resp, nonce))
+
+	// Код ответа входит в подпись
+	resp, nonce = send("/export?body=a")
+	resp.StatusCode = http.StatusNoContent
+	assert.Error(t, VerifyResponse("secret", resp, nonce))
+}
+
+func TestCheckReqSignStream(t *testing.T) {
+	var signedBeforeEOF, signedAfterEOF bool
+	handler := CheckReqSign(func() string { return "secret" }, Options{Stream: []string{"/updates/"}})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		signedBeforeEOF = identity.Signed(r)
+		if _, err := io.ReadAll(r.Body); err != nil {
+			problem.WriteBodyError(w, r, problem.CodeBadRequest, err)
+			return
+		}
+		signedAfterEOF = identity.Signed(r)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	send := func(body, signedBody string) *httptest.ResponseRecorder {
+		signedBeforeEOF, signedAfterEOF = false, false
+		r := httptest.NewRequest(http.MethodPost, "/updates/", strings.NewReader(body))
+		require.NoError(t, SignRequest(r, "secret", []byte(signedBody)))
+		return serve(handler, r)
+	}
+
+	assert.Equal(t, http.StatusAccepted, send("[1]", "[1]").Code)
+	assert.False(t, signedBeforeEOF, "headers are trusted only after the body is verified")
+	assert.True(t, signedAfterEOF)
+
+	w := send("[2]", "[1]")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), string(problem.CodeInvalidSignature))
+	assert.False(t, signedAfterEOF)
+}
+
+func TestNonceCacheBounded(t *testing.T) {
+	c := newNonceCache(2)
+	now := time.Unix(1000, 0)
+	exp := now.Add(time.Minute)
+
+	assert.NoError(t, c.add("a", now, exp))
+	assert.ErrorIs(t, c.add("a", now, exp), errReplay)
+	assert.NoError(t, c.add("b", now, exp))
+
+	// Неистёкшие nonce не вытесняются, иначе их можно было бы повторить
+	var full *fullError
+	require.ErrorAs(t, c.add("c", now, exp), &full)
+	assert.Equal(t, time.Minute, full.Retry)
+	assert.ErrorIs(t, c.add("a", now, exp), errReplay)
+
+	// После истечения срока nonce забывается
+	later := exp.Add(time.Second)
+	assert.NoError(t, c.add("b", later, later.Add(time.Minute)))
+	assert.Len(t, c.seen, 1)
+}
+
+func TestCheckReqSignNoncesFull(t *testing.T) {
+	handler := newSignHandler(Options{Strict: true, Nonces: 1})
+	send := func() *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/updates/", strings.NewReader("[]"))
+		require.NoError(t, SignRequest(r, "secret", []byte("[]")))
+		return serve(handler, r)
+	}
+
+	assert.Equal(t, http.StatusAccepted, send().Code)
+	w := send()
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Contains(t, w.Body.String(), string(problem.CodeTooManyNonces))
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+}
@@ -0,0 +1,137 @@
+// Package notify доставляет уведомления о сработавших алертах во внешние каналы:
+// HTTP webhook, файл и электронную почту
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/RomanenkoDR/metrics/internal/alerts"
+)
+
+const (
+	defaultRepeatInterval = 4 * time.Hour
+	defaultAttempts       = 5
+	defaultInitialBackoff = time.Second
+	defaultMaxBackoff     = time.Minute
+	defaultTimeout        = 10 * time.Second
+
+	statusFiring   = "firing"
+	statusResolved = "resolved"
+)
+
+// Config — настройки уведомлений, секция "notifications" файла правил алертов
+type Config struct {
+	GroupBy        []string         `json:"group_by"`        // метки, по которым алерты объединяются в одно уведомление
+	RepeatInterval alerts.Duration  `json:"repeat_interval"` // как часто напоминать о продолжающих гореть алертах
+	Retry          RetryConfig      `json:"retry"`
+	Receivers      []ReceiverConfig `json:"receivers"`
+}
+
+// RetryConfig — повторные попытки доставки с экспоненциальной задержкой
+type RetryConfig struct {
+	Attempts       int             `json:"attempts"`
+	InitialBackoff alerts.Duration `json:"initial_backoff"`
+	MaxBackoff     alerts.Duration `json:"max_backoff"`
+}
+
+// ReceiverConfig описывает канал доставки. Набор полей зависит от Type: webhook, file или email.
+type ReceiverConfig struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+
+	// webhook
+	URL     string          `json:"url,omitempty"`
+	Secret  string          `json:"secret,omitempty"` // ключ подписи HMAC-SHA256 в заголовке HashSHA256
+	Timeout alerts.Duration `json:"timeout,omitempty"`
+
+	// file
+	Path string `json:"path,omitempty"`
+
+	// email
+	SMTPAddress string   `json:"smtp_address,omitempty"`
+	From        string   `json:"from,omitempty"`
+	To          []string `json:"to,omitempty"`
+	Username    string   `json:"username,omitempty"`
+	Password    string   `json:"password,omitempty"`
+}
+
+// Message — одно уведомление о группе алертов
+type Message struct {
+	Group  string         `json:"group"`
+	Status string         `json:"status"` // firing, если в группе есть горящие алерты, иначе resolved
+	Alerts []alerts.Alert `json:"alerts"`
+	SentAt time.Time      `json:"sent_at"`
+}
+
+// Notifier доставляет уведомление в один канал
+type Notifier interface {
+	Notify(ctx context.Context, msg Message) error
+}
+
+// LoadConfig читает секцию "notifications" из файла правил алертов
+func LoadConfig(path string) (Config, error) {
+	var file struct {
+		Notifications Config `json:"notifications"`
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return file.Notifications, err
+	}
+	defer f.Close()
+
+	err = json.NewDecoder(f).Decode(&file)
+	return file.Notifications, err
+}
+
+// withDefaults заполняет незаданные параметры значениями по умолчанию
+func (cfg Config) withDefaults() Config {
+	if len(cfg.GroupBy) == 0 {
+		cfg.GroupBy = []string{"alertname"}
+	}
+	if cfg.RepeatInterval <= 0 {
+		cfg.RepeatInterval = alerts.Duration(defaultRepeatInterval)
+	}
+	if cfg.Retry.Attempts <= 0 {
+		cfg.Retry.Attempts = defaultAttempts
+	}
+	if cfg.Retry.InitialBackoff <= 0 {
+		cfg.Retry.InitialBackoff = alerts.Duration(defaultInitialBackoff)
+	}
+	if cfg.Retry.MaxBackoff <= 0 {
+		cfg.Retry.MaxBackoff = alerts.Duration(defaultMaxBackoff)
+	}
+	return cfg
+}
+
+// newNotifier создаёт канал доставки по его описанию
+func newNotifier(rc ReceiverConfig) (Notifier, error) {
+	timeout := time.Duration(rc.Timeout)
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+
+	switch rc.Type {
+	case "webhook":
+		if rc.URL == "" {
+			return nil, fmt.Errorf("receiver %q: empty url", rc.Name)
+		}
+		return NewWebhook(rc.URL, rc.Secret, timeout), nil
+	case "file":
+		if rc.Path == "" {
+			return nil, fmt.Errorf("receiver %q: empty path", rc.Name)
+		}
+		return NewFile(rc.Path), nil
+	case "email":
+		if rc.SMTPAddress == "" || rc.From == "" || len(rc.To) == 0 {
+			return nil, fmt.Errorf("receiver %q: smtp_address, from and to are required", rc.Name)
+		}
+		return NewEmail(rc.SMTPAddress, rc.From, rc.To, rc.Username, rc.Password, timeout), nil
+	default:
+		return nil, fmt.Errorf("receiver %q: unknown type %q", rc.Name, rc.Type)
+	}
+}
@@ -0,0 +1,315 @@
+package notify
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/RomanenkoDR/metrics/internal/alerts"
+	"github.com/RomanenkoDR/metrics/internal/middleware/logger"
+	"go.uber.org/zap"
+)
+
+// deliveriesKept — сколько последних доставок хранится для API
+const deliveriesKept = 100
+
+// Состояния доставки
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+// Delivery — попытка доставить уведомление в один канал
+type Delivery struct {
+	ID        int       `json:"id"`
+	Receiver  string    `json:"receiver"`
+	Group     string    `json:"group"`
+	Status    string    `json:"status"` // статус уведомления: firing или resolved
+	State     string    `json:"state"`  // состояние доставки
+	Attempts  int       `json:"attempts"`
+	LastError string    `json:"last_error,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ReceiverStatus — сводка доставок по каналу
+type ReceiverStatus struct {
+	Name        string     `json:"name"`
+	Type        string     `json:"type"`
+	Delivered   int        `json:"delivered"`
+	Failed      int        `json:"failed"`
+	LastAttempt *time.Time `json:"last_attempt,omitempty"`
+	LastSuccess *time.Time `json:"last_success,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+}
+
+// Status — состояние доставки уведомлений для API
+type Status struct {
+	Receivers  []ReceiverStatus `json:"receivers"`
+	Deliveries []Delivery       `json:"deliveries"`
+}
+
+type receiver struct {
+	name     string
+	typ      string
+	notifier Notifier
+}
+
+// group — последнее отправленное состояние группы алертов
+type group struct {
+	firing   map[string]bool
+	lastSent time.Time
+}
+
+// Dispatcher группирует алерты, убирает повторы, напоминает о горящих алертах
+// и доставляет уведомления во все каналы с повторными попытками
+type Dispatcher struct {
+	cfg       Config
+	receivers []receiver
+
+	mu         sync.Mutex
+	groups     map[string]*group
+	status     map[string]*ReceiverStatus
+	deliveries []*Delivery
+	nextID     int
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	now    func() time.Time
+}
+
+// NewDispatcher создаёт диспетчер и каналы доставки из конфигурации
+func NewDispatcher(cfg Config) (*Dispatcher, error) {
+	cfg = cfg.withDefaults()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	d := &Dispatcher{
+		cfg:    cfg,
+		groups: map[string]*group{},
+		status: map[string]*ReceiverStatus{},
+		ctx:    ctx,
+		cancel: cancel,
+		now:    time.Now,
+	}
+
+	for _, rc := range cfg.Receivers {
+		n, err := newNotifier(rc)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		d.addReceiver(rc.Name, rc.Type, n)
+	}
+	return d, nil
+}
+
+// addReceiver регистрирует канал доставки
+func (d *Dispatcher) addReceiver(name, typ string, n Notifier) {
+	d.receivers = append(d.receivers, receiver{name: name, typ: typ, notifier: n})
+	d.status[name] = &ReceiverStatus{Name: name, Type: typ}
+}
+
+// Notify реализует alerts.Sink: решает, какие группы нужно уведомить, и запускает доставку
+func (d *Dispatcher) Notify(all []alerts.Alert) {
+	now := d.now()
+	repeat := time.Duration(d.cfg.RepeatInterval)
+
+	byGroup := map[string][]alerts.Alert{}
+	for _, a := range all {
+		// Заглушённые тишиной алерты не отправляются
+		if a.State == alerts.StatePending || a.Silenced() {
+			continue
+		}
+		key := d.groupKey(a)
+		byGroup[key] = append(byGroup[key], a)
+	}
+
+	d.mu.Lock()
+	var messages []Message
+	for key := range d.groups {
+		if _, ok := byGroup[key]; !ok {
+			byGroup[key] = nil
+		}
+	}
+	for key, groupAlerts := range byGroup {
+		g, ok := d.groups[key]
+		if !ok {
+			g = &group{firing: map[string]bool{}}
+		}
+
+		firing := map[string]bool{}
+		var send []alerts.Alert
+		resolved := false
+		unacked := 0
+		for _, a := range groupAlerts {
+			switch {
+			case a.State == alerts.StateFiring:
+				firing[a.Rule] = true
+				send = append(send, a)
+				if !a.Acked() {
+					unacked++
+				}
+			case g.firing[a.Rule]:
+				// Разрешение отправляется один раз — только для алертов, о которых уже сообщали
+				resolved = true
+				send = append(send, a)
+			}
+		}
+
+		changed := resolved || len(firing) != len(g.firing)
+		for fp := range firing {
+			if !g.firing[fp] {
+				changed = true
+			}
+		}
+		// Напоминания отправляются, только пока в группе есть неподтверждённые алерты
+		due := unacked > 0 && now.Sub(g.lastSent) >= repeat
+
+		if !changed && !due {
+			continue
+		}
+		if len(send) == 0 {
+			delete(d.groups, key)
+			continue
+		}
+
+		status := statusResolved
+		if len(firing) > 0 {
+			status = statusFiring
+		}
+		messages = append(messages, Message{Group: key, Status: status, Alerts: send, SentAt: now})
+
+		g.firing = firing
+		g.lastSent = now
+		if len(firing) == 0 {
+			delete(d.groups, key)
+		} else {
+			d.groups[key] = g
+		}
+	}
+	d.mu.Unlock()
+
+	for _, msg := range messages {
+		for _, r := range d.receivers {
+			d.deliver(r, msg)
+		}
+	}
+}
+
+// groupKey строит ключ группы из значений меток group_by
+func (d *Dispatcher) groupKey(a alerts.Alert) string {
+	parts := make([]string, 0, len(d.cfg.GroupBy))
+	for _, l := range d.cfg.GroupBy {
+		parts = append(parts, l+"="+a.Labels[l])
+	}
+	return strings.Join(parts, ",")
+}
+
+// deliver отправляет уведомление в канал в фоне с экспоненциальной задержкой между попытками
+func (d *Dispatcher) deliver(r receiver, msg Message) {
+	d.mu.Lock()
+	d.nextID++
+	dl := &Delivery{
+		ID:        d.nextID,
+		Receiver:  r.name,
+		Group:     msg.Group,
+		Status:    msg.Status,
+		State:     DeliveryPending,
+		CreatedAt: d.now(),
+		UpdatedAt: d.now(),
+	}
+	d.deliveries = append(d.deliveries, dl)
+	if len(d.deliveries) > deliveriesKept {
+		d.deliveries = d.deliveries[len(d.deliveries)-deliveriesKept:]
+	}
+	d.wg.Add(1)
+	d.mu.Unlock()
+
+	go func() {
+		defer d.wg.Done()
+
+		backoff := time.Duration(d.cfg.Retry.InitialBackoff)
+		for attempt := 1; ; attempt++ {
+			err := r.notifier.Notify(d.ctx, msg)
+			d.record(r.name, dl, err)
+			if err == nil {
+				return
+			}
+
+			logger.Warn("Ошибка доставки уведомления",
+				zap.String("receiver", r.name), zap.String("group", msg.Group), zap.Int("attempt", attempt), zap.Error(err))
+			if attempt >= d.cfg.Retry.Attempts {
+				d.fail(r.name, dl)
+				return
+			}
+
+			select {
+			case <-time.After(backoff):
+			case <-d.ctx.Done():
+				d.fail(r.name, dl)
+				return
+			}
+			backoff = min(backoff*2, time.Duration(d.cfg.Retry.MaxBackoff))
+		}
+	}()
+}
+
+// record сохраняет результат попытки доставки
+func (d *Dispatcher) record(name string, dl *Delivery, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := d.now()
+	st := d.status[name]
+	st.LastAttempt = &now
+	dl.Attempts++
+	dl.UpdatedAt = now
+
+	if err != nil {
+		st.LastError = err.Error()
+		dl.LastError = err.Error()
+		return
+	}
+	st.LastSuccess = &now
+	st.LastError = ""
+	st.Delivered++
+	dl.State = DeliveryDelivered
+	dl.LastError = ""
+}
+
+// fail помечает доставку неуспешной после исчерпания попыток
+func (d *Dispatcher) fail(name string, dl *Delivery) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.status[name].Failed++
+	dl.State = DeliveryFailed
+	dl.UpdatedAt = d.now()
+}
+
+// Status возвращает сводку по каналам и последние доставки, новые первыми
+func (d *Dispatcher) Status() Status {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	res := Status{Receivers: []ReceiverStatus{}, Deliveries: []Delivery{}}
+	for _, st := range d.status {
+		res.Receivers = append(res.Receivers, *st)
+	}
+	sort.Slice(res.Receivers, func(i, j int) bool { return res.Receivers[i].Name < res.Receivers[j].Name })
+
+	for i := len(d.deliveries) - 1; i >= 0; i-- {
+		res.Deliveries = append(res.Deliveries, *d.deliveries[i])
+	}
+	return res
+}
+
+// Close прерывает повторные попытки и дожидается завершения доставок
+func (d *Dispatcher) Close() {
+	d.cancel()
+	d.wg.Wait()
+}
@@ -0,0 +1,115 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// Email отправляет уведомление письмом через SMTP-сервер
+type Email struct {
+	addr     string
+	from     string
+	to       []string
+	username string
+	password string
+	timeout  time.Duration
+}
+
+// NewEmail создаёт почтовый канал. Аутентификация выполняется, только если задан username.
+// timeout ограничивает всю отправку письма: подключение и обмен с SMTP-сервером.
+func NewEmail(addr, from string, to []string, username, password string, timeout time.Duration) *Email {
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	return &Email{
+		addr:     addr,
+		from:     from,
+		to:       to,
+		username: username,
+		password: password,
+		timeout:  timeout,
+	}
+}
+
+// Notify отправляет письмо. STARTTLS используется, если сервер его поддерживает.
+func (e *Email) Notify(ctx context.Context, msg Message) error {
+	host, _, err := net.SplitHostPort(e.addr)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+	deadline, _ := ctx.Deadline()
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", e.addr)
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(deadline)
+
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if e.username != "" {
+		if err := c.Auth(smtp.PlainAuth("", e.username, e.password, host)); err != nil {
+			return err
+		}
+	}
+
+	if err := c.Mail(e.from); err != nil {
+		return err
+	}
+	for _, rcpt := range e.to {
+		if err := c.Rcpt(rcpt); err != nil {
+			return err
+		}
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(e.message(msg)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// message формирует текст письма с заголовками
+func (e *Email) message(msg Message) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", e.from)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(e.to, ", "))
+	fmt.Fprintf(&b, "Subject: [%s] %s\r\n", strings.ToUpper(msg.Status), msg.Group)
+	fmt.Fprintf(&b, "Date: %s\r\n", msg.SentAt.Format(time.RFC1123Z))
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+
+	for _, a := range msg.Alerts {
+		fmt.Fprintf(&b, "%s (%s): %s, value %v, active since %s\r\n",
+			a.Rule, a.State, a.Expr, a.Value, a.ActiveAt.Format(time.RFC3339))
+		if a.Summary != "" {
+			fmt.Fprintf(&b, "  %s\r\n", a.Summary)
+		}
+	}
+	return b.Bytes()
+}
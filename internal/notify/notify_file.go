@@ -0,0 +1,42 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+)
+
+// File дописывает уведомления в файл, по одному JSON-объекту на строку
+type File struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewFile создаёт канал записи уведомлений в файл
+func NewFile(path string) *File {
+	return &File{path: path}
+}
+
+// Notify добавляет уведомление в конец файла
+func (f *File) Notify(_ context.Context, msg Message) error {
+	line, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	file, err := os.OpenFile(f.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	_, err = file.Write(line)
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
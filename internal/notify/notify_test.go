@@ -0,0 +1,268 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/RomanenkoDR/metrics/internal/alerts"
+	"github.com/RomanenkoDR/metrics/internal/middleware/token"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testMessage() Message {
+	return Message{
+		Group:  "alertname=HighHeap",
+		Status: statusFiring,
+		Alerts: []alerts.Alert{{Rule: "HighHeap", Expr: "gauge HeapAlloc > 100", State: alerts.StateFiring, Value: 200}},
+		SentAt: time.Now(),
+	}
+}
+
+func TestWebhookSigned(t *testing.T) {
+	var got Message
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		if r.Header.Get("HashSHA256") != token.Sign("secret", body) {
+			http.Error(w, "bad sign", http.StatusBadRequest)
+			return
+		}
+		require.NoError(t, json.Unmarshal(body, &got))
+	}))
+	defer server.Close()
+
+	err := NewWebhook(server.URL, "secret", time.Second).Notify(context.Background(), testMessage())
+	require.NoError(t, err)
+	assert.Equal(t, "alertname=HighHeap", got.Group)
+
+	err = NewWebhook(server.URL, "wrong", time.Second).Notify(context.Background(), testMessage())
+	assert.Error(t, err)
+}
+
+func TestFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "alerts.ndjson")
+	f := NewFile(path)
+	require.NoError(t, f.Notify(context.Background(), testMessage()))
+	require.NoError(t, f.Notify(context.Background(), testMessage()))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, 2, strings.Count(string(data), "\n"))
+}
+
+// fakeSMTP — минимальный SMTP-сервер, запоминающий полученные письма
+func fakeSMTP(t *testing.T) (string, <-chan string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+
+	mails := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		r := bufio.NewReader(conn)
+		reply := func(s string) { io.WriteString(conn, s+"\r\n") }
+		reply("220 localhost ESMTP")
+
+		var data strings.Builder
+		inData := false
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			if inData {
+				if line == ".\r\n" {
+					inData = false
+					mails <- data.String()
+					reply("250 OK")
+					continue
+				}
+				data.WriteString(line)
+				continue
+			}
+
+			switch cmd := strings.ToUpper(strings.TrimSpace(line)); {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				reply("250 localhost")
+			case cmd == "DATA":
+				inData = true
+				reply("354 go ahead")
+			case cmd == "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("250 OK")
+			}
+		}
+	}()
+	return ln.Addr().String(), mails
+}
+
+func TestEmail(t *testing.T) {
+	addr, mails := fakeSMTP(t)
+
+	err := NewEmail(addr, "metrics@example.com", []string{"ops@example.com"}, "", "", 0).Notify(context.Background(), testMessage())
+	require.NoError(t, err)
+
+	select {
+	case mail := <-mails:
+		assert.Contains(t, mail, "Subject: [FIRING] alertname=HighHeap")
+		assert.Contains(t, mail, "HighHeap (firing)")
+	case <-time.After(time.Second):
+		t.Fatal("mail was not received")
+	}
+}
+
+func TestEmailTimeout(t *testing.T) {
+	// Сервер принимает соединение, но не отвечает приветствием
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+	done := make(chan struct{})
+	t.Cleanup(func() { close(done) })
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		<-done
+		conn.Close()
+	}()
+
+	start := time.Now()
+	err = NewEmail(ln.Addr().String(), "metrics@example.com", []string{"ops@example.com"}, "", "", 100*time.Millisecond).
+		Notify(context.Background(), testMessage())
+	require.Error(t, err)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+// recorder — канал доставки для тестов, может падать заданное число раз
+type recorder struct {
+	mu       sync.Mutex
+	messages []Message
+	failures int
+}
+
+func (r *recorder) Notify(_ context.Context, msg Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.failures > 0 {
+		r.failures--
+		return errors.New("temporary failure")
+	}
+	r.messages = append(r.messages, msg)
+	return nil
+}
+
+func (r *recorder) sent() []Message {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Message(nil), r.messages...)
+}
+
+func newTestDispatcher(t *testing.T, rec *recorder) (*Dispatcher, *time.Time) {
+	t.Helper()
+	d, err := NewDispatcher(Config{
+		RepeatInterval: alerts.Duration(time.Hour),
+		Retry:          RetryConfig{Attempts: 3, InitialBackoff: alerts.Duration(time.Millisecond)},
+	})
+	require.NoError(t, err)
+	t.Cleanup(d.Close)
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	d.now = func() time.Time { return now }
+	d.addReceiver("test", "recorder", rec)
+	return d, &now
+}
+
+func firing(rule string) alerts.Alert {
+	return alerts.Alert{Rule: rule, State: alerts.StateFiring, Labels: map[string]string{"alertname": rule}}
+}
+
+func TestDispatcherDedupAndRepeat(t *testing.T) {
+	rec := &recorder{}
+	d, now := newTestDispatcher(t, rec)
+
+	d.Notify([]alerts.Alert{firing("HighHeap")})
+	d.Notify([]alerts.Alert{firing("HighHeap")})
+	d.wg.Wait()
+	require.Len(t, rec.sent(), 1, "unchanged group is not sent twice")
+
+	*now = now.Add(time.Hour)
+	d.Notify([]alerts.Alert{firing("HighHeap")})
+	d.wg.Wait()
+	require.Len(t, rec.sent(), 2, "repeat after interval")
+
+	resolved := firing("HighHeap")
+	resolved.State = alerts.StateResolved
+	d.Notify([]alerts.Alert{resolved})
+	d.Notify([]alerts.Alert{resolved})
+	d.wg.Wait()
+	sent := rec.sent()
+	require.Len(t, sent, 3, "resolution is sent once")
+	assert.Equal(t, statusResolved, sent[2].Status)
+}
+
+func TestDispatcherRetry(t *testing.T) {
+	rec := &recorder{failures: 2}
+	d, _ := newTestDispatcher(t, rec)
+
+	d.Notify([]alerts.Alert{firing("HighHeap")})
+	d.wg.Wait()
+
+	require.Len(t, rec.sent(), 1)
+	status := d.Status()
+	require.Len(t, status.Deliveries, 1)
+	assert.Equal(t, DeliveryDelivered, status.Deliveries[0].State)
+	assert.Equal(t, 3, status.Deliveries[0].Attempts)
+	assert.Equal(t, 1, status.Receivers[0].Delivered)
+
+	rec.failures = 5
+	d.Notify([]alerts.Alert{firing("HighHeap"), firing("LowDisk")})
+	d.wg.Wait()
+	status = d.Status()
+	assert.Equal(t, DeliveryFailed, status.Deliveries[0].State)
+	assert.Equal(t, 1, status.Receivers[0].Failed)
+}
+
+func TestDispatcherSilencedAndAcked(t *testing.T) {
+	rec := &recorder{}
+	d, now := newTestDispatcher(t, rec)
+
+	silenced := firing("Silenced")
+	silenced.SilencedBy = []string{"s1"}
+	d.Notify([]alerts.Alert{silenced})
+	d.wg.Wait()
+	assert.Empty(t, rec.sent(), "silenced alerts are not sent")
+
+	d.Notify([]alerts.Alert{firing("HighHeap")})
+	d.wg.Wait()
+	require.Len(t, rec.sent(), 1)
+
+	acked := firing("HighHeap")
+	ackedAt := *now
+	acked.AckedAt = &ackedAt
+	*now = now.Add(2 * time.Hour)
+	d.Notify([]alerts.Alert{acked})
+	d.wg.Wait()
+	assert.Len(t, rec.sent(), 1, "acked alerts are not repeated")
+}
@@ -0,0 +1,97 @@
+// Package openapi встраивает в бинарный файл описание API в формате OpenAPI 3
+// и проверяет тела запросов по схемам из него
+package openapi
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+//go:embed openapi.json
+var spec []byte
+
+// document — части спецификации, нужные для проверки запросов
+type document struct {
+	Paths      map[string]pathItem `json:"paths"`
+	Components struct {
+		Schemas map[string]*Schema `json:"schemas"`
+	} `json:"components"`
+}
+
+// pathItem — операции пути по методам. Общие поля пути, например servers, пропускаются.
+type pathItem map[string]operation
+
+// methods — ключи описания пути, которые задают операции
+var methods = map[string]bool{
+	"get": true, "put": true, "post": true, "delete": true,
+	"options": true, "head": true, "patch": true, "trace": true,
+}
+
+func (p *pathItem) UnmarshalJSON(data []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*p = pathItem{}
+	for key, value := range raw {
+		if !methods[key] {
+			continue
+		}
+		var op operation
+		if err := json.Unmarshal(value, &op); err != nil {
+			return err
+		}
+		(*p)[key] = op
+	}
+	return nil
+}
+
+type operation struct {
+	RequestBody *struct {
+		Required bool `json:"required"`
+		Content  map[string]struct {
+			Schema *Schema `json:"schema"`
+		} `json:"content"`
+	} `json:"requestBody"`
+}
+
+// Spec возвращает встроенную спецификацию
+func Spec() []byte {
+	return spec
+}
+
+// Handler отдаёт встроенную спецификацию
+func Handler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(spec)
+}
+
+// RequestSchema возвращает схему JSON-тела запроса операции с разрешёнными ссылками $ref
+// и признак того, что тело обязательно
+func RequestSchema(method, path string) (*Schema, bool, error) {
+	var doc document
+	if err := json.Unmarshal(spec, &doc); err != nil {
+		return nil, false, err
+	}
+
+	op, ok := doc.Paths[path][strings.ToLower(method)]
+	if !ok {
+		return nil, false, fmt.Errorf("openapi: operation %s %s not found", method, path)
+	}
+	if op.RequestBody == nil {
+		return nil, false, fmt.Errorf("openapi: operation %s %s has no request body", method, path)
+	}
+	content, ok := op.RequestBody.Content["application/json"]
+	if !ok || content.Schema == nil {
+		return nil, false, fmt.Errorf("openapi: operation %s %s has no JSON schema", method, path)
+	}
+
+	if err := content.Schema.resolve(doc.Components.Schemas, 0); err != nil {
+		return nil, false, err
+	}
+	return content.Schema, op.RequestBody.Required, nil
+}
@@ -0,0 +1,126 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/RomanenkoDR/metrics/internal/problem"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpecSchemasResolve(t *testing.T) {
+	var doc document
+	require.NoError(t, json.Unmarshal(Spec(), &doc))
+
+	for path, ops := range doc.Paths {
+		for method, op := range ops {
+			if op.RequestBody == nil {
+				continue
+			}
+			_, _, err := RequestSchema(method, path)
+			assert.NoError(t, err, "%s %s", method, path)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		body    string
+		wantErr string
+	}{
+		{"Counter", "/update/", `{"id":"PollCount","type":"counter","delta":5}`, ""},
+		{"Gauge", "/update/", `{"id":"Alloc","type":"gauge","value":1.5}`, ""},
+		{"Wrong type", "/update/", `{"id":"Alloc","type":"histogram","value":1}`, "body.type: should be one of counter, gauge"},
+		{"Empty id", "/update/", `{"id":"","type":"gauge","value":1}`, "body.id: should be at least 1 characters long"},
+		{"Missing id", "/update/", `{"type":"gauge","value":1}`, "body.id: is required"},
+		{"Fractional delta", "/update/", `{"id":"PollCount","type":"counter","delta":1.5}`, "body.delta: should be an integer"},
+		{"String value", "/update/", `{"id":"Alloc","type":"gauge","value":"1"}`, "body.value: should be a number"},
+		{"Batch", "/updates/", `[{"id":"Alloc","type":"gauge","value":1},{"id":"PollCount","type":"counter","delta":1}]`, ""},
+		{"Batch item", "/updates/", `[{"id":"Alloc","type":"gauge","value":1},{"id":"PollCount"}]`, "body[1].type: is required"},
+		{"Batch not array", "/updates/", `{"id":"Alloc","type":"gauge","value":1}`, "body: should be an array"},
+		{"Value", "/value/", `{"id":"Alloc","type":"gauge"}`, ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			s, _, err := RequestSchema(http.MethodPost, tc.path)
+			require.NoError(t, err)
+
+			err = s.Validate([]byte(tc.body))
+			if tc.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, tc.wantErr)
+		})
+	}
+}
+
+func TestValidator(t *testing.T) {
+	mw, err := Validator(http.MethodPost, "/update/")
+	require.NoError(t, err)
+
+	reached := false
+	handler := mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	send := func(body string) *httptest.ResponseRecorder {
+		reached = false
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/update/", strings.NewReader(body)))
+		return w
+	}
+
+	w := send(`{"id":"Alloc","type":"gauge","value":1}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, reached)
+
+	for body, code := range map[string]problem.Code{
+		`{"id":"Alloc","type":"histogram"}`: problem.CodeSchemaViolation,
+		`{"id":`:                            problem.CodeInvalidJSON,
+		``:                                  problem.CodeSchemaViolation,
+	} {
+		w = send(body)
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+		assert.False(t, reached, body)
+
+		var p problem.Problem
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &p))
+		assert.Equal(t, code, p.Code, body)
+	}
+
+	_, err = Validator(http.MethodPost, "/nosuch/")
+	assert.Error(t, err)
+}
+
+func TestItemValidator(t *testing.T) {
+	mw, err := ItemValidator(http.MethodPost, "/updates/")
+	require.NoError(t, err)
+
+	var errs []error
+	handler := mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		errs = []error{
+			ValidateItem(r, 0, []byte(`{"id":"Alloc","type":"gauge","value":1}`)),
+			ValidateItem(r, 1, []byte(`{"id":"PollCount"}`)),
+		}
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/updates/", nil))
+
+	require.Len(t, errs, 2)
+	assert.NoError(t, errs[0])
+	assert.EqualError(t, errs[1], "body[1].type: is required")
+
+	// Без ItemValidator элементы не проверяются
+	assert.NoError(t, ValidateItem(httptest.NewRequest(http.MethodPost, "/updates/", nil), 0, []byte(`{}`)))
+
+	_, err = ItemValidator(http.MethodPost, "/update/")
+	assert.Error(t, err, "body is not an array")
+}
@@ -0,0 +1,289 @@
+package openapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/RomanenkoDR/metrics/internal/problem"
+)
+
+// maxRefDepth ограничивает вложенность ссылок $ref, чтобы не зациклиться
+const maxRefDepth = 32
+
+// Schema — подмножество JSON Schema из OpenAPI 3, которое используется в спецификации:
+// type, enum, required, properties, additionalProperties, items, minLength, minItems, minimum, maximum
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties json.RawMessage    `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+
+	additional *Schema // схема дополнительных свойств объекта
+	closed     bool    // дополнительные свойства запрещены
+}
+
+// ValidationError — несоответствие значения схеме
+type ValidationError struct {
+	Path    string // путь к значению, например body[0].type
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Path + ": " + e.Message
+}
+
+// resolve подставляет схемы по ссылкам $ref из components.schemas
+func (s *Schema) resolve(components map[string]*Schema, depth int) error {
+	if s == nil {
+		return nil
+	}
+	if depth > maxRefDepth {
+		return errors.New("openapi: too deep $ref nesting")
+	}
+
+	if s.Ref != "" {
+		name := strings.TrimPrefix(s.Ref, "#/components/schemas/")
+		target, ok := components[name]
+		if !ok {
+			return fmt.Errorf("openapi: unknown schema %q", s.Ref)
+		}
+		if err := target.resolve(components, depth+1); err != nil {
+			return err
+		}
+		*s = *target
+		return nil
+	}
+
+	switch raw := bytes.TrimSpace(s.AdditionalProperties); {
+	case len(raw) == 0, bytes.Equal(raw, []byte("true")):
+	case bytes.Equal(raw, []byte("false")):
+		s.closed = true
+	default:
+		s.additional = &Schema{}
+		if err := json.Unmarshal(raw, s.additional); err != nil {
+			return fmt.Errorf("openapi: incorrect additionalProperties: %w", err)
+		}
+		if err := s.additional.resolve(components, depth+1); err != nil {
+			return err
+		}
+	}
+
+	for _, p := range s.Properties {
+		if err := p.resolve(components, depth+1); err != nil {
+			return err
+		}
+	}
+	return s.Items.resolve(components, depth+1)
+}
+
+// Validate разбирает JSON и проверяет его по схеме
+func (s *Schema) Validate(data []byte) error {
+	return s.validateJSON(data, "body")
+}
+
+// validateJSON разбирает JSON и проверяет его по схеме; path — путь к значению в ошибках
+func (s *Schema) validateJSON(data []byte, path string) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var v interface{}
+	if err := decoder.Decode(&v); err != nil {
+		return err
+	}
+	if decoder.More() {
+		return errors.New("unexpected data after JSON value")
+	}
+	return s.validate(v, path)
+}
+
+// validate проверяет разобранное значение
+func (s *Schema) validate(v interface{}, path string) error {
+	fail := func(format string, args ...interface{}) error {
+		return &ValidationError{Path: path, Message: fmt.Sprintf(format, args...)}
+	}
+
+	if len(s.Enum) > 0 && !inEnum(s.Enum, v) {
+		return fail("should be one of %s", enumString(s.Enum))
+	}
+
+	switch s.Type {
+	case "":
+		return nil
+	case "object":
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return fail("should be an object")
+		}
+		for _, name := range s.Required {
+			if _, ok := obj[name]; !ok {
+				return &ValidationError{Path: path + "." + name, Message: "is required"}
+			}
+		}
+		for name, value := range obj {
+			prop, ok := s.Properties[name]
+			switch {
+			case ok:
+			case s.additional != nil:
+				prop = s.additional
+			case s.closed:
+				return &ValidationError{Path: path + "." + name, Message: "unknown property"}
+			default:
+				continue
+			}
+			if err := prop.validate(value, path+"."+name); err != nil {
+				return err
+			}
+		}
+	case "array":
+		arr, ok := v.([]interface{})
+		if !ok {
+			return fail("should be an array")
+		}
+		if s.MinItems != nil && len(arr) < *s.MinItems {
+			return fail("should contain at least %d items", *s.MinItems)
+		}
+		if s.Items != nil {
+			for i, item := range arr {
+				if err := s.Items.validate(item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			return fail("should be a string")
+		}
+		if s.MinLength != nil && utf8.RuneCountInString(str) < *s.MinLength {
+			return fail("should be at least %d characters long", *s.MinLength)
+		}
+	case "integer", "number":
+		n, ok := v.(json.Number)
+		if !ok {
+			return fail("should be a %s", s.Type)
+		}
+		if s.Type == "integer" {
+			if _, err := n.Int64(); err != nil {
+				return fail("should be an integer")
+			}
+		}
+		f, err := n.Float64()
+		if err != nil {
+			return fail("should be a number")
+		}
+		if s.Minimum != nil && f < *s.Minimum {
+			return fail("should be at least %v", *s.Minimum)
+		}
+		if s.Maximum != nil && f > *s.Maximum {
+			return fail("should be at most %v", *s.Maximum)
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return fail("should be a boolean")
+		}
+	}
+	return nil
+}
+
+// inEnum проверяет, что значение входит в перечисление
+func inEnum(enum []interface{}, v interface{}) bool {
+	for _, e := range enum {
+		if fmt.Sprint(e) == fmt.Sprint(v) {
+			return true
+		}
+	}
+	return false
+}
+
+func enumString(enum []interface{}) string {
+	parts := make([]string, len(enum))
+	for i, e := range enum {
+		parts[i] = fmt.Sprint(e)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Validator возвращает middleware, которое проверяет тело запроса по схеме операции
+// из спецификации и отвечает 400 с кодом schema_violation, если тело ей не соответствует
+func Validator(method, path string) (func(http.Handler) http.Handler, error) {
+	schema, required, err := RequestSchema(method, path)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				problem.WriteBodyError(w, r, problem.CodeBadRequest, err)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			if len(bytes.TrimSpace(body)) == 0 {
+				if required {
+					problem.Write(w, r, http.StatusBadRequest, problem.CodeSchemaViolation, "request body is required")
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if err := schema.Validate(body); err != nil {
+				var verr *ValidationError
+				if errors.As(err, &verr) {
+					problem.Write(w, r, http.StatusBadRequest, problem.CodeSchemaViolation, verr.Error())
+					return
+				}
+				problem.WriteBodyError(w, r, problem.CodeInvalidJSON, err)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}, nil
+}
+
+type itemsKey struct{}
+
+// ItemValidator возвращает middleware для операций, тело которых — массив. В отличие от
+// Validator тело не читается заранее: схема элементов передаётся обработчику через контекст,
+// и он проверяет каждый элемент функцией ValidateItem по мере разбора.
+func ItemValidator(method, path string) (func(http.Handler) http.Handler, error) {
+	schema, _, err := RequestSchema(method, path)
+	if err != nil {
+		return nil, err
+	}
+	if schema.Type != "array" || schema.Items == nil {
+		return nil, fmt.Errorf("openapi: request body of %s %s is not an array", method, path)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), itemsKey{}, schema.Items)))
+		})
+	}, nil
+}
+
+// ValidateItem проверяет элемент массива с индексом n из тела запроса по схеме, которую
+// передал ItemValidator. Без ItemValidator элементы не проверяются.
+func ValidateItem(r *http.Request, n int, data []byte) error {
+	items, ok := r.Context().Value(itemsKey{}).(*Schema)
+	if !ok {
+		return nil
+	}
+	return items.validateJSON(data, fmt.Sprintf("body[%d]", n))
+}
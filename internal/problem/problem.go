@@ -0,0 +1,169 @@
+// Package problem описывает ответы об ошибках в формате RFC 7807 (application/problem+json).
+// Поле code содержит стабильный машиночитаемый код ошибки, по которому клиенты могут
+// различать причины отказа, не разбирая текст сообщения.
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ContentType — тип содержимого ответа об ошибке
+const ContentType = "application/problem+json"
+
+// typePrefix — префикс URI типа ошибки, к нему добавляется код
+const typePrefix = "urn:metrics:problem:"
+
+// Code — машиночитаемый код ошибки
+type Code string
+
+// Коды ошибок. Значения входят в API и не должны меняться.
+const (
+	CodeBadRequest         Code = "bad_request"          // некорректный запрос без более точной причины
+	CodeInvalidJSON        Code = "invalid_json"         // тело запроса не разбирается как JSON
+	CodeSchemaViolation    Code = "schema_violation"     // тело запроса не соответствует схеме OpenAPI
+	CodeInvalidMetricType  Code = "invalid_metric_type"  // тип метрики не counter и не gauge
+	CodeInvalidMetricValue Code = "invalid_metric_value" // значение метрики не разбирается
+	CodeMissingValue       Code = "missing_metric_value" // не передано значение метрики
+	CodeMetricNotFound     Code = "metric_not_found"     // метрика не найдена
+	CodeReservedName       Code = "reserved_metric_name" // имя метрики начинается с зарезервированного префикса
+	CodeInvalidQuery       Code = "invalid_query"        // некорректные параметры выборки
+	CodeInvalidImport      Code = "invalid_import"       // загружаемые данные не прошли проверку
+	CodeInvalidSignature   Code = "invalid_signature"    // подпись HashSHA256 не совпала или повреждена
+	CodeStaleSignature     Code = "stale_signature"      // метка времени подписи вне допустимого окна
+	CodeReplayedRequest    Code = "replayed_request"     // nonce подписи уже использован
+	CodeTooManyNonces      Code = "too_many_nonces"      // кэш nonce заполнен, запрос нужно повторить позже
+	CodeDecryptionFailed   Code = "decryption_failed"    // не удалось расшифровать тело запроса
+	CodeUnauthorized       Code = "unauthorized"         // не передан или не подошёл токен доступа
+	CodeInsufficientScope  Code = "insufficient_scope"   // токену не выдана нужная область доступа
+	CodeRateLimited        Code = "rate_limited"         // клиент превысил ограничение частоты запросов
+	CodeUntrustedNetwork   Code = "untrusted_network"    // адрес клиента не входит в доверенные подсети
+	CodeInvalidEncoding    Code = "invalid_encoding"     // тело запроса не распаковывается
+	CodePayloadTooLarge    Code = "payload_too_large"    // тело запроса больше допустимого
+	CodeNotEnoughData      Code = "not_enough_data"      // недостаточно истории для расчёта
+	CodeAlertingDisabled   Code = "alerting_disabled"    // алерты не настроены
+	CodeInvalidSilence     Code = "invalid_silence"      // некорректная тишина
+	CodeSilenceNotFound    Code = "silence_not_found"    // тишина не найдена
+	CodeAlertNotFound      Code = "alert_not_found"      // алерт не найден
+	CodeAlertNotFiring     Code = "alert_not_firing"     // алерт ещё не сработал
+	CodeStorageUnavailable Code = "storage_unavailable"  // хранилище недоступно
+	CodeKeyReloadFailed    Code = "key_reload_failed"    // не удалось перечитать ключи
+	CodeAuditDisabled      Code = "audit_disabled"       // журнал аудита не настроен
+	CodeAuditUnavailable   Code = "audit_unavailable"    // запись в журнал аудита не удалась, изменения не принимаются
+	CodeInternal           Code = "internal_error"       // внутренняя ошибка сервера
+)
+
+// Problem — описание ошибки по RFC 7807
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	Code     Code   `json:"code"`
+
+	// RetryAfter — через сколько сервер предлагает повторить запрос (заголовок Retry-After)
+	RetryAfter time.Duration `json:"-"`
+}
+
+// New создаёт описание ошибки с заголовком по статусу ответа
+func New(status int, code Code, detail string) *Problem {
+	return &Problem{
+		Type:   typePrefix + string(code),
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+// Error позволяет возвращать описание ошибки как error
+func (p *Problem) Error() string {
+	if p.Detail == "" {
+		return fmt.Sprintf("%d %s (%s)", p.Status, p.Title, p.Code)
+	}
+	return fmt.Sprintf("%d %s (%s): %s", p.Status, p.Title, p.Code, p.Detail)
+}
+
+// Write отправляет описание ошибки клиенту. Instance заполняется путём запроса.
+func Write(w http.ResponseWriter, r *http.Request, status int, code Code, detail string) {
+	p := New(status, code, detail)
+	if r != nil {
+		p.Instance = r.URL.Path
+	}
+	p.Write(w)
+}
+
+// WriteBodyError отвечает на ошибку чтения или разбора тела запроса: 413, если тело больше
+// допустимого размера (ошибка http.MaxBytesError), описанием ошибки, если это *Problem,
+// иначе 400 с кодом code
+func WriteBodyError(w http.ResponseWriter, r *http.Request, code Code, err error) {
+	// Ошибку с готовым описанием возвращает проверка тела при чтении, например подписи
+	var p *Problem
+	if errors.As(err, &p) {
+		p.Instance = r.URL.Path
+		p.Write(w)
+		return
+	}
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		Write(w, r, http.StatusRequestEntityTooLarge, CodePayloadTooLarge, fmt.Sprintf("request body is larger than %d bytes", tooLarge.Limit))
+		return
+	}
+	Write(w, r, http.StatusBadRequest, code, err.Error())
+}
+
+// Write отправляет описание ошибки клиенту
+func (p *Problem) Write(w http.ResponseWriter) {
+	body, _ := json.Marshal(p)
+
+	h := w.Header()
+	h.Del("Content-Length")
+	h.Set("Content-Type", ContentType)
+	h.Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+	w.Write(body)
+}
+
+// Parse читает описание ошибки из ответа сервера. Если ответ не в формате problem+json,
+// возвращается описание с кодом по статусу и телом ответа в Detail.
+func Parse(resp *http.Response) *Problem {
+	body, _ := io.ReadAll(resp.Body)
+
+	var p *Problem
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), ContentType) {
+		if err := json.Unmarshal(body, &p); err != nil || p == nil || p.Code == "" {
+			p = nil
+		}
+	}
+
+	if p == nil {
+		code := CodeBadRequest
+		if resp.StatusCode >= http.StatusInternalServerError {
+			code = CodeInternal
+		}
+		p = New(resp.StatusCode, code, strings.TrimSpace(string(body)))
+	}
+	p.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"))
+	return p
+}
+
+// parseRetryAfter разбирает Retry-After: число секунд или дату HTTP
+func parseRetryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(v); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(time.Until(t), 0)
+	}
+	return 0
+}
@@ -0,0 +1,64 @@
+package problem
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrite(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/value/gauge/Unknown", nil)
+	Write(w, r, http.StatusNotFound, CodeMetricNotFound, "metric Unknown not found")
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, ContentType, w.Header().Get("Content-Type"))
+
+	var p Problem
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &p))
+	assert.Equal(t, Problem{
+		Type:     "urn:metrics:problem:metric_not_found",
+		Title:    "Not Found",
+		Status:   http.StatusNotFound,
+		Detail:   "metric Unknown not found",
+		Instance: "/value/gauge/Unknown",
+		Code:     CodeMetricNotFound,
+	}, p)
+}
+
+func TestParse(t *testing.T) {
+	w := httptest.NewRecorder()
+	Write(w, nil, http.StatusBadRequest, CodeInvalidSignature, "request signature doesn't match")
+
+	p := Parse(w.Result())
+	assert.Equal(t, CodeInvalidSignature, p.Code)
+	assert.Equal(t, http.StatusBadRequest, p.Status)
+	assert.Contains(t, p.Error(), "invalid_signature")
+
+	// Ответ не в формате problem+json, например от прокси
+	w = httptest.NewRecorder()
+	http.Error(w, "bad gateway", http.StatusBadGateway)
+	p = Parse(w.Result())
+	assert.Equal(t, CodeInternal, p.Code)
+	assert.Equal(t, "bad gateway", p.Detail)
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	w := httptest.NewRecorder()
+	w.Header().Set("Retry-After", "3")
+	Write(w, nil, http.StatusTooManyRequests, CodeRateLimited, "rate limit exceeded")
+
+	p := Parse(w.Result())
+	assert.Equal(t, CodeRateLimited, p.Code)
+	assert.Equal(t, 3*time.Second, p.RetryAfter)
+
+	w = httptest.NewRecorder()
+	w.Header().Set("Retry-After", "soon")
+	http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	assert.Zero(t, Parse(w.Result()).RetryAfter)
+}
@@ -1,16 +1,96 @@
 package routers
 
 import (
+	"net/http"
+
 	"github.com/RomanenkoDR/metrics/internal/config/server/types"
 	"github.com/RomanenkoDR/metrics/internal/handlers"
+	"github.com/RomanenkoDR/metrics/internal/jwt"
+	"github.com/RomanenkoDR/metrics/internal/middleware/auth"
+	"github.com/RomanenkoDR/metrics/internal/middleware/logger"
+	"github.com/RomanenkoDR/metrics/internal/middleware/ratelimit"
+	"github.com/RomanenkoDR/metrics/internal/middleware/subnet"
 	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
 )
 
 func InitRouter(cfg types.Options, h handlers.Handler) (chi.Router, error) {
 	router := chi.NewRouter()
 
-	setupMiddleware(router, cfg)
-	setupRoutes(router, h)
+	var limiter *ratelimit.Limiter
+	if cfg.RateLimits != "" {
+		limits, err := ratelimit.LoadConfig(cfg.RateLimits)
+		if err != nil {
+			return nil, err
+		}
+		limiter = ratelimit.New(limits)
+		logger.Info("Ограничение частоты запросов включено", zap.String("file", cfg.RateLimits), zap.Int("routes", len(limits.Routes)))
+	}
+
+	if err := setupMiddleware(router, cfg, h, limiter); err != nil {
+		return nil, err
+	}
+
+	// Зашифрованные тела запросов расшифровываются в обработчиках, поэтому проверить их схему
+	// до обработчика нельзя
+	validate := cfg.CryptoKey == ""
+	if !validate {
+		logger.Info("Проверка запросов по схеме OpenAPI отключена: включено шифрование")
+	}
+
+	// Запись метрик принимается только из доверенных подсетей, если они заданы
+	trusted := func(next http.Handler) http.Handler { return next }
+	if cfg.TrustedSubnet != "" {
+		nets, err := subnet.Parse(cfg.TrustedSubnet)
+		if err != nil {
+			return nil, err
+		}
+		trusted = subnet.Handler(nets, cfg.TrustedRemote)
+		logger.Info("Запись метрик разрешена только из доверенных подсетей", zap.String("subnets", cfg.TrustedSubnet), zap.Bool("remote", cfg.TrustedRemote))
+	}
+
+	authz, err := newAuthorizer(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := setupRoutes(router, h, validate, authz, trusted); err != nil {
+		return nil, err
+	}
+
+	// Административные действия доступны с токеном JWT с областью admin или с отдельным токеном -admin-key
+	if cfg.AdminKey != "" || authz.Enabled() {
+		router.Route("/admin", func(r chi.Router) {
+			r.Use(h.Audit.Middleware, authz.Require(auth.ScopeAdmin, cfg.AdminKey))
+			setupAdminRoutes(r, h)
+		})
+	} else {
+		logger.Info("Административный API отключён: не заданы -admin-key и ключ токенов JWT")
+	}
 
 	return router, nil
 }
+
+// newAuthorizer настраивает проверку токенов JWT. Если не задан ни секрет HS256, ни публичный
+// ключ RS256, токены не проверяются и возвращается nil.
+func newAuthorizer(cfg types.Options) (*auth.Authorizer, error) {
+	if cfg.JWTSecret == "" && cfg.JWTPublicKey == "" {
+		return nil, nil
+	}
+
+	verifier := &jwt.Verifier{Secret: []byte(cfg.JWTSecret), Issuer: cfg.JWTIssuer, Audience: cfg.JWTAudience}
+	if cfg.JWTSecret != "" {
+		if err := jwt.CheckSecret(verifier.Secret); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.JWTPublicKey != "" {
+		key, err := jwt.LoadPublicKey(cfg.JWTPublicKey)
+		if err != nil {
+			return nil, err
+		}
+		verifier.PublicKey = key
+	}
+	logger.Info("Доступ к API по токенам JWT включён", zap.Bool("hs256", cfg.JWTSecret != ""), zap.Bool("rs256", verifier.PublicKey != nil))
+	return auth.New(verifier), nil
+}
@@ -0,0 +1,282 @@
+package routers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RomanenkoDR/metrics/internal/audit"
+	"github.com/RomanenkoDR/metrics/internal/config/server/types"
+	"github.com/RomanenkoDR/metrics/internal/handlers"
+	"github.com/RomanenkoDR/metrics/internal/jwt"
+	"github.com/RomanenkoDR/metrics/internal/openapi"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIVersioning(t *testing.T) {
+	h := handlers.NewHandler()
+	router, err := InitRouter(types.Options{}, h)
+	require.NoError(t, err)
+
+	send := func(method, target, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return w
+	}
+
+	w := send(http.MethodGet, "/api/v1/openapi.json", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"openapi": "3.0.3"`)
+
+	// Новый и прежний пути ведут к одному обработчику
+	assert.Equal(t, http.StatusOK, send(http.MethodPost, "/api/v1/update/", `{"id":"Alloc","type":"gauge","value":1}`).Code)
+	assert.Equal(t, http.StatusOK, send(http.MethodPost, "/update/", `{"id":"Alloc","type":"gauge","value":2}`).Code)
+	v, ok := h.Store.GetGauge("Alloc")
+	require.True(t, ok)
+	assert.Equal(t, 2.0, float64(v))
+
+	w = send(http.MethodPost, "/api/v1/updates/", `[{"id":"Alloc","type":"histogram"}]`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "schema_violation")
+}
+
+func TestAdminAPI(t *testing.T) {
+	h := handlers.NewHandler()
+	h.Store.UpdateCounter("PollCount", 5)
+	h.Store.UpdateCounter("Requests", 3)
+	h.Store.UpdateGauge("cpu_load;host=a", 0.5)
+	h.Store.UpdateGauge("HeapAlloc", 1)
+
+	router, err := InitRouter(types.Options{AdminKey: "secret"}, h)
+	require.NoError(t, err)
+
+	send := func(method, target, auth, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, target, strings.NewReader(body))
+		if auth != "" {
+			r.Header.Set("Authorization", "Bearer "+auth)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return w
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, send(http.MethodPost, "/admin/counters/reset", "", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, send(http.MethodPost, "/admin/counters/reset", "agent-key", "").Code)
+
+	w := send(http.MethodPost, "/admin/counters/reset?name=PollCount", "secret", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	v, _ := h.Store.GetCounter("PollCount")
+	assert.Equal(t, 0, int(v))
+	v, _ = h.Store.GetCounter("Requests")
+	assert.Equal(t, 3, int(v))
+	assert.Equal(t, http.StatusNotFound, send(http.MethodPost, "/admin/counters/reset?name=Unknown", "secret", "").Code)
+
+	assert.Equal(t, http.StatusBadRequest, send(http.MethodPost, "/admin/metrics/drop", "secret", "").Code)
+	w = send(http.MethodPost, "/admin/metrics/drop?pattern=cpu_*&type=gauge", "secret", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.JSONEq(t, `{"dropped":["cpu_load;host=a"]}`, w.Body.String())
+	_, ok := h.Store.GetGauge("HeapAlloc")
+	assert.True(t, ok)
+
+	w = send(http.MethodPut, "/admin/log-level", "secret", `{"level":"warn"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.JSONEq(t, `{"level":"warn"}`, send(http.MethodGet, "/admin/log-level", "secret", "").Body.String())
+	assert.Equal(t, http.StatusBadRequest, send(http.MethodPut, "/admin/log-level", "secret", `{"level":"fatal"}`).Code)
+	send(http.MethodPut, "/admin/log-level", "secret", `{"level":"debug"}`)
+
+	assert.Equal(t, http.StatusServiceUnavailable, send(http.MethodPost, "/admin/flush", "secret", "").Code)
+	assert.Equal(t, http.StatusNoContent, send(http.MethodPost, "/admin/keys/reload", "secret", "").Code)
+}
+
+func TestAdminAPIDisabled(t *testing.T) {
+	router, err := InitRouter(types.Options{}, handlers.NewHandler())
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/admin/flush", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestSelfMetrics(t *testing.T) {
+	router, err := InitRouter(types.Options{}, handlers.NewHandler())
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/value/gauge/Unknown", nil))
+	require.Equal(t, http.StatusNotFound, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `metrics_server_http_requests_total{method="GET",route="/api/v1/value/gauge/{metric}",status="404"}`)
+}
+
+func TestStrictSignModeRequiresKey(t *testing.T) {
+	_, err := InitRouter(types.Options{SignMode: "strict"}, handlers.NewHandler())
+	assert.Error(t, err)
+
+	_, err = InitRouter(types.Options{SignMode: "always"}, handlers.NewHandler())
+	assert.Error(t, err)
+}
+
+func TestTrustedSubnet(t *testing.T) {
+	router, err := InitRouter(types.Options{TrustedSubnet: "10.0.0.0/8"}, handlers.NewHandler())
+	require.NoError(t, err)
+
+	send := func(method, target, realIP string) int {
+		r := httptest.NewRequest(method, target, strings.NewReader(`[{"id":"Alloc","type":"gauge","value":1}]`))
+		if realIP != "" {
+			r.Header.Set("X-Real-IP", realIP)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, send(http.MethodPost, "/updates/", "10.0.0.7"))
+	assert.Equal(t, http.StatusForbidden, send(http.MethodPost, "/api/v1/updates/", "192.168.0.7"))
+	assert.Equal(t, http.StatusForbidden, send(http.MethodPost, "/update/gauge/Alloc/1", ""))
+	assert.Equal(t, http.StatusOK, send(http.MethodGet, "/value/gauge/Alloc", ""), "reads are not restricted")
+
+	_, err = InitRouter(types.Options{TrustedSubnet: "10.0.0.0"}, handlers.NewHandler())
+	assert.Error(t, err)
+}
+
+func TestShortJWTSecret(t *testing.T) {
+	_, err := InitRouter(types.Options{JWTSecret: "secret"}, handlers.NewHandler())
+	assert.Error(t, err)
+}
+
+// jwtSecret — секрет HS256 не короче jwt.MinSecretLength
+const jwtSecret = "0123456789abcdef0123456789abcdef"
+
+func TestTokenScopes(t *testing.T) {
+	router, err := InitRouter(types.Options{JWTSecret: jwtSecret, AdminKey: "admin-key"}, handlers.NewHandler())
+	require.NoError(t, err)
+
+	send := func(method, target, bearer string) int {
+		r := httptest.NewRequest(method, target, strings.NewReader(`[{"id":"Alloc","type":"gauge","value":1}]`))
+		if bearer != "" {
+			r.Header.Set("Authorization", "Bearer "+bearer)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return w.Code
+	}
+	token := func(scope string) string {
+		s, err := jwt.Sign(jwt.Claims{Subject: "test", Scope: scope, ExpiresAt: time.Now().Add(time.Hour).Unix()}, []byte(jwtSecret))
+		require.NoError(t, err)
+		return s
+	}
+
+	assert.Equal(t, http.StatusOK, send(http.MethodGet, "/healthz", ""), "health checks are open")
+	assert.Equal(t, http.StatusUnauthorized, send(http.MethodGet, "/values", ""))
+	assert.Equal(t, http.StatusUnauthorized, send(http.MethodGet, "/", ""))
+	assert.Equal(t, http.StatusOK, send(http.MethodGet, "/values", token("read")))
+
+	assert.Equal(t, http.StatusForbidden, send(http.MethodPost, "/api/v1/updates/", token("read")))
+	assert.Equal(t, http.StatusOK, send(http.MethodPost, "/api/v1/updates/", token("write")))
+	assert.Equal(t, http.StatusOK, send(http.MethodGet, "/value/gauge/Alloc", token("read")))
+
+	assert.Equal(t, http.StatusForbidden, send(http.MethodGet, "/metrics", token("write")))
+	assert.Equal(t, http.StatusOK, send(http.MethodGet, "/metrics", token("read")))
+	assert.Equal(t, http.StatusForbidden, send(http.MethodGet, "/admin/log-level", token("read")))
+	assert.Equal(t, http.StatusOK, send(http.MethodGet, "/admin/log-level", token("admin")))
+	assert.Equal(t, http.StatusOK, send(http.MethodGet, "/admin/log-level", "admin-key"))
+}
+
+func TestAuditLog(t *testing.T) {
+	h := handlers.NewHandler()
+	h.Store.UpdateCounter("PollCount", 5)
+	var err error
+	h.Audit, err = audit.Open(filepath.Join(t.TempDir(), "audit.log"))
+	require.NoError(t, err)
+	defer h.Audit.Close()
+
+	router, err := InitRouter(types.Options{JWTSecret: jwtSecret}, h)
+	require.NoError(t, err)
+
+	send := func(method, target, scope, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, target, strings.NewReader(body))
+		if scope != "" {
+			bearer, err := jwt.Sign(jwt.Claims{Subject: "alice", Scope: scope, ExpiresAt: time.Now().Add(time.Hour).Unix()}, []byte(jwtSecret))
+			require.NoError(t, err)
+			r.Header.Set("Authorization", "Bearer "+bearer)
+		}
+		r.Header.Set("X-Tenant-ID", "team-a")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return w
+	}
+
+	require.Equal(t, http.StatusOK, send(http.MethodPost, "/updates/", "write", `[{"id":"Alloc","type":"gauge","value":1},{"id":"Sys","type":"gauge","value":2}]`).Code)
+	require.Equal(t, http.StatusOK, send(http.MethodGet, "/values", "read", "").Code)
+	require.Equal(t, http.StatusOK, send(http.MethodPost, "/admin/counters/reset?name=PollCount", "admin", "").Code)
+	require.Equal(t, http.StatusForbidden, send(http.MethodPost, "/updates/", "read", `[]`).Code)
+	require.Equal(t, http.StatusUnauthorized, send(http.MethodPost, "/admin/counters/reset?name=PollCount", "", "").Code)
+
+	w := send(http.MethodGet, "/admin/audit?actor=alice&from=2000-01-01T00:00:00Z", "admin", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var res struct {
+		Head    audit.Head    `json:"head"`
+		Entries []audit.Entry `json:"entries"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+	require.Len(t, res.Entries, 3, "reads are not recorded")
+	assert.Equal(t, uint64(4), res.Head.Seq)
+
+	update, reset, denied := res.Entries[0], res.Entries[1], res.Entries[2]
+	assert.Equal(t, "/updates", update.Route, "route pattern as in telemetry")
+	assert.Equal(t, 2, update.Metrics)
+	assert.Equal(t, "team-a", update.Tenant)
+	assert.False(t, update.Admin)
+	assert.Equal(t, "/admin/counters/reset", reset.Route)
+	assert.Equal(t, "name=PollCount", reset.Query)
+	assert.Equal(t, 1, reset.Metrics)
+	assert.True(t, reset.Admin)
+	assert.Equal(t, http.StatusForbidden, denied.Status, "token without scope is recorded with its subject")
+	assert.Equal(t, "/updates", denied.Route)
+
+	w = send(http.MethodGet, "/admin/audit", "admin", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+	require.Len(t, res.Entries, 4)
+	anonymous := res.Entries[3]
+	assert.Equal(t, http.StatusUnauthorized, anonymous.Status)
+	assert.Equal(t, anonymous.Client, anonymous.Actor, "request without token is recorded under client")
+
+	assert.Equal(t, http.StatusBadRequest, send(http.MethodGet, "/admin/audit?from=yesterday", "admin", "").Code)
+}
+
+func TestSpecCoversRoutes(t *testing.T) {
+	router, err := InitRouter(types.Options{AdminKey: "secret"}, handlers.NewHandler())
+	require.NoError(t, err)
+
+	var spec struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	require.NoError(t, json.Unmarshal(openapi.Spec(), &spec))
+
+	// HTML-страницы и статические файлы не входят в описание API
+	pages := map[string]bool{"/": true, "/metric/{type}/{metric}": true, "/static/*": true}
+
+	err = chi.Walk(router, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		route = strings.TrimPrefix(route, apiPrefix)
+		if pages[route] {
+			return nil
+		}
+		if route == "/value/gauge/{metric}" || route == "/value/counter/{metric}" {
+			route = "/value/{type}/{metric}"
+		}
+		_, ok := spec.Paths[route][strings.ToLower(method)]
+		assert.True(t, ok, "%s %s is not described in openapi.json", method, route)
+		return nil
+	})
+	require.NoError(t, err)
+}
@@ -1,17 +1,61 @@
 package routers
 
 import (
+	"errors"
+	"fmt"
+	"time"
+
 	"github.com/RomanenkoDR/metrics/internal/config/server/types"
+	"github.com/RomanenkoDR/metrics/internal/handlers"
+	"github.com/RomanenkoDR/metrics/internal/middleware/bodylimit"
 	"github.com/RomanenkoDR/metrics/internal/middleware/gzip"
 	"github.com/RomanenkoDR/metrics/internal/middleware/logger"
+	"github.com/RomanenkoDR/metrics/internal/middleware/ratelimit"
 	"github.com/RomanenkoDR/metrics/internal/middleware/token"
+	"github.com/RomanenkoDR/metrics/internal/telemetry"
 	"github.com/go-chi/chi/v5"
 )
 
-func setupMiddleware(router chi.Router, cfg types.Options) {
+func setupMiddleware(router chi.Router, cfg types.Options, h handlers.Handler, limiter *ratelimit.Limiter) error {
+	sign, err := signOptions(cfg, h.Keys.Signing())
+	if err != nil {
+		return err
+	}
+
+	router.Use(telemetry.Middleware(telemetry.Default))
 	router.Use(logger.LogHandler)
-	router.Use(gzip.GzipHandle)
-	if cfg.Key != "" {
-		router.Use(token.CheckReqSign(cfg.Key))
+	// Ограничение применяется раньше чтения тела, распаковки и проверки подписи,
+	// чтобы превысивший его клиент не тратил ресурсы сервера
+	if limiter != nil {
+		router.Use(limiter.Middleware)
+	}
+	// Размер сжатого тела ограничивается до распаковки, распакованного — при чтении
+	router.Use(bodylimit.Handler(cfg.MaxBodySize))
+	router.Use(gzip.Handle(cfg.MaxDecompressedSize))
+	if h.Keys.Signing() {
+		router.Use(token.CheckReqSign(h.Keys.SignKey, sign))
+	}
+	return nil
+}
+
+// signOptions проверяет режим подписи запросов
+func signOptions(cfg types.Options, signing bool) (token.Options, error) {
+	opt := token.Options{
+		Skew: time.Duration(cfg.SignSkew) * time.Second,
+		// У административного API своя аутентификация по токену
+		Skip: []string{"/admin/"},
+		// Пакеты метрик бывают большими, их подпись проверяется без копирования тела в память
+		Stream: []string{"/updates/", apiPrefix + "/updates/"},
+	}
+	switch cfg.SignMode {
+	case "", "optional":
+	case "strict":
+		if !signing {
+			return opt, errors.New("strict sign mode requires a sign key, set -k or -key-file")
+		}
+		opt.Strict = true
+	default:
+		return opt, fmt.Errorf("incorrect sign mode %q, should be optional or strict", cfg.SignMode)
 	}
+	return opt, nil
 }
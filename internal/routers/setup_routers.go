@@ -1,18 +1,119 @@
 package routers
 
 import (
+	"net/http"
+
 	"github.com/RomanenkoDR/metrics/internal/handlers"
+	"github.com/RomanenkoDR/metrics/internal/middleware/auth"
+	"github.com/RomanenkoDR/metrics/internal/openapi"
+	"github.com/RomanenkoDR/metrics/internal/telemetry"
 	"github.com/go-chi/chi/v5"
 )
 
-func setupRoutes(router chi.Router, h handlers.Handler) {
-	router.Get("/", h.HandleMain)
+// apiPrefix — префикс версионированного JSON API
+const apiPrefix = "/api/v1"
+
+// validators — проверка тел запросов по схемам OpenAPI
+type validators struct {
+	update  func(http.Handler) http.Handler
+	updates func(http.Handler) http.Handler
+	value   func(http.Handler) http.Handler
+}
+
+// newValidators создаёт middleware проверки запросов. Если validate равен false,
+// запросы пропускаются без проверки.
+func newValidators(validate bool) (validators, error) {
+	pass := func(next http.Handler) http.Handler { return next }
+	v := validators{update: pass, updates: pass, value: pass}
+	if !validate {
+		return v, nil
+	}
+
+	var err error
+	if v.update, err = openapi.Validator(http.MethodPost, "/update/"); err != nil {
+		return v, err
+	}
+	// Пакет метрик проверяется поэлементно при разборе, чтобы не держать тело в памяти
+	if v.updates, err = openapi.ItemValidator(http.MethodPost, "/updates/"); err != nil {
+		return v, err
+	}
+	if v.value, err = openapi.Validator(http.MethodPost, "/value/"); err != nil {
+		return v, err
+	}
+	return v, nil
+}
+
+// setupRoutes регистрирует маршруты и области доступа, которые нужны для каждого из них.
+// Middleware trusted применяется к запросам записи метрик.
+func setupRoutes(router chi.Router, h handlers.Handler, validate bool, authz *auth.Authorizer, trusted func(http.Handler) http.Handler) error {
+	v, err := newValidators(validate)
+	if err != nil {
+		return err
+	}
+
+	// Проверки живости и готовности для оркестратора и статические файлы доступны без токена
+	router.Get("/healthz", h.HandleHealthz)
+	router.Get("/readyz", h.HandleReadyz)
+	router.Handle("/static/*", h.HandleStatic())
+
+	router.With(authz.Require(auth.ScopeRead)).Get("/", h.HandleMain)
+	router.With(authz.Require(auth.ScopeRead)).Get("/metric/{type}/{metric}", h.HandleMetricPage)
+
+	// Собственные метрики сервера в формате Prometheus
+	router.With(authz.Require(auth.ScopeRead)).Get("/metrics", telemetry.Default.Handler)
+
+	// JSON API доступно по /api/v1 и по прежним путям без версии
+	router.Route(apiPrefix, func(r chi.Router) {
+		r.Get("/openapi.json", openapi.Handler)
+		setupAPIRoutes(r, h, v, authz, trusted)
+	})
+	setupAPIRoutes(router, h, v, authz, trusted)
+	return nil
+}
+
+func setupAPIRoutes(router chi.Router, h handlers.Handler, v validators, authz *auth.Authorizer, trusted func(http.Handler) http.Handler) {
 	router.Get("/ping", h.HandlePing)
-	router.Get("/value/gauge/{metric}", h.HandleValue)
-	router.Get("/value/counter/{metric}", h.HandleValue)
 
-	router.Post("/update/{type}/{metric}/{value}", h.HandleUpdate)
-	router.Post("/value/", h.HandleValueJSON)
-	router.Post("/update/", h.HandleUpdateJSON)
-	router.Post("/updates/", h.HandleUpdateBatch)
+	// Чтение метрик, алертов и прогнозов
+	router.Group(func(r chi.Router) {
+		r.Use(authz.Require(auth.ScopeRead))
+		r.Get("/value/gauge/{metric}", h.HandleValue)
+		r.Get("/value/counter/{metric}", h.HandleValue)
+		r.Get("/values", h.HandleValues)
+		r.Get("/alerts", h.HandleAlerts)
+		r.Get("/alerts/notifications", h.HandleNotifications)
+		r.Get("/alerts/silences", h.HandleSilences)
+		r.Get("/anomalies", h.HandleAnomalies)
+		r.Get("/forecast/{metric}", h.HandleForecast)
+		r.Get("/export", h.HandleExport)
+		r.With(v.value).Post("/value/", h.HandleValueJSON)
+		r.Post("/values/", h.HandleValuesJSON)
+	})
+
+	// Запись метрик, изменения и отказы в доступе записываются в журнал аудита
+	router.Group(func(r chi.Router) {
+		r.Use(h.Audit.Middleware, authz.Require(auth.ScopeWrite), trusted)
+		r.Post("/update/{type}/{metric}/{value}", h.HandleUpdate)
+		r.With(v.update).Post("/update/", h.HandleUpdateJSON)
+		r.With(v.updates).Post("/updates/", h.HandleUpdateBatch)
+		r.Post("/import", h.HandleImport)
+	})
+
+	// Управление алертами
+	router.Group(func(r chi.Router) {
+		r.Use(h.Audit.Middleware, authz.Require(auth.ScopeAdmin))
+		r.Post("/alerts/silences", h.HandleSilenceCreate)
+		r.Delete("/alerts/silences/{id}", h.HandleSilenceExpire)
+		r.Post("/alerts/{rule}/ack", h.HandleAlertAck)
+	})
+}
+
+func setupAdminRoutes(router chi.Router, h handlers.Handler) {
+	router.Post("/flush", h.HandleAdminFlush)
+	router.Post("/counters/reset", h.HandleAdminResetCounters)
+	router.Post("/metrics/drop", h.HandleAdminDrop)
+	router.Post("/keys/reload", h.HandleAdminReloadKeys)
+	router.Get("/log-level", h.HandleAdminLogLevel)
+	router.Put("/log-level", h.HandleAdminSetLogLevel)
+	router.Get("/audit", h.HandleAdminAudit)
 }
@@ -2,7 +2,11 @@
 package storage
 
 import (
+	"encoding/json"
 	"fmt"
+	"strings"
+	"sync"
+	"time"
 )
 
 type Counter int64
@@ -11,6 +15,25 @@ type Gauge float64
 type MemStorage struct {
 	CounterData map[string]Counter
 	GaugeData   map[string]Gauge
+	Updated     map[string]time.Time // время последнего обновления метрики по ключу Key(тип, имя)
+
+	// history хранит последние значения для графиков и прогнозов, в файл не сохраняется
+	history *History
+
+	// mu защищает карты от одновременной записи из HTTP-обработчиков и других источников (graphite)
+	mu *sync.RWMutex
+}
+
+// Типы метрик
+const (
+	CounterType = "counter"
+	GaugeType   = "gauge"
+)
+
+// Key возвращает ключ метрики в Updated и истории. Счётчик и gauge с одним именем —
+// разные метрики, поэтому тип входит в ключ.
+func Key(metricType, name string) string {
+	return metricType + ":" + name
 }
 
 // Define methods to write/read data from different providers
@@ -21,6 +44,19 @@ type StorageWriter interface {
 	Close()
 }
 
+// Dropper удаляет метрики из внешнего хранилища, чтобы они не вернулись при восстановлении.
+// s — хранилище метрик после удаления.
+type Dropper interface {
+	Drop(s MemStorage, counters, gauges []string) error
+}
+
+// StateStore сохраняет служебное состояние сервера (алерты, тишины) рядом с метриками.
+// LoadState возвращает nil без ошибки, если состояние ещё не сохранялось.
+type StateStore interface {
+	SaveState(key string, data []byte) error
+	LoadState(key string) ([]byte, error)
+}
+
 // Write data to store
 func SaveData(m MemStorage, sw StorageWriter) error {
 	err := sw.Write(m)
@@ -34,10 +70,45 @@ func New() MemStorage {
 	return MemStorage{
 		CounterData: map[string]Counter{},
 		GaugeData:   map[string]Gauge{},
+		Updated:     map[string]time.Time{},
+		history:     NewHistory(DefaultHistorySize, DefaultHistorySeries),
+		mu:          &sync.RWMutex{},
 	}
 }
 
+// lock захватывает блокировку на запись, если хранилище создано через New
+func (m *MemStorage) lock() func() {
+	if m.mu == nil {
+		return func() {}
+	}
+	m.mu.Lock()
+	return m.mu.Unlock
+}
+
+// rlock захватывает блокировку на чтение, если хранилище создано через New
+func (m *MemStorage) rlock() func() {
+	if m.mu == nil {
+		return func() {}
+	}
+	m.mu.RLock()
+	return m.mu.RUnlock
+}
+
+// MarshalJSON сериализует хранилище под блокировкой, чтобы сохранение не пересекалось с записью
+func (m MemStorage) MarshalJSON() ([]byte, error) {
+	defer m.rlock()()
+
+	type plain struct {
+		CounterData map[string]Counter
+		GaugeData   map[string]Gauge
+		Updated     map[string]time.Time
+	}
+	return json.Marshal(plain{CounterData: m.CounterData, GaugeData: m.GaugeData, Updated: m.Updated})
+}
+
 func (m *MemStorage) Get(metric string) (interface{}, error) {
+	defer m.rlock()()
+
 	if v, ok := m.CounterData[metric]; ok {
 		return v, nil
 	}
@@ -48,18 +119,122 @@ func (m *MemStorage) Get(metric string) (interface{}, error) {
 
 }
 
+// GetCounter возвращает значение счётчика и признак его наличия
+func (m *MemStorage) GetCounter(metric string) (Counter, bool) {
+	defer m.rlock()()
+
+	v, ok := m.CounterData[metric]
+	return v, ok
+}
+
+// GetGauge возвращает значение gauge-метрики и признак её наличия
+func (m *MemStorage) GetGauge(metric string) (Gauge, bool) {
+	defer m.rlock()()
+
+	v, ok := m.GaugeData[metric]
+	return v, ok
+}
+
+// GetAllCounters возвращает копию всех счётчиков
 func (m *MemStorage) GetAllCounters() map[string]Counter {
-	return m.CounterData
+	defer m.rlock()()
+
+	res := make(map[string]Counter, len(m.CounterData))
+	for k, v := range m.CounterData {
+		res[k] = v
+	}
+	return res
 }
 
+// GetAllGauge возвращает копию всех gauge-метрик
 func (m *MemStorage) GetAllGauge() map[string]Gauge {
-	return m.GaugeData
+	defer m.rlock()()
+
+	res := make(map[string]Gauge, len(m.GaugeData))
+	for k, v := range m.GaugeData {
+		res[k] = v
+	}
+	return res
+}
+
+// LastUpdated возвращает время последнего обновления метрики
+func (m *MemStorage) LastUpdated(metricType, metric string) (time.Time, bool) {
+	defer m.rlock()()
+
+	t, ok := m.Updated[Key(metricType, metric)]
+	return t, ok
+}
+
+// Len возвращает число счётчиков и gauge-метрик
+func (m *MemStorage) Len() (counters, gauges int) {
+	defer m.rlock()()
+	return len(m.CounterData), len(m.GaugeData)
+}
+
+// History возвращает последние значения метрики от старых к новым
+func (m *MemStorage) History(metricType, metric string) []Point {
+	return m.history.Points(Key(metricType, metric))
 }
 
 func (m *MemStorage) UpdateGauge(metric string, value Gauge) {
+	defer m.lock()()
+
 	m.GaugeData[metric] = value
+	m.touch(GaugeType, metric, float64(value))
 }
 
 func (m *MemStorage) UpdateCounter(metric string, value Counter) {
+	defer m.lock()()
+
 	m.CounterData[metric] = m.CounterData[metric] + value
+	m.touch(CounterType, metric, float64(m.CounterData[metric]))
+}
+
+// Restore устанавливает значения метрик, загруженные из внешнего хранилища.
+// Ключи updated — Key(тип, имя).
+func (m *MemStorage) Restore(counters map[string]Counter, gauges map[string]Gauge, updated map[string]time.Time) {
+	defer m.lock()()
+
+	for k, v := range counters {
+		m.CounterData[k] = v
+	}
+	for k, v := range gauges {
+		m.GaugeData[k] = v
+	}
+	if m.Updated == nil {
+		m.Updated = map[string]time.Time{}
+	}
+	for k, v := range updated {
+		m.Updated[k] = v
+	}
+}
+
+// touch запоминает время обновления и новое значение метрики. Вызывается под блокировкой.
+func (m *MemStorage) touch(metricType, metric string, value float64) {
+	now := time.Now()
+	if m.Updated == nil {
+		m.Updated = map[string]time.Time{}
+	}
+	key := Key(metricType, metric)
+	m.Updated[key] = now
+	m.history.Add(key, value, now)
+}
+
+// upgradeUpdated переводит время обновления из файлов прежнего формата, где ключом было
+// только имя метрики, на ключи Key(тип, имя)
+func (m *MemStorage) upgradeUpdated() {
+	defer m.lock()()
+
+	for k, t := range m.Updated {
+		if strings.HasPrefix(k, CounterType+":") || strings.HasPrefix(k, GaugeType+":") {
+			continue
+		}
+		delete(m.Updated, k)
+		if _, ok := m.CounterData[k]; ok {
+			m.Updated[Key(CounterType, k)] = t
+		}
+		if _, ok := m.GaugeData[k]; ok {
+			m.Updated[Key(GaugeType, k)] = t
+		}
+	}
 }
@@ -0,0 +1,56 @@
+package storage
+
+// ResetCounter обнуляет счётчик. Возвращает false, если счётчика нет.
+func (m *MemStorage) ResetCounter(metric string) bool {
+	defer m.lock()()
+
+	if _, ok := m.CounterData[metric]; !ok {
+		return false
+	}
+	m.CounterData[metric] = 0
+	m.touch(CounterType, metric, 0)
+	return true
+}
+
+// ResetCounters обнуляет все счётчики и возвращает их число
+func (m *MemStorage) ResetCounters() int {
+	defer m.lock()()
+
+	for k := range m.CounterData {
+		m.CounterData[k] = 0
+		m.touch(CounterType, k, 0)
+	}
+	return len(m.CounterData)
+}
+
+// Drop удаляет метрики, для которых match возвращает true, вместе с их историей.
+// counters и gauges задают, метрики каких типов рассматриваются. Возвращает имена удалённых
+// счётчиков и gauge-метрик.
+func (m *MemStorage) Drop(match func(metric string) bool, counters, gauges bool) (droppedCounters, droppedGauges []string) {
+	defer m.lock()()
+
+	forget := func(metricType, k string) {
+		delete(m.Updated, Key(metricType, k))
+		m.history.Delete(Key(metricType, k))
+	}
+
+	if counters {
+		for k := range m.CounterData {
+			if match(k) {
+				delete(m.CounterData, k)
+				forget(CounterType, k)
+				droppedCounters = append(droppedCounters, k)
+			}
+		}
+	}
+	if gauges {
+		for k := range m.GaugeData {
+			if match(k) {
+				delete(m.GaugeData, k)
+				forget(GaugeType, k)
+				droppedGauges = append(droppedGauges, k)
+			}
+		}
+	}
+	return droppedCounters, droppedGauges
+}
@@ -4,58 +4,67 @@ import (
 	"encoding/json"
 	"go.uber.org/zap"
 	"os"
+	"path/filepath"
+	"sync"
 	"time"
 )
 
 type Localfile struct {
 	Path string
+
+	// mu упорядочивает записи: периодическое сохранение, загрузку и сохранение по запросу
+	mu sync.Mutex
 }
 
-// Очистка файла перед записью
-func (localfile *Localfile) cleanFile() error {
-	f, err := os.OpenFile(localfile.Path, os.O_WRONLY|os.O_CREATE, 0666)
+// Запись данных в файл. Данные пишутся во временный файл, который затем заменяет основной,
+// чтобы при сбое записи файл не остался пустым или обрезанным. Одновременные записи выполняются
+// по очереди, и каждая сериализует хранилище уже под блокировкой, поэтому более поздняя запись
+// не заменится более старым снимком.
+func (localfile *Localfile) Write(s MemStorage) error {
+	localfile.mu.Lock()
+	defer localfile.mu.Unlock()
+
+	data, err := json.MarshalIndent(s, "", "  ")
 	if err != nil {
-		zap.L().Error("Ошибка открытия файла для очистки", zap.String("path", localfile.Path), zap.Error(err))
+		zap.L().Error("Ошибка сериализации данных", zap.Error(err))
 		return err
 	}
-	defer f.Close()
 
-	err = f.Truncate(0)
-	if err != nil {
-		zap.L().Error("Ошибка очистки файла", zap.String("path", localfile.Path), zap.Error(err))
+	if err := writeFileAtomic(localfile.Path, data); err != nil {
+		zap.L().Error("Ошибка записи в файл", zap.String("path", localfile.Path), zap.Error(err))
 		return err
 	}
+
+	zap.L().Info("Данные успешно записаны в файл", zap.String("path", localfile.Path))
 	return nil
 }
 
-// Запись данных в файл
-func (localfile *Localfile) Write(s MemStorage) error {
-	err := localfile.cleanFile()
+// writeFileAtomic записывает данные в уникальный временный файл рядом с path и заменяет им path
+func writeFileAtomic(path string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
 	if err != nil {
 		return err
 	}
+	tmp := f.Name()
+	defer os.Remove(tmp)
 
-	f, err := os.OpenFile(localfile.Path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
-	if err != nil {
-		zap.L().Error("Ошибка открытия файла для записи", zap.String("path", localfile.Path), zap.Error(err))
+	if _, err := f.Write(data); err != nil {
+		f.Close()
 		return err
 	}
-	defer f.Close()
-
-	data, err := json.MarshalIndent(s, "", "  ")
-	if err != nil {
-		zap.L().Error("Ошибка сериализации данных", zap.Error(err))
+	if err := f.Chmod(0o644); err != nil {
+		f.Close()
 		return err
 	}
-
-	_, err = f.Write(data)
-	if err != nil {
-		zap.L().Error("Ошибка записи в файл", zap.String("path", localfile.Path), zap.Error(err))
+	if err := f.Close(); err != nil {
 		return err
 	}
+	return os.Rename(tmp, path)
+}
 
-	zap.L().Info("Данные успешно записаны в файл", zap.String("path", localfile.Path))
-	return nil
+// Drop удаляет метрики из файла: файл перезаписывается хранилищем, из которого они уже удалены
+func (localfile *Localfile) Drop(s MemStorage, counters, gauges []string) error {
+	return localfile.Write(s)
 }
 
 // Восстановление данных из файла
@@ -86,6 +95,7 @@ func (localfile *Localfile) RestoreData(s *MemStorage) error {
 		zap.L().Error("Ошибка декодирования JSON из файла", zap.String("path", localfile.Path), zap.Error(err))
 		return err
 	}
+	s.upgradeUpdated()
 
 	zap.L().Info("Данные успешно загружены из файла", zap.String("path", localfile.Path))
 	return nil
@@ -106,3 +116,14 @@ func (localfile *Localfile) Save(t int, s MemStorage) error {
 func (localfile *Localfile) Close() {
 	// Пока не требуется
 }
+
+// CheckWritable проверяет, что в каталог файла хранения можно записывать
+func (localfile *Localfile) CheckWritable() error {
+	f, err := os.CreateTemp(filepath.Dir(localfile.Path), ".healthcheck-*")
+	if err != nil {
+		return err
+	}
+	name := f.Name()
+	f.Close()
+	return os.Remove(name)
+}
@@ -0,0 +1,130 @@
+package storage
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// DefaultHistorySize — сколько последних значений хранится для каждой метрики
+const DefaultHistorySize = 256
+
+// DefaultHistorySeries — для скольких метрик хранится история. При превышении вытесняется
+// история метрики, которая дольше всех не обновлялась.
+const DefaultHistorySeries = 10000
+
+// Point — значение метрики в момент времени
+type Point struct {
+	Time  time.Time `json:"time"`
+	Value float64   `json:"value"`
+}
+
+// History хранит последние значения метрик в кольцевых буферах фиксированного размера
+type History struct {
+	mu     sync.RWMutex
+	size   int
+	limit  int
+	series map[string]*list.Element
+	lru    *list.List // от недавно обновлённых к давно не обновлявшимся
+}
+
+type ring struct {
+	key    string
+	points []Point // растёт до size, затем перезаписывается по кругу
+	next   int
+}
+
+// NewHistory создаёт историю, хранящую не более size значений для каждой из не более чем
+// series метрик
+func NewHistory(size, series int) *History {
+	if size <= 0 {
+		size = DefaultHistorySize
+	}
+	if series <= 0 {
+		series = DefaultHistorySeries
+	}
+	return &History{
+		size:   size,
+		limit:  series,
+		series: map[string]*list.Element{},
+		lru:    list.New(),
+	}
+}
+
+// Add добавляет значение метрики в историю
+func (h *History) Add(key string, value float64, t time.Time) {
+	if h == nil {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	el, ok := h.series[key]
+	if ok {
+		h.lru.MoveToFront(el)
+	} else {
+		if h.lru.Len() >= h.limit {
+			oldest := h.lru.Back()
+			h.lru.Remove(oldest)
+			delete(h.series, oldest.Value.(*ring).key)
+		}
+		el = h.lru.PushFront(&ring{key: key})
+		h.series[key] = el
+	}
+
+	r := el.Value.(*ring)
+	p := Point{Time: t, Value: value}
+	if len(r.points) < h.size {
+		r.points = append(r.points, p)
+		return
+	}
+	r.points[r.next] = p
+	r.next = (r.next + 1) % h.size
+}
+
+// Points возвращает копию истории метрики от старых значений к новым
+func (h *History) Points(key string) []Point {
+	if h == nil {
+		return nil
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	el, ok := h.series[key]
+	if !ok {
+		return nil
+	}
+
+	r := el.Value.(*ring)
+	res := make([]Point, 0, len(r.points))
+	res = append(res, r.points[r.next:]...)
+	res = append(res, r.points[:r.next]...)
+	return res
+}
+
+// Len возвращает число метрик, для которых хранится история
+func (h *History) Len() int {
+	if h == nil {
+		return 0
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.lru.Len()
+}
+
+// Delete удаляет историю метрики
+func (h *History) Delete(key string) {
+	if h == nil {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if el, ok := h.series[key]; ok {
+		h.lru.Remove(el)
+		delete(h.series, key)
+	}
+}
@@ -0,0 +1,124 @@
+package storage
+
+import "time"
+
+// Import применяет загруженные значения и сохраняет хранилище во внешнее хранилище w. Если
+// replaceCounters равен false, значения счётчиков прибавляются к текущим, иначе заменяют их.
+// updated задаёт время обновления метрик, для остальных используется текущее; ключи updated —
+// Key(тип, имя).
+//
+// Хранилище заблокировано на запись от применения до конца сохранения: одновременные обновления
+// ждут, поэтому, если сохранить не удалось, затронутые метрики возвращаются к прежним значениям
+// без потери чужих изменений. Через Snapshots сохранение выполняется в очереди с остальными
+// записями. Если w равен nil, значения только применяются.
+func (m *MemStorage) Import(counters map[string]Counter, gauges map[string]Gauge, updated map[string]time.Time, replaceCounters bool, w StorageWriter) error {
+	change := func() (rollback func()) {
+		return m.apply(counters, gauges, updated, replaceCounters)
+	}
+	if s, ok := w.(*Snapshots); ok {
+		return s.commit(m, change)
+	}
+	return commit(m, w, change)
+}
+
+// commit выполняет change и сохраняет хранилище в w, не отпуская блокировку на запись.
+// Если сохранить не удалось, изменение откатывается.
+func commit(m *MemStorage, w StorageWriter, change func() (rollback func())) error {
+	defer m.lock()()
+
+	rollback := change()
+	if w == nil {
+		return nil
+	}
+	if err := w.Write(m.unlocked()); err != nil {
+		rollback()
+		return err
+	}
+	return nil
+}
+
+// unlocked возвращает хранилище с теми же данными без блокировки, чтобы сохранить его,
+// пока блокировка удерживается вызывающим
+func (m *MemStorage) unlocked() MemStorage {
+	return MemStorage{CounterData: m.CounterData, GaugeData: m.GaugeData, Updated: m.Updated, history: m.history}
+}
+
+// apply применяет загруженные значения и возвращает функцию отката, которая возвращает
+// затронутые метрики к прежним значениям. Вызывается под блокировкой.
+func (m *MemStorage) apply(counters map[string]Counter, gauges map[string]Gauge, updated map[string]time.Time, replaceCounters bool) (rollback func()) {
+
+	type prevCounter struct {
+		value  Counter
+		exists bool
+	}
+	type prevGauge struct {
+		value  Gauge
+		exists bool
+	}
+	type prevTime struct {
+		value  time.Time
+		exists bool
+	}
+
+	if m.Updated == nil {
+		m.Updated = map[string]time.Time{}
+	}
+	now := time.Now()
+
+	oldCounters := make(map[string]prevCounter, len(counters))
+	oldGauges := make(map[string]prevGauge, len(gauges))
+	oldUpdated := make(map[string]prevTime, len(counters)+len(gauges))
+
+	setUpdated := func(key string) {
+		if _, ok := oldUpdated[key]; !ok {
+			v, exists := m.Updated[key]
+			oldUpdated[key] = prevTime{v, exists}
+		}
+		if t, ok := updated[key]; ok && !t.IsZero() {
+			m.Updated[key] = t
+		} else {
+			m.Updated[key] = now
+		}
+	}
+
+	for k, v := range counters {
+		old, exists := m.CounterData[k]
+		oldCounters[k] = prevCounter{old, exists}
+		if replaceCounters {
+			m.CounterData[k] = v
+		} else {
+			m.CounterData[k] = old + v
+		}
+		setUpdated(Key(CounterType, k))
+	}
+	for k, v := range gauges {
+		old, exists := m.GaugeData[k]
+		oldGauges[k] = prevGauge{old, exists}
+		m.GaugeData[k] = v
+		setUpdated(Key(GaugeType, k))
+	}
+
+	return func() {
+		for k, p := range oldCounters {
+			if p.exists {
+				m.CounterData[k] = p.value
+			} else {
+				delete(m.CounterData, k)
+			}
+		}
+		for k, p := range oldGauges {
+			if p.exists {
+				m.GaugeData[k] = p.value
+			} else {
+				delete(m.GaugeData, k)
+			}
+		}
+		for k, p := range oldUpdated {
+			if p.exists {
+				m.Updated[k] = p.value
+			} else {
+				delete(m.Updated, k)
+			}
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package storage
+
+import (
+	"sort"
+	"strings"
+)
+
+// Метки хранятся прямо в имени метрики в формате тегов Graphite:
+// "name;key1=value1;key2=value2". Хранилище остаётся плоским, а источники,
+// поддерживающие метки, получают стабильное имя серии.
+
+const labelSeparator = ";"
+
+// JoinLabels собирает имя серии из имени метрики и меток (метки сортируются по ключу)
+func JoinLabels(name string, labels map[string]string) string {
+	if len(labels) == 0 {
+		return name
+	}
+
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(name)
+	for _, k := range keys {
+		b.WriteString(labelSeparator)
+		b.WriteString(k)
+		b.WriteString("=")
+		b.WriteString(labels[k])
+	}
+	return b.String()
+}
+
+// SplitLabels разбирает имя серии на имя метрики и метки
+func SplitLabels(id string) (string, map[string]string) {
+	parts := strings.Split(id, labelSeparator)
+	if len(parts) == 1 {
+		return id, nil
+	}
+
+	labels := make(map[string]string, len(parts)-1)
+	for _, p := range parts[1:] {
+		k, v, ok := strings.Cut(p, "=")
+		if !ok || k == "" {
+			continue
+		}
+		labels[k] = v
+	}
+	return parts[0], labels
+}
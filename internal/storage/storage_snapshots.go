@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"sync"
+	"time"
+
+	"github.com/RomanenkoDR/metrics/internal/telemetry"
+)
+
+// Snapshots оборачивает внешнее хранилище и запоминает время последнего успешного сохранения.
+// Записи в хранилище выполняются по очереди: периодическое сохранение, загрузка метрик и
+// сохранение по запросу администратора не пересекаются.
+type Snapshots struct {
+	StorageWriter
+
+	write   sync.Mutex
+	mu      sync.RWMutex
+	started time.Time
+	last    time.Time
+}
+
+// TrackSnapshots начинает отслеживать сохранения в хранилище w
+func TrackSnapshots(w StorageWriter) *Snapshots {
+	return &Snapshots{StorageWriter: w, started: time.Now()}
+}
+
+// Write сохраняет метрики, запоминает время успешного сохранения и длительность записи
+func (s *Snapshots) Write(m MemStorage) error {
+	s.write.Lock()
+	start := time.Now()
+	err := s.StorageWriter.Write(m)
+	s.write.Unlock()
+
+	s.done(start, err)
+	return err
+}
+
+// commit выполняет изменение и сохраняет хранилище в очереди с остальными записями. Очередь
+// захватывается до блокировки хранилища, в том же порядке, что и при периодическом сохранении.
+func (s *Snapshots) commit(m *MemStorage, change func() (rollback func())) error {
+	s.write.Lock()
+	start := time.Now()
+	err := commit(m, s.StorageWriter, change)
+	s.write.Unlock()
+
+	s.done(start, err)
+	return err
+}
+
+// done записывает длительность сохранения и запоминает время успешного
+func (s *Snapshots) done(start time.Time, err error) {
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	telemetry.Default.Observe("snapshot_duration_seconds", telemetry.Labels{"result": result}, time.Since(start).Seconds())
+
+	if err == nil {
+		s.mu.Lock()
+		s.last = time.Now()
+		s.mu.Unlock()
+	}
+}
+
+// Drop удаляет метрики из хранилища в очереди с остальными записями. Если хранилище не умеет
+// удалять метрики, в него записывается снимок s, из которого они уже удалены.
+func (s *Snapshots) Drop(m MemStorage, counters, gauges []string) error {
+	s.write.Lock()
+	defer s.write.Unlock()
+
+	if d, ok := s.StorageWriter.(Dropper); ok {
+		return d.Drop(m, counters, gauges)
+	}
+	return s.StorageWriter.Write(m)
+}
+
+// Save ждёт t секунд и сохраняет метрики, как это делают Save всех хранилищ
+func (s *Snapshots) Save(t int, m MemStorage) error {
+	time.Sleep(time.Second * time.Duration(t))
+	return s.Write(m)
+}
+
+// LastSnapshot возвращает время последнего успешного сохранения; ok равен false,
+// если сохранений ещё не было
+func (s *Snapshots) LastSnapshot() (t time.Time, ok bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.last, !s.last.IsZero()
+}
+
+// Age возвращает, сколько времени прошло с последнего успешного сохранения,
+// а до первого сохранения — с момента запуска
+func (s *Snapshots) Age() time.Duration {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.last.IsZero() {
+		return time.Since(s.started)
+	}
+	return time.Since(s.last)
+}
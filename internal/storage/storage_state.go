@@ -0,0 +1,37 @@
+package storage
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// statePath возвращает путь к файлу состояния рядом с файлом метрик: metrics.json -> metrics.<key>.json
+func (localfile *Localfile) statePath(key string) string {
+	ext := filepath.Ext(localfile.Path)
+	return strings.TrimSuffix(localfile.Path, ext) + "." + key + ".json"
+}
+
+// SaveState атомарно записывает состояние в отдельный файл
+func (localfile *Localfile) SaveState(key string, data []byte) error {
+	path := localfile.statePath(key)
+
+	if err := writeFileAtomic(path, data); err != nil {
+		zap.L().Error("Ошибка записи состояния", zap.String("path", path), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// LoadState читает состояние из файла, отсутствующий файл не считается ошибкой
+func (localfile *Localfile) LoadState(key string) ([]byte, error) {
+	data, err := os.ReadFile(localfile.statePath(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	return data, err
+}
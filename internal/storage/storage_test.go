@@ -1,8 +1,15 @@
 package storage
 
 import (
+	"errors"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 // implement all the tests for storage
@@ -65,3 +72,240 @@ func TestUpdateCounter(t *testing.T) {
 	v, _ = h.Get(key)
 	assert.Equal(t, val+val, v)
 }
+
+func TestLabels(t *testing.T) {
+	id := JoinLabels("cpu_load", map[string]string{"host": "a", "dc": "msk"})
+	assert.Equal(t, "cpu_load;dc=msk;host=a", id)
+
+	name, labels := SplitLabels(id)
+	assert.Equal(t, "cpu_load", name)
+	assert.Equal(t, map[string]string{"host": "a", "dc": "msk"}, labels)
+
+	name, labels = SplitLabels("plain")
+	assert.Equal(t, "plain", name)
+	assert.Nil(t, labels)
+}
+
+func TestHistory(t *testing.T) {
+	h := New()
+	h.UpdateGauge("g", 1)
+	h.UpdateGauge("g", 2)
+	h.UpdateCounter("c", 3)
+	h.UpdateCounter("c", 4)
+
+	points := h.History(GaugeType, "g")
+	assert.Len(t, points, 2)
+	assert.Equal(t, 2.0, points[1].Value)
+
+	points = h.History(CounterType, "c")
+	assert.Equal(t, 7.0, points[1].Value)
+
+	_, ok := h.LastUpdated(GaugeType, "g")
+	assert.True(t, ok)
+
+	// Счётчик и gauge с одним именем не смешиваются
+	h.UpdateCounter("g", 10)
+	assert.Len(t, h.History(GaugeType, "g"), 2)
+	assert.Len(t, h.History(CounterType, "g"), 1)
+	_, ok = h.LastUpdated(CounterType, "c")
+	assert.True(t, ok)
+	_, ok = h.LastUpdated(GaugeType, "c")
+	assert.False(t, ok)
+
+	r := NewHistory(3, 0)
+	for i := 1; i <= 5; i++ {
+		r.Add("x", float64(i), time.Unix(int64(i), 0))
+	}
+	points = r.Points("x")
+	assert.Equal(t, []float64{3, 4, 5}, []float64{points[0].Value, points[1].Value, points[2].Value})
+}
+
+func TestHistoryEviction(t *testing.T) {
+	r := NewHistory(3, 2)
+	r.Add("a", 1, time.Unix(1, 0))
+	r.Add("b", 1, time.Unix(2, 0))
+	r.Add("a", 2, time.Unix(3, 0))
+	r.Add("c", 1, time.Unix(4, 0))
+
+	// Вытесняется b: она дольше всех не обновлялась
+	assert.Equal(t, 2, r.Len())
+	assert.Nil(t, r.Points("b"))
+	assert.Len(t, r.Points("a"), 2)
+	assert.Len(t, r.Points("c"), 1)
+
+	r.Delete("a")
+	assert.Equal(t, 1, r.Len())
+}
+
+func TestUpgradeUpdated(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrics.json")
+	ts := time.Date(2024, 8, 1, 10, 0, 0, 0, time.UTC)
+	data := `{"CounterData":{"m":1},"GaugeData":{"m":2,"g":3},"Updated":{"m":"2024-08-01T10:00:00Z","gauge:g":"2024-08-01T10:00:00Z"}}`
+	require.NoError(t, os.WriteFile(path, []byte(data), 0o600))
+
+	s := New()
+	require.NoError(t, (&Localfile{Path: path}).RestoreData(&s))
+	for _, key := range []string{Key(CounterType, "m"), Key(GaugeType, "m"), Key(GaugeType, "g")} {
+		assert.Equal(t, ts, s.Updated[key], key)
+	}
+	assert.NotContains(t, s.Updated, "m")
+}
+
+func TestImport(t *testing.T) {
+	h := New()
+	h.UpdateCounter("c", 5)
+	h.UpdateGauge("g", 1)
+	ts := time.Date(2024, 8, 1, 10, 0, 0, 0, time.UTC)
+
+	file := &Localfile{Path: filepath.Join(t.TempDir(), "metrics.json")}
+	require.NoError(t, h.Import(map[string]Counter{"c": 2, "n": 1}, map[string]Gauge{"g": 3}, map[string]time.Time{Key(CounterType, "n"): ts}, false, TrackSnapshots(file)))
+	v, _ := h.GetCounter("c")
+	assert.Equal(t, Counter(7), v)
+	g, _ := h.GetGauge("g")
+	assert.Equal(t, Gauge(3), g)
+	updated, _ := h.LastUpdated(CounterType, "n")
+	assert.Equal(t, ts, updated)
+
+	// Загруженные значения сохранены в файл
+	saved := New()
+	require.NoError(t, file.RestoreData(&saved))
+	v, _ = saved.GetCounter("c")
+	assert.Equal(t, Counter(7), v)
+
+	require.NoError(t, h.Import(map[string]Counter{"c": 2}, nil, nil, true, nil))
+	v, _ = h.GetCounter("c")
+	assert.Equal(t, Counter(2), v)
+}
+
+// blockingWriter не может сохранить данные и сообщает, что запись началась
+type blockingWriter struct {
+	MockWriter
+	started chan struct{}
+	once    sync.Once
+}
+
+func (w *blockingWriter) Write(s MemStorage) error {
+	w.once.Do(func() { close(w.started) })
+	time.Sleep(20 * time.Millisecond)
+	return errors.New("disk full")
+}
+
+func TestImportRollback(t *testing.T) {
+	h := New()
+	h.UpdateCounter("c", 5)
+	h.UpdateGauge("g", 1)
+
+	w := &blockingWriter{started: make(chan struct{})}
+	snapshots := TrackSnapshots(w)
+
+	// Обновление и периодическое сохранение во время загрузки ждут её окончания
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		<-w.started
+		h.UpdateCounter("c", 1)
+		assert.Error(t, snapshots.Write(h))
+	}()
+
+	err := h.Import(map[string]Counter{"c": 2, "n": 1}, map[string]Gauge{"g": 3}, nil, false, snapshots)
+	require.Error(t, err)
+	<-done
+
+	v, _ := h.GetCounter("c")
+	assert.Equal(t, Counter(6), v, "concurrent update survives rollback")
+	g, _ := h.GetGauge("g")
+	assert.Equal(t, Gauge(1), g)
+	_, ok := h.GetCounter("n")
+	assert.False(t, ok)
+	_, ok = h.LastUpdated(CounterType, "n")
+	assert.False(t, ok)
+}
+
+func TestResetAndDrop(t *testing.T) {
+	h := New()
+	h.UpdateCounter("a", 5)
+	h.UpdateCounter("b", 3)
+	h.UpdateGauge("a_gauge", 1)
+
+	assert.True(t, h.ResetCounter("a"))
+	assert.False(t, h.ResetCounter("missing"))
+	v, _ := h.GetCounter("a")
+	assert.Equal(t, Counter(0), v)
+
+	assert.Equal(t, 2, h.ResetCounters())
+	v, _ = h.GetCounter("b")
+	assert.Equal(t, Counter(0), v)
+
+	counters, gauges := h.Drop(func(name string) bool { return strings.HasPrefix(name, "a") }, false, true)
+	assert.Empty(t, counters)
+	assert.Equal(t, []string{"a_gauge"}, gauges)
+	_, ok := h.GetGauge("a_gauge")
+	assert.False(t, ok)
+	assert.Nil(t, h.History(GaugeType, "a_gauge"))
+	_, ok = h.GetCounter("a")
+	assert.True(t, ok)
+}
+
+func TestSnapshots(t *testing.T) {
+	file := &Localfile{Path: filepath.Join(t.TempDir(), "metrics.json")}
+	require.NoError(t, file.CheckWritable())
+
+	s := TrackSnapshots(file)
+	_, ok := s.LastSnapshot()
+	assert.False(t, ok)
+
+	require.NoError(t, s.Write(New()))
+	_, ok = s.LastSnapshot()
+	assert.True(t, ok)
+	assert.Less(t, s.Age(), time.Second)
+
+	bad := TrackSnapshots(&Localfile{Path: filepath.Join(t.TempDir(), "missing", "metrics.json")})
+	assert.Error(t, bad.Write(New()))
+	_, ok = bad.LastSnapshot()
+	assert.False(t, ok)
+}
+
+func TestLocalfileConcurrentWrites(t *testing.T) {
+	dir := t.TempDir()
+	s := TrackSnapshots(&Localfile{Path: filepath.Join(dir, "metrics.json")})
+	m := New()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			m.UpdateCounter("c", 1)
+			assert.NoError(t, s.Write(m))
+		}(i)
+	}
+	wg.Wait()
+
+	restored := New()
+	require.NoError(t, (&Localfile{Path: filepath.Join(dir, "metrics.json")}).RestoreData(&restored))
+	v, _ := restored.GetCounter("c")
+	assert.Equal(t, Counter(8), v, "the last write wins")
+
+	files, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, files, 1, "temporary files are removed")
+}
+
+func TestSnapshotsDrop(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrics.json")
+	s := TrackSnapshots(&Localfile{Path: path})
+	m := New()
+	m.UpdateCounter("a", 1)
+	m.UpdateCounter("b", 1)
+	require.NoError(t, s.Write(m))
+
+	counters, gauges := m.Drop(func(name string) bool { return name == "a" }, true, true)
+	require.NoError(t, s.Drop(m, counters, gauges))
+
+	restored := New()
+	require.NoError(t, (&Localfile{Path: path}).RestoreData(&restored))
+	_, ok := restored.GetCounter("a")
+	assert.False(t, ok, "dropped metric is not restored")
+	_, ok = restored.GetCounter("b")
+	assert.True(t, ok)
+}
@@ -0,0 +1,249 @@
+// Package telemetry собирает собственные метрики сервера: число и длительность запросов,
+// размеры батчей, ошибки расшифровки и подписи, длительность сохранения снимков.
+// Метрики отдаются в текстовом формате Prometheus и могут копироваться в MemStorage.
+package telemetry
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Prefix — зарезервированный префикс имён собственных метрик сервера
+const Prefix = "metrics_server_"
+
+// Reserved сообщает, занято ли имя метрики собственными метриками сервера. Такие имена
+// не принимаются от клиентов, чтобы они не подменяли и не сбрасывали телеметрию сервера.
+func Reserved(name string) bool {
+	return strings.HasPrefix(name, Prefix)
+}
+
+// Границы бакетов гистограмм
+var (
+	LatencyBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+	SizeBuckets    = []float64{1, 5, 10, 25, 50, 100, 250, 500, 1000, 5000}
+)
+
+// Default — реестр, в который пишут все компоненты сервера
+var Default = New()
+
+// Типы метрик
+const (
+	KindCounter   = "counter"
+	KindGauge     = "gauge"
+	KindHistogram = "histogram"
+)
+
+// Labels — метки серии
+type Labels map[string]string
+
+// Sample — одно значение серии. Гистограммы раскладываются на серии _bucket, _sum и _count.
+type Sample struct {
+	Name   string // имя с префиксом Prefix
+	Labels Labels
+	Kind   string // counter или gauge
+	Value  float64
+}
+
+type series struct {
+	labels Labels
+	value  float64
+}
+
+type histogram struct {
+	labels  Labels
+	buckets []float64
+	counts  []uint64 // число наблюдений в каждом бакете, не накопленное
+	sum     float64
+	count   uint64
+}
+
+type gaugeFunc struct {
+	labels Labels
+	fn     func() float64
+}
+
+// Registry хранит метрики. Методы безопасны для одновременного вызова и для nil-реестра.
+type Registry struct {
+	mu         sync.Mutex
+	kinds      map[string]string
+	buckets    map[string][]float64
+	counters   map[string]map[string]*series
+	gauges     map[string]map[string]*series
+	gaugeFuncs map[string]map[string]gaugeFunc
+	histograms map[string]map[string]*histogram
+}
+
+// New создаёт пустой реестр
+func New() *Registry {
+	return &Registry{
+		kinds:      map[string]string{},
+		buckets:    map[string][]float64{},
+		counters:   map[string]map[string]*series{},
+		gauges:     map[string]map[string]*series{},
+		gaugeFuncs: map[string]map[string]gaugeFunc{},
+		histograms: map[string]map[string]*histogram{},
+	}
+}
+
+// Inc увеличивает счётчик на единицу
+func (r *Registry) Inc(name string, labels Labels) {
+	r.Add(name, labels, 1)
+}
+
+// Add увеличивает счётчик на delta
+func (r *Registry) Add(name string, labels Labels, delta float64) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.kinds[name] = KindCounter
+	seriesFor(r.counters, name, labels).value += delta
+}
+
+// Set устанавливает значение gauge
+func (r *Registry) Set(name string, labels Labels, value float64) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.kinds[name] = KindGauge
+	seriesFor(r.gauges, name, labels).value = value
+}
+
+// GaugeFunc регистрирует gauge, значение которого вычисляется при каждом чтении
+func (r *Registry) GaugeFunc(name string, labels Labels, fn func() float64) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.kinds[name] = KindGauge
+	if r.gaugeFuncs[name] == nil {
+		r.gaugeFuncs[name] = map[string]gaugeFunc{}
+	}
+	r.gaugeFuncs[name][labelsKey(labels)] = gaugeFunc{labels: labels, fn: fn}
+}
+
+// Buckets задаёт границы бакетов гистограммы; по умолчанию используются LatencyBuckets.
+// Вызывается до первого наблюдения.
+func (r *Registry) Buckets(name string, buckets []float64) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	b := append([]float64(nil), buckets...)
+	sort.Float64s(b)
+	r.buckets[name] = b
+}
+
+// Observe добавляет наблюдение в гистограмму
+func (r *Registry) Observe(name string, labels Labels, value float64) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.kinds[name] = KindHistogram
+	if r.histograms[name] == nil {
+		r.histograms[name] = map[string]*histogram{}
+	}
+	key := labelsKey(labels)
+	h, ok := r.histograms[name][key]
+	if !ok {
+		buckets, ok := r.buckets[name]
+		if !ok {
+			buckets = LatencyBuckets
+		}
+		h = &histogram{labels: copyLabels(labels), buckets: buckets, counts: make([]uint64, len(buckets))}
+		r.histograms[name][key] = h
+	}
+
+	for i, le := range h.buckets {
+		if value <= le {
+			h.counts[i]++
+			break
+		}
+	}
+	h.sum += value
+	h.count++
+}
+
+// Value возвращает значение счётчика или gauge
+func (r *Registry) Value(name string, labels Labels) (float64, bool) {
+	if r == nil {
+		return 0, false
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := labelsKey(labels)
+	if s, ok := r.counters[name][key]; ok {
+		return s.value, true
+	}
+	if s, ok := r.gauges[name][key]; ok {
+		return s.value, true
+	}
+	return 0, false
+}
+
+// seriesFor возвращает серию, создавая её при необходимости. Вызывается под блокировкой.
+func seriesFor(families map[string]map[string]*series, name string, labels Labels) *series {
+	if families[name] == nil {
+		families[name] = map[string]*series{}
+	}
+	key := labelsKey(labels)
+	s, ok := families[name][key]
+	if !ok {
+		s = &series{labels: copyLabels(labels)}
+		families[name][key] = s
+	}
+	return s
+}
+
+// labelsKey строит ключ серии из отсортированных меток
+func labelsKey(labels Labels) string {
+	keys := sortedKeys(labels)
+	var b strings.Builder
+	for _, k := range keys {
+		b.WriteString(k)
+		b.WriteByte('=')
+		b.WriteString(labels[k])
+		b.WriteByte(0)
+	}
+	return b.String()
+}
+
+func sortedKeys(labels Labels) []string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func copyLabels(labels Labels) Labels {
+	if len(labels) == 0 {
+		return nil
+	}
+	res := make(Labels, len(labels))
+	for k, v := range labels {
+		res[k] = v
+	}
+	return res
+}
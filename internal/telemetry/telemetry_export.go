@@ -0,0 +1,192 @@
+package telemetry
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ContentType — тип содержимого текстового формата Prometheus
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// family — все серии одной метрики
+type family struct {
+	name    string // имя с префиксом
+	kind    string
+	samples []Sample
+}
+
+// Samples возвращает значения всех серий, отсортированные по имени
+func (r *Registry) Samples() []Sample {
+	var res []Sample
+	for _, f := range r.collect() {
+		res = append(res, f.samples...)
+	}
+	return res
+}
+
+// collect делает снимок реестра. Функции gauge вызываются без блокировки реестра.
+func (r *Registry) collect() []family {
+	if r == nil {
+		return nil
+	}
+
+	r.mu.Lock()
+	families := map[string]*family{}
+	get := func(name string) *family {
+		f, ok := families[name]
+		if !ok {
+			f = &family{name: Prefix + name, kind: r.kinds[name]}
+			families[name] = f
+		}
+		return f
+	}
+
+	for name, all := range r.counters {
+		f := get(name)
+		for _, s := range all {
+			f.samples = append(f.samples, Sample{Name: f.name, Labels: s.labels, Kind: KindCounter, Value: s.value})
+		}
+	}
+	for name, all := range r.gauges {
+		f := get(name)
+		for _, s := range all {
+			f.samples = append(f.samples, Sample{Name: f.name, Labels: s.labels, Kind: KindGauge, Value: s.value})
+		}
+	}
+	var funcs []struct {
+		f  *family
+		gf gaugeFunc
+	}
+	for name, all := range r.gaugeFuncs {
+		f := get(name)
+		for _, gf := range all {
+			funcs = append(funcs, struct {
+				f  *family
+				gf gaugeFunc
+			}{f, gf})
+		}
+	}
+	for name, all := range r.histograms {
+		f := get(name)
+		for _, h := range all {
+			f.samples = append(f.samples, h.samples(f.name)...)
+		}
+	}
+	r.mu.Unlock()
+
+	for _, fn := range funcs {
+		fn.f.samples = append(fn.f.samples, Sample{Name: fn.f.name, Labels: fn.gf.labels, Kind: KindGauge, Value: fn.gf.fn()})
+	}
+
+	res := make([]family, 0, len(families))
+	for _, f := range families {
+		sort.SliceStable(f.samples, func(i, j int) bool {
+			if f.samples[i].Name != f.samples[j].Name {
+				return f.samples[i].Name < f.samples[j].Name
+			}
+			// Бакеты гистограммы уже идут по возрастанию le, их порядок сохраняется
+			return seriesKey(f.samples[i].Labels) < seriesKey(f.samples[j].Labels)
+		})
+		res = append(res, *f)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].name < res[j].name })
+	return res
+}
+
+// samples раскладывает гистограмму на накопленные бакеты, сумму и число наблюдений
+func (h *histogram) samples(name string) []Sample {
+	res := make([]Sample, 0, len(h.buckets)+3)
+
+	var cumulative uint64
+	for i, le := range h.buckets {
+		cumulative += h.counts[i]
+		res = append(res, Sample{Name: name + "_bucket", Labels: withLabel(h.labels, "le", formatFloat(le)), Kind: KindCounter, Value: float64(cumulative)})
+	}
+	res = append(res,
+		Sample{Name: name + "_bucket", Labels: withLabel(h.labels, "le", "+Inf"), Kind: KindCounter, Value: float64(h.count)},
+		Sample{Name: name + "_sum", Labels: h.labels, Kind: KindGauge, Value: h.sum},
+		Sample{Name: name + "_count", Labels: h.labels, Kind: KindCounter, Value: float64(h.count)},
+	)
+	return res
+}
+
+// WritePrometheus записывает все метрики в текстовом формате Prometheus
+func (r *Registry) WritePrometheus(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	for _, f := range r.collect() {
+		bw.WriteString("# TYPE " + f.name + " " + f.kind + "\n")
+		for _, s := range f.samples {
+			bw.WriteString(s.Name)
+			writeLabels(bw, s.Labels)
+			bw.WriteByte(' ')
+			bw.WriteString(formatFloat(s.Value))
+			bw.WriteByte('\n')
+		}
+	}
+	return bw.Flush()
+}
+
+// Handler отдаёт метрики реестра в текстовом формате Prometheus
+func (r *Registry) Handler(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", ContentType)
+	w.WriteHeader(http.StatusOK)
+	r.WritePrometheus(w)
+}
+
+func writeLabels(bw *bufio.Writer, labels Labels) {
+	if len(labels) == 0 {
+		return
+	}
+
+	bw.WriteByte('{')
+	for i, k := range sortedKeys(labels) {
+		if i > 0 {
+			bw.WriteByte(',')
+		}
+		bw.WriteString(k)
+		bw.WriteString(`="`)
+		bw.WriteString(labelEscaper.Replace(labels[k]))
+		bw.WriteByte('"')
+	}
+	bw.WriteByte('}')
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// seriesKey — ключ серии без метки бакета le
+func seriesKey(labels Labels) string {
+	if _, ok := labels["le"]; !ok {
+		return labelsKey(labels)
+	}
+	res := make(Labels, len(labels))
+	for k, v := range labels {
+		if k != "le" {
+			res[k] = v
+		}
+	}
+	return labelsKey(res)
+}
+
+func withLabel(labels Labels, k, v string) Labels {
+	res := make(Labels, len(labels)+1)
+	for lk, lv := range labels {
+		res[lk] = lv
+	}
+	res[k] = v
+	return res
+}
@@ -0,0 +1,54 @@
+package telemetry
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// statusWriter запоминает код ответа
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Middleware считает запросы и их длительность по маршруту, методу и коду ответа.
+// Маршрут берётся из шаблона chi, чтобы имена метрик не попадали в метки.
+func Middleware(r *Registry) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			start := time.Now()
+			sw := &statusWriter{ResponseWriter: w}
+			next.ServeHTTP(sw, req)
+
+			route := "unmatched"
+			if rctx := chi.RouteContext(req.Context()); rctx != nil && rctx.RoutePattern() != "" {
+				route = rctx.RoutePattern()
+			}
+			status := sw.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+
+			labels := Labels{"route": route, "method": req.Method, "status": strconv.Itoa(status)}
+			r.Inc("http_requests_total", labels)
+			r.Observe("http_request_duration_seconds", labels, time.Since(start).Seconds())
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package telemetry
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWritePrometheus(t *testing.T) {
+	r := New()
+	r.Inc("signature_failures_total", Labels{"reason": "mismatch"})
+	r.Inc("signature_failures_total", Labels{"reason": "mismatch"})
+	r.Set("queue", Labels{"name": `a"b`}, 3)
+	r.GaugeFunc("series", Labels{"type": "gauge"}, func() float64 { return 7 })
+	r.Buckets("batch_size", []float64{10, 1})
+	r.Observe("batch_size", nil, 5)
+	r.Observe("batch_size", nil, 50)
+
+	var b strings.Builder
+	require.NoError(t, r.WritePrometheus(&b))
+	assert.Equal(t, `# TYPE metrics_server_batch_size histogram
+metrics_server_batch_size_bucket{le="1"} 0
+metrics_server_batch_size_bucket{le="10"} 1
+metrics_server_batch_size_bucket{le="+Inf"} 2
+metrics_server_batch_size_count 2
+metrics_server_batch_size_sum 55
+# TYPE metrics_server_queue gauge
+metrics_server_queue{name="a\"b"} 3
+# TYPE metrics_server_series gauge
+metrics_server_series{type="gauge"} 7
+# TYPE metrics_server_signature_failures_total counter
+metrics_server_signature_failures_total{reason="mismatch"} 2
+`, b.String())
+
+	v, ok := r.Value("signature_failures_total", Labels{"reason": "mismatch"})
+	assert.True(t, ok)
+	assert.Equal(t, 2.0, v)
+}
+
+func TestMiddleware(t *testing.T) {
+	r := New()
+	router := chi.NewRouter()
+	router.Use(Middleware(r))
+	router.Get("/value/gauge/{metric}", func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	for _, name := range []string{"a", "b"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/value/gauge/"+name, nil))
+	}
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/unknown", nil))
+
+	v, _ := r.Value("http_requests_total", Labels{"route": "/value/gauge/{metric}", "method": "GET", "status": "404"})
+	assert.Equal(t, 2.0, v, "metric names should not become label values")
+	v, _ = r.Value("http_requests_total", Labels{"route": "unmatched", "method": "GET", "status": "404"})
+	assert.Equal(t, 1.0, v)
+
+	var count float64
+	for _, s := range r.Samples() {
+		if s.Name == Prefix+"http_request_duration_seconds_count" {
+			count += s.Value
+		}
+	}
+	assert.Equal(t, 3.0, count)
+}
+
+func TestNilRegistry(t *testing.T) {
+	var r *Registry
+	r.Inc("x", nil)
+	r.Observe("y", nil, 1)
+	assert.Empty(t, r.Samples())
+}
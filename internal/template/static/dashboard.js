@@ -0,0 +1,16 @@
+// Мгновенная фильтрация строк таблицы по мере ввода, без перезагрузки страницы
+(function () {
+    var input = document.getElementById("filter");
+    var rows = document.querySelectorAll("#metrics tbody tr[data-name]");
+    if (!input) {
+        return;
+    }
+
+    input.addEventListener("input", function () {
+        var q = input.value.toLowerCase();
+        rows.forEach(function (row) {
+            var name = row.getAttribute("data-name").toLowerCase();
+            row.style.display = name.indexOf(q) === -1 ? "none" : "";
+        });
+    });
+})();
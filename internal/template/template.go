@@ -0,0 +1,15 @@
+// Package template содержит HTML-шаблоны и статические файлы веб-интерфейса,
+// встроенные в бинарный файл сервера
+package template
+
+import "embed"
+
+// Pages — HTML-шаблоны страниц
+//
+//go:embed *.html
+var Pages embed.FS
+
+// Static — стили и скрипты, отдаются по пути /static/
+//
+//go:embed static
+var Static embed.FS